@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/astjson"
+	"github.com/elm-tangram/tangram/parser"
+	"github.com/elm-tangram/tangram/source"
+)
+
+var astCmd = &command{
+	name:  "ast",
+	usage: "[flags] file.elm",
+	short: "Print the AST of the given file as JSON",
+	run:   runAST,
+}
+
+func runAST(cmd *command, args []string) error {
+	flags := newFlagSet(cmd)
+	noResolve := flags.Bool("no-resolve", false, "only parse the file, without parsing its imports and resolving the identifiers")
+	compact := flags.Bool("compact", false, "do not indent the JSON output")
+	schema := flags.Bool("schema", false, "print the JSON Schema of the output instead")
+	maxErrors := maxErrorsFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+
+	if *schema {
+		data, err = astjson.Schema()
+	} else {
+		if flags.NArg() != 1 {
+			flags.Usage()
+			return flag.ErrHelp
+		}
+		data, err = marshalFile(flags.Arg(0), *noResolve, *maxErrors)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !*compact {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", "  "); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+
+	_, err = fmt.Fprintln(os.Stdout, string(data))
+	return err
+}
+
+// marshalFile returns the JSON encoding of the module in the file at the
+// given path. The modules of the dependencies are loaded from the build
+// cache when possible, as only their interfaces are needed.
+func marshalFile(path string, noResolve bool, maxErrors int) ([]byte, error) {
+	mod, pkg, cm, err := parseFile(path, noResolve, true, maxErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	return astjson.Marshal(mod, cm.Source(mod.Path), pkg)
+}
+
+// parseFile parses the module in the file at the given path and returns it
+// along with the code map with the source files that were parsed and, unless
+// noResolve is true, the package it belongs to, with all its identifiers
+// resolved. If cache is true, the modules of the dependencies are loaded from
+// the build cache of the package instead of being parsed, so they have no
+// definitions nor source. Only maxErrors errors of the package are reported,
+// unless it is 0.
+func parseFile(path string, noResolve, cache bool, maxErrors int) (*ast.Module, *ast.Package, *source.CodeMap, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if noResolve {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		loader := source.NewMemLoader()
+		loader.Add(path, string(data))
+		cm := source.NewCodeMap(loader)
+		if err := cm.Add(path); err != nil {
+			return nil, nil, nil, err
+		}
+
+		mod, err := parser.ParseFrom(path, bytes.NewReader(data), parser.FullParse|parser.SkipWarnings)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return mod, nil, cm, nil
+	}
+
+	mode := parser.FullParse | parser.SkipWarnings
+	if cache {
+		mode |= parser.UseCache
+	}
+
+	pkg, cm, err := parser.ParseSources(path, mode, maxErrors)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	mod, err := findModule(pkg, path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return mod, pkg, cm, nil
+}
+
+// findModule returns the module of the package in the file at the given
+// absolute path.
+func findModule(pkg *ast.Package, path string) (*ast.Module, error) {
+	for _, mod := range pkg.Modules {
+		modPath, err := filepath.Abs(mod.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		if modPath == path {
+			return mod, nil
+		}
+	}
+	return nil, fmt.Errorf("elmc: can't find the module of %q in the package", path)
+}
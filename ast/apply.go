@@ -0,0 +1,392 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ApplyFunc is invoked by Apply for each node, including nil nodes of
+// optional fields, before and after its children have been traversed.
+type ApplyFunc func(*Cursor) bool
+
+// Apply traverses an AST recursively with the given node as a starting point,
+// calling pre and post for each node, in the same order as Walk does.
+//
+// If pre is not nil, it is called for each node before the children of the
+// node are traversed. If pre returns false, the children are not traversed
+// and post is not called for that node.
+//
+// If post is not nil, and a prior call of pre did not return false, post is
+// called for each node after its children are traversed. If post returns
+// false, the traversal is stopped and Apply returns immediately.
+//
+// Only fields that refer to AST nodes are considered children, that is, the
+// objects of identifiers are not traversed.
+//
+// Children of a node are traversed in the order they appear in the source
+// code. If the node being visited is replaced through the cursor, the new
+// node is not traversed, the children of the original one are.
+//
+// Apply returns the root node, which may have been replaced.
+func Apply(root Node, pre, post ApplyFunc) (result Node) {
+	parent := &struct{ Node }{root}
+	defer func() {
+		if r := recover(); r != nil && r != abort {
+			panic(r)
+		}
+		result = parent.Node
+	}()
+
+	a := &application{pre: pre, post: post}
+	a.apply(parent, "Node", nil, root)
+	return
+}
+
+var abort = new(int)
+
+// Cursor describes the node being visited during Apply and allows to
+// replace it, delete it or insert nodes around it.
+type Cursor struct {
+	parent Node
+	name   string
+	iter   *iterator
+	node   Node
+}
+
+// Node returns the node being visited.
+func (c *Cursor) Node() Node { return c.node }
+
+// Parent returns the parent of the node being visited.
+func (c *Cursor) Parent() Node { return c.parent }
+
+// Name returns the name of the field of the parent that contains the node
+// being visited. If the field is a slice, the node is at Index in it.
+func (c *Cursor) Name() string { return c.name }
+
+// Index returns the index of the node being visited in the slice of its
+// parent that contains it, or a value below zero if it is not in a slice.
+// The index is updated when nodes are inserted or deleted before the
+// current one.
+func (c *Cursor) Index() int {
+	if c.iter != nil {
+		return c.iter.index
+	}
+	return -1
+}
+
+// field returns the field of the parent that contains the node.
+func (c *Cursor) field() reflect.Value {
+	return reflect.Indirect(reflect.ValueOf(c.parent)).FieldByName(c.name)
+}
+
+// Replace replaces the node being visited with n. The replacement is not
+// traversed by Apply. It panics if n can't be stored in the field of the
+// parent that contains the node.
+func (c *Cursor) Replace(n Node) {
+	v := c.field()
+	if i := c.Index(); i >= 0 {
+		v = v.Index(i)
+	}
+	v.Set(nodeValue(n, v.Type()))
+	c.node = n
+}
+
+// Delete deletes the node being visited from the slice that contains it.
+// It panics if the node is not in a slice.
+func (c *Cursor) Delete() {
+	i := c.Index()
+	if i < 0 {
+		panic(fmt.Errorf("ast: can't delete node of type %T, it is not contained in a slice", c.node))
+	}
+
+	v := c.field()
+	l := v.Len()
+	reflect.Copy(v.Slice(i, l), v.Slice(i+1, l))
+	v.Index(l - 1).Set(reflect.Zero(v.Type().Elem()))
+	v.SetLen(l - 1)
+	c.iter.step--
+}
+
+// InsertAfter inserts n after the node being visited in the slice that
+// contains it. The inserted node is not traversed by Apply. It panics if the
+// node is not in a slice.
+func (c *Cursor) InsertAfter(n Node) {
+	i := c.Index()
+	if i < 0 {
+		panic(fmt.Errorf("ast: can't insert after node of type %T, it is not contained in a slice", c.node))
+	}
+
+	v := c.field()
+	l := v.Len()
+	v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+	reflect.Copy(v.Slice(i+2, l+1), v.Slice(i+1, l))
+	v.Index(i + 1).Set(nodeValue(n, v.Type().Elem()))
+	c.iter.step++
+}
+
+// InsertBefore inserts n before the node being visited in the slice that
+// contains it. The inserted node is not traversed by Apply. It panics if the
+// node is not in a slice.
+func (c *Cursor) InsertBefore(n Node) {
+	i := c.Index()
+	if i < 0 {
+		panic(fmt.Errorf("ast: can't insert before node of type %T, it is not contained in a slice", c.node))
+	}
+
+	v := c.field()
+	l := v.Len()
+	v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+	reflect.Copy(v.Slice(i+1, l+1), v.Slice(i, l))
+	v.Index(i).Set(nodeValue(n, v.Type().Elem()))
+	c.iter.index++
+}
+
+// nodeValue returns the value of the node to store in a field of the given
+// type, which is the zero value of the type if the node is nil.
+func nodeValue(n Node, typ reflect.Type) reflect.Value {
+	if n == nil {
+		return reflect.Zero(typ)
+	}
+	return reflect.ValueOf(n)
+}
+
+// iterator is the position of the node being visited in a slice.
+type iterator struct {
+	index, step int
+}
+
+type application struct {
+	pre, post ApplyFunc
+	cursor    Cursor
+	iter      iterator
+}
+
+func (a *application) apply(parent Node, name string, iter *iterator, n Node) {
+	// typed nils are treated as nil nodes
+	if v := reflect.ValueOf(n); v.Kind() == reflect.Ptr && v.IsNil() {
+		n = nil
+	}
+
+	saved := a.cursor
+	a.cursor.parent = parent
+	a.cursor.name = name
+	a.cursor.iter = iter
+	a.cursor.node = n
+
+	if a.pre != nil && !a.pre(&a.cursor) {
+		a.cursor = saved
+		return
+	}
+
+	switch n := n.(type) {
+	case nil:
+		// nothing to do
+
+	case *Module:
+		a.apply(n, "Module", nil, n.Module)
+		a.applyList(n, "Imports")
+		a.applyList(n, "Decls")
+
+	// Decls
+	case *ModuleDecl:
+		a.apply(n, "Name", nil, n.Name)
+		a.apply(n, "Exposing", nil, n.Exposing)
+
+	case *ImportDecl:
+		a.apply(n, "Module", nil, n.Module)
+		a.apply(n, "Alias", nil, n.Alias)
+		a.apply(n, "Exposing", nil, n.Exposing)
+
+	case *ClosedList:
+		a.applyList(n, "Exposed")
+
+	case *OpenList:
+		// nothing to do
+
+	case *ExposedVar:
+		a.apply(n, "Ident", nil, n.Ident)
+
+	case *ExposedUnion:
+		a.apply(n, "Type", nil, n.Type)
+		a.apply(n, "Ctors", nil, n.Ctors)
+
+	case *InfixDecl:
+		a.apply(n, "Op", nil, n.Op)
+		a.apply(n, "Precedence", nil, n.Precedence)
+
+	case *AliasDecl:
+		a.apply(n, "Name", nil, n.Name)
+		a.applyList(n, "Args")
+		a.apply(n, "Type", nil, n.Type)
+
+	case *UnionDecl:
+		a.apply(n, "Name", nil, n.Name)
+		a.applyList(n, "Args")
+		a.applyList(n, "Ctors")
+
+	case *Constructor:
+		a.apply(n, "Name", nil, n.Name)
+		a.applyList(n, "Args")
+
+	case *DestructuringAssignment:
+		a.apply(n, "Pattern", nil, n.Pattern)
+		a.apply(n, "Expr", nil, n.Expr)
+
+	case *Definition:
+		a.apply(n, "Annotation", nil, n.Annotation)
+		a.apply(n, "Name", nil, n.Name)
+		a.applyList(n, "Args")
+		a.apply(n, "Body", nil, n.Body)
+
+	case *TypeAnnotation:
+		a.apply(n, "Name", nil, n.Name)
+		a.apply(n, "Type", nil, n.Type)
+
+	// Types
+	case *NamedType:
+		a.apply(n, "Name", nil, n.Name)
+		a.applyList(n, "Args")
+
+	case *VarType:
+		a.apply(n, "Ident", nil, n.Ident)
+
+	case *FuncType:
+		a.applyList(n, "Args")
+		a.apply(n, "Return", nil, n.Return)
+
+	case *RecordType:
+		a.applyList(n, "Fields")
+
+	case *RecordField:
+		a.apply(n, "Name", nil, n.Name)
+		a.apply(n, "Type", nil, n.Type)
+
+	case *TupleType:
+		a.applyList(n, "Elems")
+
+	// Patterns
+	case *VarPattern:
+		a.apply(n, "Name", nil, n.Name)
+
+	case *AnythingPattern:
+		// nothing to do
+
+	case *LiteralPattern:
+		a.apply(n, "Literal", nil, n.Literal)
+
+	case *AliasPattern:
+		a.apply(n, "Pattern", nil, n.Pattern)
+		a.apply(n, "Name", nil, n.Name)
+
+	case *CtorPattern:
+		a.apply(n, "Ctor", nil, n.Ctor)
+		a.applyList(n, "Args")
+
+	case *TuplePattern:
+		a.applyList(n, "Elems")
+
+	case *RecordPattern:
+		a.applyList(n, "Fields")
+
+	case *ListPattern:
+		a.applyList(n, "Elems")
+
+	// Exprs
+	case *Ident, *BasicLit, *ShaderExpr, *TupleCtor, *BadExpr:
+		// nothing to do
+
+	case *SelectorExpr:
+		a.apply(n, "Selector", nil, n.Selector)
+		a.apply(n, "Expr", nil, n.Expr)
+
+	case *TupleLit:
+		a.applyList(n, "Elems")
+
+	case *FuncApp:
+		a.apply(n, "Func", nil, n.Func)
+		a.applyList(n, "Args")
+
+	case *RecordLit:
+		a.applyList(n, "Fields")
+
+	case *FieldAssign:
+		a.apply(n, "Field", nil, n.Field)
+		a.apply(n, "Expr", nil, n.Expr)
+
+	case *RecordUpdate:
+		a.apply(n, "Record", nil, n.Record)
+		a.applyList(n, "Fields")
+
+	case *LetExpr:
+		a.applyList(n, "Decls")
+		a.apply(n, "Body", nil, n.Body)
+
+	case *IfExpr:
+		a.apply(n, "Cond", nil, n.Cond)
+		a.apply(n, "ThenExpr", nil, n.ThenExpr)
+		a.apply(n, "ElseExpr", nil, n.ElseExpr)
+
+	case *CaseExpr:
+		a.apply(n, "Expr", nil, n.Expr)
+		a.applyList(n, "Branches")
+
+	case *CaseBranch:
+		a.apply(n, "Pattern", nil, n.Pattern)
+		a.apply(n, "Expr", nil, n.Expr)
+
+	case *Lambda:
+		a.applyList(n, "Args")
+		a.apply(n, "Expr", nil, n.Expr)
+
+	case *ListLit:
+		a.applyList(n, "Elems")
+
+	case *UnaryOp:
+		a.apply(n, "Op", nil, n.Op)
+		a.apply(n, "Expr", nil, n.Expr)
+
+	case *BinaryOp:
+		a.apply(n, "Op", nil, n.Op)
+		a.apply(n, "Lhs", nil, n.Lhs)
+		a.apply(n, "Rhs", nil, n.Rhs)
+
+	case *AccessorExpr:
+		a.apply(n, "Field", nil, n.Field)
+
+	case *ParensExpr:
+		a.apply(n, "Expr", nil, n.Expr)
+
+	default:
+		panic(fmt.Errorf("apply: unable to apply to node of type %T", n))
+	}
+
+	if a.post != nil && !a.post(&a.cursor) {
+		panic(abort)
+	}
+
+	a.cursor = saved
+}
+
+// applyList applies to all the nodes in the slice field with the given name
+// of the parent. The field is read again after every node, because the
+// cursor may have modified it.
+func (a *application) applyList(parent Node, name string) {
+	saved := a.iter
+	a.iter.index = 0
+	for {
+		v := reflect.Indirect(reflect.ValueOf(parent)).FieldByName(name)
+		if a.iter.index >= v.Len() {
+			break
+		}
+
+		var n Node
+		if e := v.Index(a.iter.index); e.IsValid() && !e.IsNil() {
+			n = e.Interface().(Node)
+		}
+
+		a.iter.step = 1
+		a.apply(parent, name, &a.iter, n)
+		a.iter.index += a.iter.step
+	}
+	a.iter = saved
+}
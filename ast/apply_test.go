@@ -0,0 +1,173 @@
+package ast
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyVisitsLikeWalk(t *testing.T) {
+	require := require.New(t)
+
+	var pre, post = make(map[string]int), make(map[string]int)
+	result := Apply(testFile, func(c *Cursor) bool {
+		if c.Node() != nil {
+			pre[fmt.Sprintf("%T", c.Node())]++
+		}
+		return true
+	}, func(c *Cursor) bool {
+		if c.Node() != nil {
+			post[fmt.Sprintf("%T", c.Node())]++
+		}
+		return true
+	})
+
+	require.True(result == testFile)
+	for typ, num := range expectedVisits {
+		require.Equal(num, pre[typ], "pre visits for type %s", typ)
+		require.Equal(num, post[typ], "post visits for type %s", typ)
+	}
+}
+
+func lit(v string) *BasicLit {
+	return &BasicLit{Type: Int, Value: v}
+}
+
+func litValues(exprs []Expr) []string {
+	var result []string
+	for _, e := range exprs {
+		switch e := e.(type) {
+		case *BasicLit:
+			result = append(result, e.Value)
+		case *Ident:
+			result = append(result, e.Name)
+		}
+	}
+	return result
+}
+
+func TestApplyCursor(t *testing.T) {
+	require := require.New(t)
+
+	app := &FuncApp{
+		Func: &Ident{Name: "f"},
+		Args: []Expr{lit("1"), lit("2"), lit("3"), lit("4")},
+	}
+
+	var visited []string
+	Apply(app, func(c *Cursor) bool {
+		switch n := c.Node().(type) {
+		case *Ident:
+			require.Equal("Func", c.Name())
+			require.Equal(-1, c.Index())
+			c.Replace(&Ident{Name: "g"})
+		case *BasicLit:
+			require.Equal("Args", c.Name())
+			require.True(c.Parent() == app)
+			visited = append(visited, fmt.Sprintf("%s@%d", n.Value, c.Index()))
+
+			switch n.Value {
+			case "1":
+				c.InsertBefore(&Ident{Name: "before"})
+			case "2":
+				c.Delete()
+			case "3":
+				c.InsertAfter(&Ident{Name: "after"})
+				c.Replace(lit("three"))
+			}
+		}
+		return true
+	}, nil)
+
+	require.Equal([]string{"1@0", "2@2", "3@2", "4@4"}, visited)
+	require.Equal("g", app.Func.(*Ident).Name)
+	require.Equal([]string{"before", "1", "three", "after", "4"}, litValues(app.Args))
+}
+
+func TestApplyReplaceRoot(t *testing.T) {
+	require := require.New(t)
+
+	result := Apply(lit("1"), func(c *Cursor) bool {
+		c.Replace(lit("2"))
+		return true
+	}, nil)
+	require.Equal(lit("2"), result)
+}
+
+func TestApplyNilNodes(t *testing.T) {
+	require := require.New(t)
+
+	def := &Definition{
+		Name: &Ident{Name: "foo"},
+		Body: lit("1"),
+	}
+
+	Apply(def, func(c *Cursor) bool {
+		if c.Name() == "Annotation" {
+			require.Nil(c.Node())
+			c.Replace(&TypeAnnotation{
+				Name: &Ident{Name: "foo"},
+				Type: &NamedType{Name: &Ident{Name: "Int"}},
+			})
+		}
+		return true
+	}, nil)
+
+	require.NotNil(def.Annotation)
+	require.Equal("foo", def.Annotation.Name.Name)
+}
+
+func TestApplySkipAndAbort(t *testing.T) {
+	require := require.New(t)
+
+	app := &FuncApp{
+		Func: &Ident{Name: "f"},
+		Args: []Expr{
+			&ParensExpr{Expr: lit("1")},
+			lit("2"),
+			lit("3"),
+		},
+	}
+
+	var visited []string
+	Apply(app, func(c *Cursor) bool {
+		if lit, ok := c.Node().(*BasicLit); ok {
+			visited = append(visited, lit.Value)
+		}
+		_, parens := c.Node().(*ParensExpr)
+		return !parens
+	}, func(c *Cursor) bool {
+		lit, ok := c.Node().(*BasicLit)
+		return !ok || lit.Value != "2"
+	})
+
+	require.Equal([]string{"2"}, visited)
+}
+
+func TestApplyInvalid(t *testing.T) {
+	require := require.New(t)
+
+	app := &FuncApp{
+		Func: &Ident{Name: "f"},
+		Args: []Expr{lit("1")},
+	}
+
+	require.Panics(func() {
+		Apply(app, func(c *Cursor) bool {
+			if c.Name() == "Func" {
+				c.Delete()
+			}
+			return true
+		}, nil)
+	})
+
+	require.Panics(func() {
+		Apply(app, func(c *Cursor) bool {
+			if c.Name() == "Args" {
+				c.Replace(&OpenList{})
+			}
+			return true
+		}, nil)
+	})
+}
@@ -21,6 +21,10 @@ type Module struct {
 	NativeImports []string
 	Decls         []Decl
 	Scope         *ModuleScope
+	// Tokens contains all the tokens of the module with their leading
+	// trivia, so the module can be printed back exactly as it was written.
+	// It is only set if the module was parsed keeping the trivia.
+	Tokens []*token.Token
 }
 
 func (f *Module) Pos() token.Pos { return f.Module.Pos() }
@@ -0,0 +1,440 @@
+package ast
+
+import "fmt"
+
+// Copy returns a deep copy of the given node and all its children. The
+// objects identifiers refer to and the scope of modules are shared with the
+// original node, not copied, so the copy stays resolved.
+func Copy(node Node) Node {
+	switch n := node.(type) {
+	case nil:
+		return nil
+
+	case *Module:
+		c := *n
+		if n.Module != nil {
+			c.Module = Copy(n.Module).(*ModuleDecl)
+		}
+
+		if n.Imports != nil {
+			c.Imports = make([]*ImportDecl, len(n.Imports))
+			for i, imp := range n.Imports {
+				c.Imports[i] = Copy(imp).(*ImportDecl)
+			}
+		}
+
+		c.NativeImports = copyStrings(n.NativeImports)
+		c.Decls = copyDecls(n.Decls)
+		return &c
+
+	// Decls
+	case *ModuleDecl:
+		c := *n
+		c.Name = copyExpr(n.Name)
+		c.Exposing = copyExposedList(n.Exposing)
+		return &c
+
+	case *ImportDecl:
+		c := *n
+		c.Module = copyExpr(n.Module)
+		c.Alias = copyIdent(n.Alias)
+		c.Exposing = copyExposedList(n.Exposing)
+		return &c
+
+	case *ClosedList:
+		c := *n
+		if n.Exposed != nil {
+			c.Exposed = make([]ExposedIdent, len(n.Exposed))
+			for i, e := range n.Exposed {
+				c.Exposed[i] = Copy(e).(ExposedIdent)
+			}
+		}
+		return &c
+
+	case *OpenList:
+		c := *n
+		return &c
+
+	case *ExposedVar:
+		return &ExposedVar{copyIdent(n.Ident)}
+
+	case *ExposedUnion:
+		return &ExposedUnion{
+			Type:  copyIdent(n.Type),
+			Ctors: copyExposedList(n.Ctors),
+		}
+
+	case *InfixDecl:
+		c := *n
+		c.Op = copyIdent(n.Op)
+		if n.Precedence != nil {
+			c.Precedence = Copy(n.Precedence).(*BasicLit)
+		}
+		return &c
+
+	case *AliasDecl:
+		c := *n
+		c.Name = copyIdent(n.Name)
+		c.Args = copyIdents(n.Args)
+		c.Type = copyType(n.Type)
+		return &c
+
+	case *UnionDecl:
+		c := *n
+		c.Name = copyIdent(n.Name)
+		c.Args = copyIdents(n.Args)
+		if n.Ctors != nil {
+			c.Ctors = make([]*Constructor, len(n.Ctors))
+			for i, ctor := range n.Ctors {
+				c.Ctors[i] = Copy(ctor).(*Constructor)
+			}
+		}
+		return &c
+
+	case *Constructor:
+		return &Constructor{
+			Name: copyIdent(n.Name),
+			Args: copyTypes(n.Args),
+		}
+
+	case *DestructuringAssignment:
+		c := *n
+		c.Pattern = copyPattern(n.Pattern)
+		c.Expr = copyExpr(n.Expr)
+		return &c
+
+	case *Definition:
+		c := *n
+		if n.Annotation != nil {
+			c.Annotation = Copy(n.Annotation).(*TypeAnnotation)
+		}
+		c.Name = copyIdent(n.Name)
+		c.Args = copyPatterns(n.Args)
+		c.Body = copyExpr(n.Body)
+		return &c
+
+	case *TypeAnnotation:
+		c := *n
+		c.Name = copyIdent(n.Name)
+		c.Type = copyType(n.Type)
+		return &c
+
+	// Types
+	case *NamedType:
+		return &NamedType{
+			Name: copyExpr(n.Name),
+			Args: copyTypes(n.Args),
+		}
+
+	case *VarType:
+		return &VarType{copyIdent(n.Ident)}
+
+	case *FuncType:
+		return &FuncType{
+			Args:   copyTypes(n.Args),
+			Return: copyType(n.Return),
+		}
+
+	case *RecordType:
+		c := *n
+		if n.Fields != nil {
+			c.Fields = make([]*RecordField, len(n.Fields))
+			for i, f := range n.Fields {
+				c.Fields[i] = Copy(f).(*RecordField)
+			}
+		}
+		return &c
+
+	case *RecordField:
+		c := *n
+		c.Name = copyIdent(n.Name)
+		c.Type = copyType(n.Type)
+		return &c
+
+	case *TupleType:
+		c := *n
+		c.Elems = copyTypes(n.Elems)
+		return &c
+
+	// Patterns
+	case *VarPattern:
+		return &VarPattern{copyIdent(n.Name)}
+
+	case *AnythingPattern:
+		c := *n
+		return &c
+
+	case *LiteralPattern:
+		if n.Literal == nil {
+			return &LiteralPattern{}
+		}
+		return &LiteralPattern{Copy(n.Literal).(*BasicLit)}
+
+	case *AliasPattern:
+		return &AliasPattern{
+			Name:    copyIdent(n.Name),
+			Pattern: copyPattern(n.Pattern),
+		}
+
+	case *CtorPattern:
+		return &CtorPattern{
+			Ctor: copyExpr(n.Ctor),
+			Args: copyPatterns(n.Args),
+		}
+
+	case *TuplePattern:
+		c := *n
+		c.Elems = copyPatterns(n.Elems)
+		return &c
+
+	case *RecordPattern:
+		c := *n
+		c.Fields = copyPatterns(n.Fields)
+		return &c
+
+	case *ListPattern:
+		c := *n
+		c.Elems = copyPatterns(n.Elems)
+		return &c
+
+	// Exprs
+	case *Ident:
+		return copyIdent(n)
+
+	case *BasicLit:
+		c := *n
+		return &c
+
+	case *ShaderExpr:
+		c := *n
+		return &c
+
+	case *SelectorExpr:
+		return &SelectorExpr{
+			Expr:     copyExpr(n.Expr),
+			Selector: copyIdent(n.Selector),
+		}
+
+	case *TupleLit:
+		c := *n
+		c.Elems = copyExprs(n.Elems)
+		return &c
+
+	case *FuncApp:
+		return &FuncApp{
+			Func: copyExpr(n.Func),
+			Args: copyExprs(n.Args),
+		}
+
+	case *RecordLit:
+		c := *n
+		c.Fields = copyFieldAssigns(n.Fields)
+		return &c
+
+	case *FieldAssign:
+		c := *n
+		c.Field = copyIdent(n.Field)
+		c.Expr = copyExpr(n.Expr)
+		return &c
+
+	case *RecordUpdate:
+		c := *n
+		c.Record = copyIdent(n.Record)
+		c.Fields = copyFieldAssigns(n.Fields)
+		return &c
+
+	case *LetExpr:
+		c := *n
+		c.Decls = copyDecls(n.Decls)
+		c.Body = copyExpr(n.Body)
+		return &c
+
+	case *IfExpr:
+		c := *n
+		c.Cond = copyExpr(n.Cond)
+		c.ThenExpr = copyExpr(n.ThenExpr)
+		c.ElseExpr = copyExpr(n.ElseExpr)
+		return &c
+
+	case *CaseExpr:
+		c := *n
+		c.Expr = copyExpr(n.Expr)
+		if n.Branches != nil {
+			c.Branches = make([]*CaseBranch, len(n.Branches))
+			for i, b := range n.Branches {
+				c.Branches[i] = Copy(b).(*CaseBranch)
+			}
+		}
+		return &c
+
+	case *CaseBranch:
+		c := *n
+		c.Pattern = copyPattern(n.Pattern)
+		c.Expr = copyExpr(n.Expr)
+		return &c
+
+	case *Lambda:
+		c := *n
+		c.Args = copyPatterns(n.Args)
+		c.Expr = copyExpr(n.Expr)
+		return &c
+
+	case *ListLit:
+		c := *n
+		c.Elems = copyExprs(n.Elems)
+		return &c
+
+	case *UnaryOp:
+		return &UnaryOp{
+			Op:   copyIdent(n.Op),
+			Expr: copyExpr(n.Expr),
+		}
+
+	case *BinaryOp:
+		return &BinaryOp{
+			Op:  copyIdent(n.Op),
+			Lhs: copyExpr(n.Lhs),
+			Rhs: copyExpr(n.Rhs),
+		}
+
+	case *AccessorExpr:
+		return &AccessorExpr{copyIdent(n.Field)}
+
+	case *TupleCtor:
+		c := *n
+		return &c
+
+	case *ParensExpr:
+		c := *n
+		c.Expr = copyExpr(n.Expr)
+		return &c
+
+	case *BadExpr:
+		c := *n
+		return &c
+
+	default:
+		panic(fmt.Errorf("copy: unable to copy node of type %T", node))
+	}
+}
+
+func copyIdent(i *Ident) *Ident {
+	if i == nil {
+		return nil
+	}
+
+	c := *i
+	return &c
+}
+
+func copyIdents(idents []*Ident) []*Ident {
+	if idents == nil {
+		return nil
+	}
+
+	result := make([]*Ident, len(idents))
+	for i, ident := range idents {
+		result[i] = copyIdent(ident)
+	}
+	return result
+}
+
+func copyStrings(strs []string) []string {
+	if strs == nil {
+		return nil
+	}
+
+	result := make([]string, len(strs))
+	copy(result, strs)
+	return result
+}
+
+func copyExposedList(l ExposedList) ExposedList {
+	if l == nil {
+		return nil
+	}
+	return Copy(l).(ExposedList)
+}
+
+func copyExpr(e Expr) Expr {
+	if e == nil {
+		return nil
+	}
+	return Copy(e).(Expr)
+}
+
+func copyExprs(exprs []Expr) []Expr {
+	if exprs == nil {
+		return nil
+	}
+
+	result := make([]Expr, len(exprs))
+	for i, e := range exprs {
+		result[i] = copyExpr(e)
+	}
+	return result
+}
+
+func copyPattern(p Pattern) Pattern {
+	if p == nil {
+		return nil
+	}
+	return Copy(p).(Pattern)
+}
+
+func copyPatterns(patterns []Pattern) []Pattern {
+	if patterns == nil {
+		return nil
+	}
+
+	result := make([]Pattern, len(patterns))
+	for i, p := range patterns {
+		result[i] = copyPattern(p)
+	}
+	return result
+}
+
+func copyType(t Type) Type {
+	if t == nil {
+		return nil
+	}
+	return Copy(t).(Type)
+}
+
+func copyTypes(types []Type) []Type {
+	if types == nil {
+		return nil
+	}
+
+	result := make([]Type, len(types))
+	for i, t := range types {
+		result[i] = copyType(t)
+	}
+	return result
+}
+
+func copyDecls(decls []Decl) []Decl {
+	if decls == nil {
+		return nil
+	}
+
+	result := make([]Decl, len(decls))
+	for i, d := range decls {
+		if d != nil {
+			result[i] = Copy(d).(Decl)
+		}
+	}
+	return result
+}
+
+func copyFieldAssigns(fields []*FieldAssign) []*FieldAssign {
+	if fields == nil {
+		return nil
+	}
+
+	result := make([]*FieldAssign, len(fields))
+	for i, f := range fields {
+		result[i] = Copy(f).(*FieldAssign)
+	}
+	return result
+}
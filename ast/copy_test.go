@@ -0,0 +1,48 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopy(t *testing.T) {
+	require := require.New(t)
+
+	c := Copy(testFile)
+	require.Equal(testFile, c)
+
+	original := make(map[Node]bool)
+	WalkFunc(testFile, func(n Node) bool {
+		if n != nil {
+			original[n] = true
+		}
+		return true
+	})
+
+	WalkFunc(c, func(n Node) bool {
+		if n != nil {
+			require.False(original[n], "node %T is shared with the original", n)
+		}
+		return true
+	})
+
+	require.Nil(Copy(nil))
+}
+
+func TestCopySharesObjects(t *testing.T) {
+	require := require.New(t)
+
+	obj := NewObject("foo", Var, nil)
+	app := &FuncApp{
+		Func: &Ident{Name: "foo", Obj: obj},
+		Args: []Expr{&BasicLit{Type: Int, Value: "1", Decoded: int64(1)}},
+	}
+
+	c := Copy(app).(*FuncApp)
+	require.Equal(app, c)
+	require.True(c.Func.(*Ident).Obj == obj)
+
+	c.Args[0].(*BasicLit).Value = "2"
+	require.Equal("1", app.Args[0].(*BasicLit).Value)
+}
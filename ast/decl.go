@@ -28,7 +28,7 @@ type ClosedList struct {
 
 func (ClosedList) isExposedList()    {}
 func (l *ClosedList) Pos() token.Pos { return l.Lparen }
-func (l *ClosedList) End() token.Pos { return l.Rparen }
+func (l *ClosedList) End() token.Pos { return l.Rparen + 1 }
 
 // OpenList means all objects in a module are exposed.
 type OpenList struct {
@@ -38,7 +38,7 @@ type OpenList struct {
 
 func (OpenList) isExposedList()    {}
 func (l *OpenList) Pos() token.Pos { return l.Lparen }
-func (l *OpenList) End() token.Pos { return l.Rparen }
+func (l *OpenList) End() token.Pos { return l.Rparen + 1 }
 
 // ExposedIdent represents an identifier exposed by a module.
 type ExposedIdent interface {
@@ -171,7 +171,7 @@ type AliasDecl struct {
 
 func (d AliasDecl) isDecl()        {}
 func (d AliasDecl) Pos() token.Pos { return d.TypePos }
-func (d AliasDecl) End() token.Pos { return d.Type.Pos() }
+func (d AliasDecl) End() token.Pos { return d.Type.End() }
 
 // UnionDecl is a node representing an union type declaration. Contains
 // the name of the union type, the arguments and all the constructors for
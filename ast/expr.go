@@ -96,8 +96,12 @@ type BasicLit struct {
 	Position token.Pos
 	// Type of the literal.
 	Type BasicLitType
-	// Value of the literal.
+	// Value of the literal as it was written in the source code.
 	Value string
+	// Decoded is the value of the literal: an int64 for Int, a float64 for
+	// Float, a string for String, a rune for Char and a bool for Bool
+	// literals. It is nil if the literal could not be decoded.
+	Decoded interface{}
 }
 
 func (b *BasicLit) Pos() token.Pos { return b.Position }
@@ -139,6 +143,30 @@ func (t BasicLitType) String() string {
 	}
 }
 
+// ShaderExpr is a GLSL shader block. Shaders are opaque values, their
+// source code is never checked.
+type ShaderExpr struct {
+	// Lbracket is the position of the opening bracket.
+	Lbracket token.Pos
+	// Source is the source code of the shader, without the delimiters.
+	Source string
+	// Obj is the object of the opaque type of the shader.
+	Obj *Object
+}
+
+const (
+	// ShaderStart is the delimiter at the start of a shader block.
+	ShaderStart = "[glsl|"
+	// ShaderEnd is the delimiter at the end of a shader block.
+	ShaderEnd = "|]"
+)
+
+func (e *ShaderExpr) Pos() token.Pos { return e.Lbracket }
+func (e *ShaderExpr) End() token.Pos {
+	return e.Lbracket + token.Pos(len(ShaderStart)+len(e.Source)+len(ShaderEnd))
+}
+func (*ShaderExpr) isExpr() {}
+
 // TupleLit is a tuple literal.
 type TupleLit struct {
 	// Lparen is the position of the opening parenthesis.
@@ -151,7 +179,7 @@ type TupleLit struct {
 }
 
 func (l *TupleLit) Pos() token.Pos { return l.Lparen }
-func (l *TupleLit) End() token.Pos { return l.Rparen }
+func (l *TupleLit) End() token.Pos { return l.Rparen + 1 }
 func (*TupleLit) isExpr()          {}
 
 // FuncApp is a function application, that is, a function and its arguments.
@@ -177,7 +205,7 @@ type RecordLit struct {
 }
 
 func (e *RecordLit) Pos() token.Pos { return e.Lbrace }
-func (e *RecordLit) End() token.Pos { return e.Rbrace }
+func (e *RecordLit) End() token.Pos { return e.Rbrace + 1 }
 func (*RecordLit) isExpr()          {}
 
 // FieldAssign is an assignation to a field of a record.
@@ -209,7 +237,7 @@ type RecordUpdate struct {
 }
 
 func (e *RecordUpdate) Pos() token.Pos { return e.Lbrace }
-func (e *RecordUpdate) End() token.Pos { return e.Rbrace }
+func (e *RecordUpdate) End() token.Pos { return e.Rbrace + 1 }
 func (*RecordUpdate) isExpr()          {}
 
 // LetExpr is an expression that allows declarations to be used inside
@@ -293,7 +321,7 @@ type ListLit struct {
 }
 
 func (e *ListLit) Pos() token.Pos { return e.Lbracket }
-func (e *ListLit) End() token.Pos { return e.Rbracket }
+func (e *ListLit) End() token.Pos { return e.Rbracket + 1 }
 func (*ListLit) isExpr()          {}
 
 // UnaryOp is an expression representing an operator being applied to only one
@@ -346,7 +374,7 @@ type TupleCtor struct {
 }
 
 func (e *TupleCtor) Pos() token.Pos { return e.Lparen }
-func (e *TupleCtor) End() token.Pos { return e.Rparen }
+func (e *TupleCtor) End() token.Pos { return e.Rparen + 1 }
 func (e *TupleCtor) isExpr()        {}
 
 // Lambda is a lambda function expression.
@@ -377,7 +405,7 @@ type ParensExpr struct {
 }
 
 func (e *ParensExpr) Pos() token.Pos { return e.Lparen }
-func (e *ParensExpr) End() token.Pos { return e.Rparen }
+func (e *ParensExpr) End() token.Pos { return e.Rparen + 1 }
 func (*ParensExpr) isExpr()          {}
 
 // BadExpr is a malformed expression.
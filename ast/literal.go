@@ -0,0 +1,170 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DecodeLiteral returns the value of the literal of the given type with the
+// given source code. The value is an int64 for Int literals, a float64 for
+// Float literals, a string for String literals, a rune for Char literals
+// and a bool for Bool literals. An error is returned if the literal is
+// malformed or its value is out of range.
+func DecodeLiteral(typ BasicLitType, value string) (interface{}, error) {
+	var (
+		v   interface{}
+		err error
+	)
+
+	switch typ {
+	case Int:
+		v, err = decodeInt(value)
+	case Float:
+		v, err = decodeFloat(value)
+	case String:
+		v, err = decodeString(value)
+	case Char:
+		v, err = decodeChar(value)
+	case Bool:
+		switch value {
+		case "True":
+			v = true
+		case "False":
+			v = false
+		default:
+			err = fmt.Errorf("%q is not a valid %s literal", value, typ)
+		}
+	default:
+		err = fmt.Errorf("%q is not a valid %s literal", value, typ)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func decodeInt(value string) (int64, error) {
+	var (
+		n   int64
+		err error
+	)
+	if strings.HasPrefix(value, "0x") {
+		n, err = strconv.ParseInt(value[2:], 16, 64)
+	} else {
+		n, err = strconv.ParseInt(value, 10, 64)
+	}
+
+	if err != nil {
+		if e, ok := err.(*strconv.NumError); ok && e.Err == strconv.ErrRange {
+			return 0, fmt.Errorf("the integer %s is too big, integers must be between %d and %d", value, int64(math.MinInt64), int64(math.MaxInt64))
+		}
+		return 0, fmt.Errorf("%q is not a valid integer", value)
+	}
+	return n, nil
+}
+
+func decodeFloat(value string) (float64, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		if e, ok := err.(*strconv.NumError); ok && e.Err == strconv.ErrRange {
+			return 0, fmt.Errorf("the float %s is out of the range of 64-bit floating point numbers", value)
+		}
+		return 0, fmt.Errorf("%q is not a valid float", value)
+	}
+	return f, nil
+}
+
+func decodeString(value string) (string, error) {
+	var content string
+	switch {
+	case len(value) >= 6 && strings.HasPrefix(value, `"""`) && strings.HasSuffix(value, `"""`):
+		content = value[3 : len(value)-3]
+	case len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`):
+		content = value[1 : len(value)-1]
+	default:
+		return "", fmt.Errorf("%s is not a valid string", value)
+	}
+	return unescape(content)
+}
+
+func decodeChar(value string) (rune, error) {
+	if len(value) < 2 || value[0] != '\'' || value[len(value)-1] != '\'' {
+		return 0, fmt.Errorf("%s is not a valid character", value)
+	}
+
+	content, err := unescape(value[1 : len(value)-1])
+	if err != nil {
+		return 0, err
+	}
+
+	if utf8.RuneCountInString(content) != 1 {
+		return 0, fmt.Errorf("the character %s must contain exactly one character", value)
+	}
+
+	r, _ := utf8.DecodeRuneInString(content)
+	return r, nil
+}
+
+var escapes = map[byte]rune{
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+	'"':  '"',
+	'\'': '\'',
+	'\\': '\\',
+}
+
+// unescape replaces all the escape sequences in the given content of a string
+// or character literal with the runes they represent.
+func unescape(content string) (string, error) {
+	if strings.IndexByte(content, '\\') < 0 {
+		return content, nil
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < len(content); i++ {
+		if content[i] != '\\' {
+			buf.WriteByte(content[i])
+			continue
+		}
+
+		i++
+		if i >= len(content) {
+			return "", fmt.Errorf("unfinished escape sequence at the end of the literal")
+		}
+
+		if r, ok := escapes[content[i]]; ok {
+			buf.WriteRune(r)
+			continue
+		}
+
+		if content[i] != 'u' {
+			return "", fmt.Errorf("unknown escape sequence \\%c, the valid escape sequences are \\n, \\r, \\t, \\\", \\', \\\\ and \\u{...}", content[i])
+		}
+
+		end := strings.IndexByte(content[i:], '}')
+		if end < 0 || i+1 >= len(content) || content[i+1] != '{' {
+			return "", fmt.Errorf("unicode escape sequences must have the form \\u{1F600}")
+		}
+
+		hex := content[i+2 : i+end]
+		code, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) == 0 || len(hex) > 6 {
+			return "", fmt.Errorf("invalid unicode escape sequence \\u{%s}, it must contain between 1 and 6 hexadecimal digits", hex)
+		}
+
+		r := rune(code)
+		if r > utf8.MaxRune || (r >= 0xD800 && r <= 0xDFFF) {
+			return "", fmt.Errorf("the unicode escape sequence \\u{%s} is not a valid code point", hex)
+		}
+
+		buf.WriteRune(r)
+		i += end
+	}
+	return buf.String(), nil
+}
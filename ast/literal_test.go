@@ -0,0 +1,72 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeLiteral(t *testing.T) {
+	cases := []struct {
+		typ      BasicLitType
+		value    string
+		expected interface{}
+	}{
+		{Int, "42", int64(42)},
+		{Int, "0xFF", int64(255)},
+		{Int, "0x7FFFFFFFFFFFFFFF", int64(9223372036854775807)},
+		{Float, "3.1416", 3.1416},
+		{Float, "1e-3", 0.001},
+		{Float, "6.02E23", 6.02e23},
+		{String, `"hello"`, "hello"},
+		{String, `"a\"b\\c\n"`, "a\"b\\c\n"},
+		{String, `"\u{1F600} \u{41}"`, "\U0001F600 A"},
+		{String, "\"\"\"multi\n\\\"\"\" line\"\"\"", "multi\n\"\"\" line"},
+		{String, `""`, ""},
+		{Char, `'a'`, 'a'},
+		{Char, `'\''`, '\''},
+		{Char, `'\t'`, '\t'},
+		{Char, `'\u{1F600}'`, '\U0001F600'},
+		{Char, `'ñ'`, 'ñ'},
+		{Bool, "True", true},
+		{Bool, "False", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.value, func(t *testing.T) {
+			v, err := DecodeLiteral(c.typ, c.value)
+			require.NoError(t, err)
+			require.Equal(t, c.expected, v)
+		})
+	}
+}
+
+func TestDecodeLiteralErrors(t *testing.T) {
+	cases := []struct {
+		typ   BasicLitType
+		value string
+		err   string
+	}{
+		{Int, "9223372036854775808", "the integer 9223372036854775808 is too big"},
+		{Int, "0x10000000000000000", "the integer 0x10000000000000000 is too big"},
+		{Int, "0x", `"0x" is not a valid integer`},
+		{Float, "1e400", "the float 1e400 is out of the range"},
+		{String, `"\q"`, `unknown escape sequence \q`},
+		{String, `"\u41"`, "unicode escape sequences must have the form"},
+		{String, `"\u{}"`, `invalid unicode escape sequence \u{}`},
+		{String, `"\u{1234567}"`, `invalid unicode escape sequence \u{1234567}`},
+		{String, `"\u{110000}"`, `\u{110000} is not a valid code point`},
+		{String, `"\u{D800}"`, `\u{D800} is not a valid code point`},
+		{Char, `'ab'`, "must contain exactly one character"},
+		{Char, `''`, "must contain exactly one character"},
+		{Bool, "Yes", `"Yes" is not a valid Bool literal`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.value, func(t *testing.T) {
+			_, err := DecodeLiteral(c.typ, c.value)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), c.err)
+		})
+	}
+}
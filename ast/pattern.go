@@ -98,7 +98,7 @@ type TuplePattern struct {
 }
 
 func (p TuplePattern) Pos() token.Pos { return p.Lparen }
-func (p TuplePattern) End() token.Pos { return p.Rparen }
+func (p TuplePattern) End() token.Pos { return p.Rparen + 1 }
 func (TuplePattern) isPattern()       {}
 func (TuplePattern) isArgPattern()    {}
 
@@ -113,7 +113,7 @@ type RecordPattern struct {
 }
 
 func (p RecordPattern) Pos() token.Pos { return p.Lbrace }
-func (p RecordPattern) End() token.Pos { return p.Rbrace }
+func (p RecordPattern) End() token.Pos { return p.Rbrace + 1 }
 func (RecordPattern) isPattern()       {}
 func (RecordPattern) isArgPattern()    {}
 
@@ -128,5 +128,5 @@ type ListPattern struct {
 }
 
 func (p ListPattern) Pos() token.Pos { return p.Lbracket }
-func (p ListPattern) End() token.Pos { return p.Rbracket }
+func (p ListPattern) End() token.Pos { return p.Rbracket + 1 }
 func (ListPattern) isPattern()       {}
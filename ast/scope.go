@@ -113,19 +113,34 @@ func (s *NodeScope) Lookup(name string, kind ObjKind) *Object {
 	return nil
 }
 
+// Add adds the object to the scope, unless there is already an object with
+// the same name. The identifiers with the name of the object that could not
+// be resolved in this scope or in the descendant scopes that do not declare
+// the same name are resolved to it, so names can be referenced before they
+// are declared.
 func (s *NodeScope) Add(obj *Object) bool {
 	if obj := s.Objects[obj.Name]; obj != nil {
 		return false
 	}
 
+	s.resolveUnresolved(obj)
+	s.Objects[obj.Name] = obj
+	return true
+}
+
+func (s *NodeScope) resolveUnresolved(obj *Object) {
 	if nodes, ok := s.Unresolved[obj.Name]; ok {
 		for _, n := range nodes {
 			n.Obj = obj
 		}
 		delete(s.Unresolved, obj.Name)
 	}
-	s.Objects[obj.Name] = obj
-	return true
+
+	for _, child := range s.children {
+		if _, ok := child.Objects[obj.Name]; !ok {
+			child.resolveUnresolved(obj)
+		}
+	}
 }
 
 func (s *NodeScope) Resolve(name string, id *Ident, kind ObjKind) {
@@ -58,7 +58,7 @@ type RecordType struct {
 
 func (RecordType) isType()           {}
 func (t *RecordType) Pos() token.Pos { return t.Lbrace }
-func (t *RecordType) End() token.Pos { return t.Rbrace }
+func (t *RecordType) End() token.Pos { return t.Rbrace + 1 }
 
 // RecordField represents a field in a record type node.
 type RecordField struct {
@@ -85,4 +85,4 @@ type TupleType struct {
 
 func (TupleType) isType()          {}
 func (t TupleType) Pos() token.Pos { return t.Lparen }
-func (t TupleType) End() token.Pos { return t.Rparen }
+func (t TupleType) End() token.Pos { return t.Rparen + 1 }
@@ -194,7 +194,7 @@ func Walk(v Visitor, node Node) {
 		walkPatterns(v, node.Elems)
 
 	// Exprs
-	case *Ident, *BasicLit:
+	case *Ident, *BasicLit, *ShaderExpr:
 		// do nothing
 
 	case *SelectorExpr:
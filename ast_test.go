@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elm-tangram/tangram/token"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalFileUsesCache(t *testing.T) {
+	require := require.New(t)
+	wd, err := os.Getwd()
+	require.NoError(err)
+
+	root, err := ioutil.TempDir("", "elmc-ast")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+	require.NoError(copyDir(filepath.Join(wd, "parser", "_testdata", "valid_fullparse"), root))
+
+	path := filepath.Join(root, "src", "Main.elm")
+	first, err := marshalFile(path, false, 0)
+	require.NoError(err)
+
+	files, err := ioutil.ReadDir(filepath.Join(root, "elm-stuff", "tangram"))
+	require.NoError(err)
+	require.Len(files, 8)
+
+	_, pkg, _, err := parseFile(path, false, true, 0)
+	require.NoError(err)
+	require.Equal(token.NoPos, pkg.Modules["Dependency"].Module.Exposing.Pos(), "Dependency should be loaded from the cache")
+
+	second, err := marshalFile(path, false, 0)
+	require.NoError(err)
+	require.Equal(string(first), string(second))
+}
+
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, 0644)
+	})
+}
@@ -0,0 +1,367 @@
+// Package astjson implements a stable JSON encoding of the AST, so tools
+// written in other languages can work with the structure of Elm code.
+//
+// Every node is encoded as a JSON object with a "kind" member, which is the
+// name of its type in the ast package, and "start" and "end" members with
+// its positions. The rest of the members are the fields of the node, named
+// like them but starting with a lower case letter. Positions are objects with
+// the byte "offset" and, if the source code is available, the "line" and
+// "column", or null for the nodes that are not in the source code, such as
+// the default imports. Identifiers resolved to an object have an "obj" member that
+// references the declaration of the object, if it is part of the encoded
+// node, or the module it comes from.
+package astjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/source"
+	"github.com/elm-tangram/tangram/token"
+)
+
+// kinds contains the types of all the nodes that can be encoded by their
+// kind.
+var kinds = make(map[string]reflect.Type)
+
+func init() {
+	for _, n := range []ast.Node{
+		(*ast.Module)(nil),
+		(*ast.ModuleDecl)(nil),
+		(*ast.ImportDecl)(nil),
+		(*ast.ClosedList)(nil),
+		(*ast.OpenList)(nil),
+		(*ast.ExposedVar)(nil),
+		(*ast.ExposedUnion)(nil),
+		(*ast.InfixDecl)(nil),
+		(*ast.AliasDecl)(nil),
+		(*ast.UnionDecl)(nil),
+		(*ast.Constructor)(nil),
+		(*ast.DestructuringAssignment)(nil),
+		(*ast.Definition)(nil),
+		(*ast.TypeAnnotation)(nil),
+		(*ast.NamedType)(nil),
+		(*ast.VarType)(nil),
+		(*ast.FuncType)(nil),
+		(*ast.RecordType)(nil),
+		(*ast.RecordField)(nil),
+		(*ast.TupleType)(nil),
+		(*ast.VarPattern)(nil),
+		(*ast.AnythingPattern)(nil),
+		(*ast.LiteralPattern)(nil),
+		(*ast.AliasPattern)(nil),
+		(*ast.CtorPattern)(nil),
+		(*ast.TuplePattern)(nil),
+		(*ast.RecordPattern)(nil),
+		(*ast.ListPattern)(nil),
+		(*ast.Ident)(nil),
+		(*ast.SelectorExpr)(nil),
+		(*ast.BasicLit)(nil),
+		(*ast.ShaderExpr)(nil),
+		(*ast.TupleLit)(nil),
+		(*ast.FuncApp)(nil),
+		(*ast.RecordLit)(nil),
+		(*ast.FieldAssign)(nil),
+		(*ast.RecordUpdate)(nil),
+		(*ast.LetExpr)(nil),
+		(*ast.IfExpr)(nil),
+		(*ast.CaseExpr)(nil),
+		(*ast.CaseBranch)(nil),
+		(*ast.ListLit)(nil),
+		(*ast.UnaryOp)(nil),
+		(*ast.BinaryOp)(nil),
+		(*ast.AccessorExpr)(nil),
+		(*ast.TupleCtor)(nil),
+		(*ast.Lambda)(nil),
+		(*ast.ParensExpr)(nil),
+		(*ast.BadExpr)(nil),
+	} {
+		typ := reflect.TypeOf(n).Elem()
+		kinds[typ.Name()] = typ
+	}
+}
+
+var (
+	nodeType        = reflect.TypeOf((*ast.Node)(nil)).Elem()
+	posType         = reflect.TypeOf(token.NoPos)
+	objectType      = reflect.TypeOf((*ast.Object)(nil))
+	litType         = reflect.TypeOf(ast.Error)
+	assocType       = reflect.TypeOf(ast.NonAssoc)
+	scopeType       = reflect.TypeOf((*ast.ModuleScope)(nil))
+	tokensType      = reflect.TypeOf([]*token.Token(nil))
+	emptyInterface  = reflect.TypeOf((*interface{})(nil)).Elem()
+	assocStrings    = []string{"none", "left", "right"}
+	litTypeStrings  = []string{"error", "Int", "Float", "String", "Bool", "Char"}
+	objKindsByNames = make(map[string]ast.ObjKind)
+)
+
+func init() {
+	for k := ast.Bad; k <= ast.NativeMod; k++ {
+		objKindsByNames[k.String()] = k
+	}
+}
+
+// isSkipped reports whether the field of a node is not encoded.
+func isSkipped(f reflect.StructField) bool {
+	return f.Type == scopeType || f.Type == tokensType
+}
+
+// isNode reports whether the values of the given type are nodes.
+func isNode(typ reflect.Type) bool {
+	return typ.Implements(nodeType)
+}
+
+// fieldName returns the name of the JSON member of the given field.
+func fieldName(f reflect.StructField) string {
+	r, n := utf8.DecodeRuneInString(f.Name)
+	return string(unicode.ToLower(r)) + f.Name[n:]
+}
+
+// kindOf returns the kind of the given node.
+func kindOf(n ast.Node) string {
+	return reflect.TypeOf(n).Elem().Name()
+}
+
+// isNil reports whether the node is nil or a typed nil.
+func isNil(n ast.Node) bool {
+	if n == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(n)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// Marshal returns the JSON encoding of the given node. If src is not nil, the
+// positions will contain the line and column in it. If pkg is not nil, it is
+// used to find the module of the objects declared outside of the node.
+func Marshal(node ast.Node, src *source.Source, pkg *ast.Package) ([]byte, error) {
+	e := &encoder{src: src, pkg: pkg, local: make(map[ast.Node]bool)}
+	ast.Apply(node, func(c *ast.Cursor) bool {
+		if c.Node() != nil {
+			e.local[c.Node()] = true
+		}
+		return true
+	}, nil)
+
+	v, err := e.node(node)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// member is a member of a JSON object.
+type member struct {
+	key   string
+	value interface{}
+}
+
+// object is a JSON object whose members are encoded in order.
+type object []member
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(m.key)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type encoder struct {
+	src     *source.Source
+	pkg     *ast.Package
+	local   map[ast.Node]bool
+	modules map[*ast.Object]string
+}
+
+func (e *encoder) node(n ast.Node) (interface{}, error) {
+	if isNil(n) {
+		return nil, nil
+	}
+
+	kind := kindOf(n)
+	if _, ok := kinds[kind]; !ok {
+		return nil, fmt.Errorf("astjson: unable to encode node of type %T", n)
+	}
+
+	start, err := e.pos(n.Pos())
+	if err != nil {
+		return nil, err
+	}
+
+	// the end of a node that is not in the source code is not either
+	var end *position
+	if start != nil {
+		end, err = e.pos(n.End())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := object{{"kind", kind}, {"start", start}, {"end", end}}
+	v := reflect.ValueOf(n).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if isSkipped(f) {
+			continue
+		}
+
+		value, err := e.value(v.Field(i))
+		if err != nil {
+			return nil, fmt.Errorf("astjson: can't encode field %s of %s: %s", f.Name, kind, err)
+		}
+		result = append(result, member{fieldName(f), value})
+	}
+	return result, nil
+}
+
+func (e *encoder) value(v reflect.Value) (interface{}, error) {
+	switch typ := v.Type(); {
+	case typ == posType:
+		return e.pos(token.Pos(v.Int()))
+	case typ == objectType:
+		return e.object(v.Interface().(*ast.Object))
+	case typ == litType:
+		return ast.BasicLitType(v.Uint()).String(), nil
+	case typ == assocType:
+		if int(v.Uint()) >= len(assocStrings) {
+			return nil, fmt.Errorf("invalid associativity %d", v.Uint())
+		}
+		return assocStrings[v.Uint()], nil
+	case typ == emptyInterface:
+		if r, ok := v.Interface().(rune); ok {
+			return string(r), nil
+		}
+		return v.Interface(), nil
+	case isNode(typ):
+		if v.IsNil() {
+			return nil, nil
+		}
+		return e.node(v.Interface().(ast.Node))
+	case typ.Kind() == reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+
+		elems := make([]interface{}, v.Len())
+		for i := range elems {
+			elem, err := e.value(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = elem
+		}
+		return elems, nil
+	case typ.Kind() == reflect.String, typ.Kind() == reflect.Int, typ.Kind() == reflect.Bool:
+		return v.Interface(), nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", typ)
+	}
+}
+
+// position is the JSON representation of a position.
+type position struct {
+	Offset token.Pos `json:"offset"`
+	Line   int       `json:"line,omitempty"`
+	Column int       `json:"column,omitempty"`
+}
+
+// pos returns the JSON representation of a position, which is nil if the
+// position is NoPos, as in the nodes created by the parser that do not
+// appear in the source code. Note that it is also the offset of the first
+// byte of a file.
+func (e *encoder) pos(pos token.Pos) (*position, error) {
+	if !pos.IsValid() {
+		return nil, nil
+	}
+
+	p := &position{Offset: pos}
+	if e.src != nil {
+		lp, err := e.src.LinePos(pos)
+		if err != nil {
+			return nil, err
+		}
+		p.Line, p.Column = lp.Line, lp.Col
+	}
+	return p, nil
+}
+
+// objectRef is the JSON representation of a reference to an object.
+type objectRef struct {
+	Name   string   `json:"name"`
+	Kind   string   `json:"kind"`
+	Module string   `json:"module,omitempty"`
+	Decl   *declRef `json:"decl,omitempty"`
+}
+
+// declRef is the JSON representation of a reference to the node that
+// declares an object.
+type declRef struct {
+	Kind  string    `json:"kind"`
+	Start *position `json:"start"`
+}
+
+func (e *encoder) object(obj *ast.Object) (*objectRef, error) {
+	if obj == nil {
+		return nil, nil
+	}
+
+	ref := &objectRef{Name: obj.Name, Kind: obj.Kind.String()}
+	if !isNil(obj.Node) && e.local[obj.Node] {
+		start, err := e.pos(obj.Node.Pos())
+		if err != nil {
+			return nil, err
+		}
+		ref.Decl = &declRef{kindOf(obj.Node), start}
+	} else {
+		ref.Module = e.moduleOf(obj)
+	}
+	return ref, nil
+}
+
+// moduleOf returns the name of the module of the package that exposes the
+// given object, if any.
+func (e *encoder) moduleOf(obj *ast.Object) string {
+	if e.pkg == nil {
+		return ""
+	}
+
+	if e.modules == nil {
+		e.modules = make(map[*ast.Object]string)
+		for _, name := range e.pkg.Order {
+			mod := e.pkg.Modules[name]
+			if mod == nil || mod.Scope == nil {
+				continue
+			}
+
+			for _, o := range mod.Scope.Exposed {
+				e.modules[o] = name
+			}
+		}
+	}
+	return e.modules[obj]
+}
@@ -0,0 +1,161 @@
+package astjson
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/parser"
+	"github.com/elm-tangram/tangram/source"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalPackage(t *testing.T) {
+	require := require.New(t)
+
+	path, err := filepath.Abs(filepath.Join("..", "parser", "_testdata", "valid_fullparse", "src", "Main.elm"))
+	require.NoError(err)
+
+	pkg, err := parser.Parse(path, parser.FullParse|parser.SkipWarnings)
+	require.NoError(err)
+
+	f, err := os.Open(path)
+	require.NoError(err)
+	defer f.Close()
+	src, err := source.NewSource(path, f)
+	require.NoError(err)
+
+	mod := pkg.Modules["Main"]
+	data, err := Marshal(mod, src, pkg)
+	require.NoError(err)
+
+	var decoded map[string]interface{}
+	require.NoError(json.Unmarshal(data, &decoded))
+	require.Equal("Module", decoded["kind"])
+	require.Equal("Main", decoded["name"])
+
+	decls := decoded["decls"].([]interface{})
+	def := decls[0].(map[string]interface{})
+	require.Equal("Definition", def["kind"])
+	require.Equal(map[string]interface{}{
+		"offset": float64(mod.Decls[0].Pos()),
+		"line":   float64(7),
+		"column": float64(1),
+	}, def["start"])
+
+	// the default imports are not in the source code
+	imports := decoded["imports"].([]interface{})
+	basics := imports[0].(map[string]interface{})
+	require.Nil(basics["start"])
+	require.Nil(basics["end"])
+
+	// maybeStr ? "hello" ?: "hello world"
+	body := def["body"].(map[string]interface{})
+	require.Equal("BinaryOp", body["kind"])
+	op := body["op"].(map[string]interface{})
+	require.Equal(map[string]interface{}{
+		"name":   "?:",
+		"kind":   "variable",
+		"module": "Dependency",
+	}, op["obj"])
+
+	// the module of objects declared outside is lost when decoding
+	data, err = Marshal(mod, src, nil)
+	require.NoError(err)
+
+	node, err := Unmarshal(data)
+	require.NoError(err)
+	result := node.(*ast.Module)
+	require.Equal("Main", result.Name)
+	require.Len(result.Decls, 1)
+
+	again, err := Marshal(result, src, nil)
+	require.NoError(err)
+	require.JSONEq(string(data), string(again))
+}
+
+func TestMarshalLocalObjects(t *testing.T) {
+	require := require.New(t)
+
+	// \x -> f x 'a'
+	pattern := &ast.VarPattern{Name: ast.NewIdent("x", 1)}
+	obj := ast.NewObject("x", ast.Var, pattern)
+	pattern.Name.Obj = obj
+	lambda := &ast.Lambda{
+		Backslash: 0,
+		Args:      []ast.Pattern{pattern},
+		Arrow:     3,
+		Expr: &ast.FuncApp{
+			Func: &ast.Ident{NamePos: 6, Name: "f"},
+			Args: []ast.Expr{
+				&ast.Ident{NamePos: 8, Name: "x", Obj: obj},
+				&ast.BasicLit{Position: 10, Type: ast.Char, Value: "'a'", Decoded: 'a'},
+			},
+		},
+	}
+
+	data, err := Marshal(lambda, nil, nil)
+	require.NoError(err)
+	require.Contains(string(data), `"obj":{"name":"x","kind":"variable","decl":{"kind":"VarPattern","start":{"offset":1}}}`)
+	require.Contains(string(data), `"decoded":"a"`)
+
+	node, err := Unmarshal(data)
+	require.NoError(err)
+	result := node.(*ast.Lambda)
+
+	decl := result.Args[0].(*ast.VarPattern)
+	arg := result.Expr.(*ast.FuncApp).Args[0].(*ast.Ident)
+	require.NotNil(arg.Obj)
+	require.True(arg.Obj == decl.Name.Obj)
+	require.True(arg.Obj.Node == decl)
+	require.Equal('a', result.Expr.(*ast.FuncApp).Args[1].(*ast.BasicLit).Decoded)
+	require.Equal(lambda, result)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	cases := []struct {
+		input string
+		err   string
+	}{
+		{`{"kind":"Foo"}`, `unknown node kind "Foo"`},
+		{`[]`, "expecting a node"},
+		{`{"kind":"FuncApp","func":{"kind":"OpenList"}}`, "a node of kind OpenList is not valid here"},
+		{`{"kind":"InfixDecl","assoc":"up"}`, `invalid value "up"`},
+		{`{"kind":"Ident","name":"x","obj":{"name":"x","kind":"variable","decl":{"kind":"VarPattern","start":{"offset":3}}}}`, `can't find the declaration of object "x"`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			_, err := Unmarshal([]byte(c.input))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), c.err)
+		})
+	}
+}
+
+func TestSchema(t *testing.T) {
+	require := require.New(t)
+
+	data, err := Schema()
+	require.NoError(err)
+
+	var schema struct {
+		Definitions map[string]json.RawMessage `json:"definitions"`
+	}
+	require.NoError(json.Unmarshal(data, &schema))
+
+	for kind := range kinds {
+		require.Contains(schema.Definitions, kind)
+	}
+
+	for _, iface := range []string{"Node", "Expr", "Pattern", "Type", "Decl", "ExposedList", "ExposedIdent", "position", "object"} {
+		require.Contains(schema.Definitions, iface)
+	}
+
+	require.True(strings.Contains(string(schema.Definitions["Expr"]), `"#/definitions/FuncApp"`))
+	require.False(strings.Contains(string(schema.Definitions["Expr"]), `"#/definitions/VarPattern"`))
+}
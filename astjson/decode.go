@@ -0,0 +1,221 @@
+package astjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/token"
+)
+
+// Unmarshal decodes the JSON encoding of a node produced by Marshal. The
+// lines and columns of the positions are ignored, only the offsets are used.
+// Identifiers that referenced an object declared inside the node refer to
+// an object whose node is the decoded declaration, and identifiers that
+// referenced the same object share it. Objects declared outside the node
+// only have their name and kind.
+func Unmarshal(data []byte) (ast.Node, error) {
+	d := &decoder{
+		decls:   make(map[declKey]ast.Node),
+		objects: make(map[objectKey]*ast.Object),
+	}
+
+	n, err := d.node(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range d.pending {
+		obj, err := d.object(p.ref)
+		if err != nil {
+			return nil, err
+		}
+		p.field.Set(reflect.ValueOf(obj))
+	}
+	return n, nil
+}
+
+// declKey identifies a node that declares an object.
+type declKey struct {
+	kind   string
+	offset token.Pos
+}
+
+// objectKey identifies an object by its name, kind and either the module it
+// comes from or its declaration.
+type objectKey struct {
+	name, kind, module string
+	decl               declKey
+}
+
+// pendingObject is a field that references an object, which can't be set
+// until all the nodes have been decoded.
+type pendingObject struct {
+	field reflect.Value
+	ref   *objectRef
+}
+
+type decoder struct {
+	decls   map[declKey]ast.Node
+	objects map[objectKey]*ast.Object
+	pending []pendingObject
+}
+
+func isNull(data []byte) bool {
+	return len(data) == 0 || bytes.Equal(bytes.TrimSpace(data), []byte("null"))
+}
+
+func (d *decoder) node(data []byte) (ast.Node, error) {
+	if isNull(data) {
+		return nil, nil
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, fmt.Errorf("astjson: expecting a node: %s", err)
+	}
+
+	var kind string
+	if err := json.Unmarshal(members["kind"], &kind); err != nil {
+		return nil, fmt.Errorf("astjson: invalid node kind: %s", err)
+	}
+
+	typ, ok := kinds[kind]
+	if !ok {
+		return nil, fmt.Errorf("astjson: unknown node kind %q", kind)
+	}
+
+	v := reflect.New(typ)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		raw, ok := members[fieldName(f)]
+		if isSkipped(f) || !ok || isNull(raw) {
+			continue
+		}
+
+		if err := d.value(v.Elem().Field(i), raw); err != nil {
+			return nil, fmt.Errorf("astjson: can't decode field %s of %s: %s", f.Name, kind, err)
+		}
+	}
+
+	n := v.Interface().(ast.Node)
+	if lit, ok := n.(*ast.BasicLit); ok {
+		lit.Decoded, _ = ast.DecodeLiteral(lit.Type, lit.Value)
+	}
+
+	var start position
+	if err := json.Unmarshal(members["start"], &start); err == nil {
+		d.decls[declKey{kind, start.Offset}] = n
+	}
+	return n, nil
+}
+
+func (d *decoder) value(v reflect.Value, data []byte) error {
+	switch typ := v.Type(); {
+	case typ == posType:
+		var p position
+		if err := json.Unmarshal(data, &p); err != nil {
+			return err
+		}
+		v.SetInt(int64(p.Offset))
+	case typ == objectType:
+		var ref objectRef
+		if err := json.Unmarshal(data, &ref); err != nil {
+			return err
+		}
+		d.pending = append(d.pending, pendingObject{v, &ref})
+	case typ == litType:
+		return decodeEnum(v, data, litTypeStrings)
+	case typ == assocType:
+		return decodeEnum(v, data, assocStrings)
+	case typ == emptyInterface:
+		// the decoded value of literals is obtained from their source
+	case isNode(typ):
+		n, err := d.node(data)
+		if err != nil {
+			return err
+		}
+
+		if n == nil {
+			return nil
+		}
+
+		if !reflect.TypeOf(n).AssignableTo(typ) {
+			return fmt.Errorf("a node of kind %s is not valid here", kindOf(n))
+		}
+		v.Set(reflect.ValueOf(n))
+	case typ.Kind() == reflect.Slice:
+		var elems []json.RawMessage
+		if err := json.Unmarshal(data, &elems); err != nil {
+			return err
+		}
+
+		slice := reflect.MakeSlice(typ, len(elems), len(elems))
+		for i, elem := range elems {
+			if isNull(elem) {
+				continue
+			}
+
+			if err := d.value(slice.Index(i), elem); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case typ.Kind() == reflect.String, typ.Kind() == reflect.Int, typ.Kind() == reflect.Bool:
+		return json.Unmarshal(data, v.Addr().Interface())
+	default:
+		return fmt.Errorf("unsupported type %s", typ)
+	}
+	return nil
+}
+
+// decodeEnum sets v to the index of the string in data in the given values.
+func decodeEnum(v reflect.Value, data []byte, values []string) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	for i, value := range values {
+		if value == s {
+			v.SetUint(uint64(i))
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value %q", s)
+}
+
+// object returns the object the given reference refers to. References to
+// the same object always return the same one.
+func (d *decoder) object(ref *objectRef) (*ast.Object, error) {
+	key := objectKey{name: ref.Name, kind: ref.Kind, module: ref.Module}
+	if ref.Decl != nil {
+		key.decl = declKey{ref.Decl.Kind, token.NoPos}
+		if ref.Decl.Start != nil {
+			key.decl.offset = ref.Decl.Start.Offset
+		}
+	}
+
+	if obj, ok := d.objects[key]; ok {
+		return obj, nil
+	}
+
+	kind, ok := objKindsByNames[ref.Kind]
+	if !ok {
+		return nil, fmt.Errorf("astjson: invalid object kind %q", ref.Kind)
+	}
+
+	obj := ast.NewObject(ref.Name, kind, nil)
+	if ref.Decl != nil {
+		decl, ok := d.decls[key.decl]
+		if !ok {
+			return nil, fmt.Errorf("astjson: can't find the declaration of object %q", ref.Name)
+		}
+		obj.Node = decl
+	}
+
+	d.objects[key] = obj
+	return obj, nil
+}
@@ -0,0 +1,167 @@
+package astjson
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// Schema returns the JSON Schema of the encoding of a node.
+func Schema() ([]byte, error) {
+	s := &schemaBuilder{defs: map[string]interface{}{
+		"position": object{
+			{"type", "object"},
+			{"properties", object{
+				{"offset", object{{"type", "integer"}}},
+				{"line", object{{"type", "integer"}}},
+				{"column", object{{"type", "integer"}}},
+			}},
+			{"required", []string{"offset"}},
+		},
+		"object": object{
+			{"type", "object"},
+			{"properties", object{
+				{"name", object{{"type", "string"}}},
+				{"kind", enum(objKindNames())},
+				{"module", object{{"type", "string"}}},
+				{"decl", object{
+					{"type", "object"},
+					{"properties", object{
+						{"kind", object{{"type", "string"}}},
+						{"start", nullable(ref("position"))},
+					}},
+					{"required", []string{"kind", "start"}},
+				}},
+			}},
+			{"required", []string{"name", "kind"}},
+		},
+	}}
+
+	for _, name := range sortedKinds() {
+		s.kind(kinds[name])
+	}
+	s.iface(nodeType)
+
+	var defs object
+	for _, name := range s.names() {
+		defs = append(defs, member{name, s.defs[name]})
+	}
+
+	return json.Marshal(object{
+		{"$schema", "http://json-schema.org/draft-07/schema#"},
+		{"title", "Elm AST"},
+		{"$ref", "#/definitions/Node"},
+		{"definitions", defs},
+	})
+}
+
+type schemaBuilder struct {
+	defs map[string]interface{}
+}
+
+func (s *schemaBuilder) names() []string {
+	var names []string
+	for name := range s.defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedKinds() []string {
+	var names []string
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func objKindNames() []string {
+	var names []string
+	for name := range objKindsByNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func ref(name string) object {
+	return object{{"$ref", "#/definitions/" + name}}
+}
+
+func enum(values []string) object {
+	return object{{"type", "string"}, {"enum", values}}
+}
+
+func nullable(schema interface{}) object {
+	return object{{"oneOf", []interface{}{object{{"type", "null"}}, schema}}}
+}
+
+// kind adds the definition of the node kind with the given type.
+func (s *schemaBuilder) kind(typ reflect.Type) {
+	props := object{
+		{"kind", object{{"type", "string"}, {"enum", []string{typ.Name()}}}},
+		{"start", nullable(ref("position"))},
+		{"end", nullable(ref("position"))},
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if !isSkipped(f) {
+			props = append(props, member{fieldName(f), s.field(f.Type)})
+		}
+	}
+
+	s.defs[typ.Name()] = object{
+		{"type", "object"},
+		{"properties", props},
+		{"required", []string{"kind", "start", "end"}},
+	}
+}
+
+// iface adds the definition of the given node interface, which is any of the
+// kinds that implement it.
+func (s *schemaBuilder) iface(typ reflect.Type) {
+	if _, ok := s.defs[typ.Name()]; ok {
+		return
+	}
+
+	var options []interface{}
+	for _, name := range sortedKinds() {
+		if reflect.PtrTo(kinds[name]).Implements(typ) {
+			options = append(options, ref(name))
+		}
+	}
+	s.defs[typ.Name()] = object{{"anyOf", options}}
+}
+
+func (s *schemaBuilder) field(typ reflect.Type) interface{} {
+	switch {
+	case typ == posType:
+		return nullable(ref("position"))
+	case typ == objectType:
+		return nullable(ref("object"))
+	case typ == litType:
+		return enum(litTypeStrings)
+	case typ == assocType:
+		return enum(assocStrings)
+	case typ == emptyInterface:
+		return object{}
+	case isNode(typ) && typ.Kind() == reflect.Interface:
+		s.iface(typ)
+		return nullable(ref(typ.Name()))
+	case isNode(typ):
+		return nullable(ref(typ.Elem().Name()))
+	case typ.Kind() == reflect.Slice:
+		return nullable(object{{"type", "array"}, {"items", s.field(typ.Elem())}})
+	case typ.Kind() == reflect.String:
+		return object{{"type", "string"}}
+	case typ.Kind() == reflect.Int:
+		return object{{"type", "integer"}}
+	case typ.Kind() == reflect.Bool:
+		return object{{"type", "boolean"}}
+	default:
+		return object{}
+	}
+}
@@ -0,0 +1,347 @@
+// Package codegen generates Go code from the IR of an Elm program.
+//
+// All the modules of the program are generated in the same Go package, each
+// one in its own file, and the names of their declarations are prefixed with
+// the name of the module, so Maybe.withDefault becomes Maybe_withDefault.
+// Underscores in Elm names are doubled and the "$" of the names introduced by
+// the compiler becomes an underscore, so the names never clash.
+//
+// The data of the program is represented as follows:
+//
+//   - Every union type is a sealed interface, and each of its constructors is
+//     a struct implementing it with a field for each argument. Cases on
+//     union values are type switches.
+//   - Records are structs with a field for each field of the record. There
+//     is a struct for each set of field names used in the program, which is
+//     named after the record type alias declaring it, if there is exactly one.
+//     Accesses and updates of the fields are functions with a type switch on
+//     all the structs having the field.
+//   - Lists, tuples and functions are the ones defined in the runtime
+//     package.
+//
+// Fields of structs and arguments of functions are runtime values, until
+// type checking allows using more precise types.
+//
+// Top-level definitions that are lambdas are Go functions, and the rest are
+// functions returning their value, which is computed the first time it is
+// used, so the native values it needs are registered by then.
+//
+// The functions and statements generated from Elm code are preceded by line
+// directives with their position in the Elm source files, so panics, stack
+// traces and profiles refer to the Elm code.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/ir"
+	"github.com/elm-tangram/tangram/source"
+)
+
+// RuntimePath is the import path of the runtime package used by the
+// generated code.
+const RuntimePath = "github.com/elm-tangram/tangram/runtime"
+
+// SupportFile is the name of the file with the declarations shared by all
+// the modules, which are the structs of the records.
+const SupportFile = "program.go"
+
+// Generate returns the Go files of a program in a package with the given
+// name, indexed by their file name. pkg is the IR of the program, and src is
+// the resolved package it was desugared from, which has the declarations of
+// the record types. cm has the source files src was parsed from, which are
+// needed to generate the line directives. If it is nil, there are none.
+// Each module is generated in a file named after it with the extension
+// ".elm.go", and the records are in SupportFile.
+func Generate(name string, src *ast.Package, cm *source.CodeMap, pkg *ir.Package) (map[string][]byte, error) {
+	g := &generator{
+		funcs:   make(map[ir.Global]int),
+		defs:    make(map[ir.Global]bool),
+		records: newRecords(),
+		sources: make(map[string]*source.Source),
+	}
+
+	for _, modName := range pkg.Order {
+		for _, d := range pkg.Modules[modName].Defs {
+			global := ir.Global{Module: modName, Name: d.Name}
+			g.defs[global] = true
+			if fn, ok := d.Expr.(*ir.Lambda); ok {
+				g.funcs[global] = len(fn.Params)
+			}
+			g.records.collect(d.Expr)
+		}
+
+		if mod := src.Modules[modName]; mod != nil {
+			g.records.aliases(modName, mod)
+			if cm != nil && cm.Source(mod.Path) != nil {
+				g.sources[modName] = cm.Source(mod.Path)
+			}
+		}
+	}
+	g.records.name()
+
+	files := make(map[string][]byte)
+	for _, modName := range pkg.Order {
+		var buf bytes.Buffer
+		g.module(&buf, pkg.Modules[modName])
+		code, err := file(name, buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("codegen: module %s: %s", modName, err)
+		}
+		files[modName+".elm.go"] = code
+	}
+
+	var buf bytes.Buffer
+	g.records.declare(&buf)
+	code, err := file(name, buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("codegen: records: %s", err)
+	}
+	files[SupportFile] = code
+	return files, nil
+}
+
+type generator struct {
+	// funcs are the arities of the top-level definitions that are lambdas,
+	// which are generated as Go functions.
+	funcs map[ir.Global]int
+	// defs are the top-level definitions of the program. The globals not in
+	// it are native values.
+	defs    map[ir.Global]bool
+	records *records
+	// sources are the source files of the modules, taken from the code map
+	// they were parsed from.
+	sources map[string]*source.Source
+}
+
+// position returns the line and column of a position in the source file of
+// its module, and whether it is known.
+func (g *generator) position(pos ir.Pos) (source.LinePos, bool) {
+	src, ok := g.sources[pos.Module]
+	if !pos.IsValid() || !ok {
+		return source.LinePos{}, false
+	}
+
+	lp, err := src.LinePos(pos.Offset)
+	return lp, err == nil
+}
+
+// line returns the line directive making the Go code that follows it refer
+// to the given position, or an empty string if the position is not known.
+func (g *generator) line(pos ir.Pos) string {
+	lp, ok := g.position(pos)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("//line %s:%d:%d\n", g.sources[pos.Module].Path, lp.Line, lp.Col)
+}
+
+// file returns the formatted source code of a file of the package with the
+// given declarations, importing the runtime package if they use it.
+func file(pkgName string, decls []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by elmc. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkgName)
+	if bytes.Contains(decls, []byte("runtime.")) {
+		fmt.Fprintf(&buf, "import %q\n\n", RuntimePath)
+	}
+	buf.Write(decls)
+
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%s\n%s", err, buf.Bytes())
+	}
+	return code, nil
+}
+
+func (g *generator) module(buf *bytes.Buffer, mod *ir.Module) {
+	for _, u := range mod.Unions {
+		g.union(buf, u)
+	}
+
+	for _, d := range mod.Defs {
+		g.def(buf, mod.Name, d)
+	}
+}
+
+// union declares the interface of a union type and the structs of its
+// constructors.
+func (g *generator) union(buf *bytes.Buffer, u *ir.Union) {
+	name := unionName(u)
+	fmt.Fprintf(buf, "// %s is the union type %s.%s.\n", name, u.Module, u.Name)
+	fmt.Fprintf(buf, "type %s interface {\n\tis%s()\n}\n\n", name, name)
+	for _, c := range u.Ctors {
+		ctor := ctorName(c)
+		fmt.Fprintf(buf, "// %s is the constructor %s.%s.\n", ctor, u.Module, c.Name)
+		if c.Arity == 0 {
+			fmt.Fprintf(buf, "type %s struct{}\n\n", ctor)
+		} else {
+			fmt.Fprintf(buf, "type %s struct {\n", ctor)
+			for i := 0; i < c.Arity; i++ {
+				fmt.Fprintf(buf, "\tV%d runtime.Value\n", i)
+			}
+			fmt.Fprintf(buf, "}\n\n")
+		}
+		fmt.Fprintf(buf, "func (%s) is%s() {}\n\n", ctor, name)
+	}
+}
+
+// def declares a top-level definition, as a function if it is a lambda.
+// Otherwise it is a function returning its value, which is computed the
+// first time, so the values can refer to each other through functions,
+// which Go does not allow in the initialisation of variables.
+func (g *generator) def(buf *bytes.Buffer, module string, d *ir.Def) {
+	name := globalName(module, d.Name)
+	f := g.newFunc(module+"."+d.Name, d.Pos)
+	if fn, ok := d.Expr.(*ir.Lambda); ok {
+		buf.WriteString(g.line(d.Pos))
+		fmt.Fprintf(buf, "func %s(%s runtime.Value) runtime.Value {\n", name, strings.Join(f.params(fn), ", "))
+		f.body(fn, d, f.scope)
+		buf.Write(f.buf.Bytes())
+		fmt.Fprintf(buf, "}\n\n")
+		return
+	}
+
+	lazy := lazyName(module, d.Name)
+	fmt.Fprintf(buf, "var %s runtime.Lazy\n\n", lazy)
+	buf.WriteString(g.line(d.Pos))
+	fmt.Fprintf(buf, "func %s() runtime.Value {\n", name)
+	fmt.Fprintf(buf, "return %s.Get(%q, func() runtime.Value {\n", lazy, f.scope)
+	f.tail(d.Expr)
+	buf.Write(f.buf.Bytes())
+	fmt.Fprintf(buf, "})\n}\n\n")
+}
+
+// goName returns the Go identifier for an Elm name, or a name introduced by
+// the compiler.
+func goName(name string) string {
+	name = strings.Replace(name, "_", "__", -1)
+	return strings.Replace(name, "$", "_", -1)
+}
+
+// localName returns the Go identifier of a local variable, which is prefixed
+// by an underscore if it is a keyword or a predeclared identifier of Go.
+func localName(name string) string {
+	if name == "_" {
+		return name
+	}
+
+	if goReserved[name] {
+		return "_" + name
+	}
+	return goName(name)
+}
+
+var goReserved = make(map[string]bool)
+
+func init() {
+	names := []string{
+		// keywords
+		"break", "case", "chan", "const", "continue", "default", "defer",
+		"else", "fallthrough", "for", "func", "go", "goto", "if", "import",
+		"interface", "map", "package", "range", "return", "select",
+		"struct", "switch", "type", "var",
+		// predeclared identifiers
+		"append", "bool", "byte", "cap", "close", "complex", "complex64",
+		"complex128", "copy", "delete", "error", "false", "float32",
+		"float64", "imag", "int", "int8", "int16", "int32", "int64", "iota",
+		"len", "make", "new", "nil", "panic", "print", "println", "real",
+		"recover", "rune", "string", "true", "uint", "uint8", "uint16",
+		"uint32", "uint64", "uintptr",
+		// the package of the generated code
+		"runtime",
+	}
+	for _, n := range names {
+		goReserved[n] = true
+	}
+}
+
+// moduleName returns the prefix of the declarations of a module.
+func moduleName(module string) string {
+	parts := strings.Split(module, ".")
+	for i, p := range parts {
+		parts[i] = goName(p)
+	}
+	return strings.Join(parts, "_")
+}
+
+// globalName returns the Go identifier of a top-level definition.
+func globalName(module, name string) string {
+	r, _ := utf8.DecodeRuneInString(name)
+	if !unicode.IsLetter(r) {
+		return moduleName(module) + "_" + operatorName(name)
+	}
+	return moduleName(module) + "_" + goName(name)
+}
+
+var operatorNames = map[rune]string{
+	'+': "plus", '-': "minus", '*': "star", '/': "slash", '<': "lt",
+	'>': "gt", '=': "eq", '|': "bar", '&': "amp", '.': "dot",
+	':': "colon", '^': "caret", '%': "percent", '!': "bang", '?': "quest",
+	'~': "tilde", '@': "at", '#': "hash", '$': "dollar", '\\': "backslash",
+}
+
+// operatorName returns a name for an operator made of the names of its
+// symbols.
+func operatorName(op string) string {
+	name := "op"
+	for _, r := range op {
+		if n, ok := operatorNames[r]; ok {
+			name += "_" + n
+		} else {
+			name += "_" + strconv.Itoa(int(r))
+		}
+	}
+	return name
+}
+
+// lazyName returns the name of the variable with the value of a top-level
+// definition that is not a lambda.
+func lazyName(module, name string) string {
+	return "lazy_" + globalName(module, name)
+}
+
+// unionName returns the Go identifier of the interface of a union type.
+func unionName(u *ir.Union) string {
+	return moduleName(u.Module) + "_" + goName(u.Name)
+}
+
+// ctorName returns the Go identifier of the struct of a constructor, which
+// has a trailing underscore if it has the same name as its union type.
+func ctorName(c *ir.Constructor) string {
+	switch c {
+	case ir.Nil:
+		return "runtime.Nil"
+	case ir.Cons:
+		return "runtime.Cons"
+	}
+
+	name := moduleName(c.Union.Module) + "_" + goName(c.Name)
+	if c.Name == c.Union.Name {
+		name += "_"
+	}
+	return name
+}
+
+// fieldName returns the Go identifier of the field of a struct for a record
+// field.
+func fieldName(name string) string {
+	r, n := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[n:]
+}
+
+// sortedFields returns the names of the given fields, sorted.
+func sortedFields(fields []string) []string {
+	sorted := make([]string, len(fields))
+	copy(sorted, fields)
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,419 @@
+package codegen
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/elm-tangram/tangram/ir"
+	"github.com/elm-tangram/tangram/parser"
+
+	"github.com/stretchr/testify/require"
+)
+
+// generate generates the Go code of the program or library in the given
+// file of the source directory of the test data of the ir package, which has
+// the stubs of the core modules, in a package with the given name.
+func generate(t *testing.T, file, pkgName string, optimize bool) map[string][]byte {
+	path, err := filepath.Abs(filepath.Join("..", "ir", "_testdata", "src", file))
+	require.NoError(t, err)
+
+	src, cm, err := parser.ParseSources(path, parser.FullParse|parser.SkipWarnings, 0)
+	require.NoError(t, err)
+
+	pkg, err := ir.Desugar(src, cm)
+	require.NoError(t, err)
+
+	name := strings.TrimSuffix(file, ".elm")
+	if optimize {
+		pkg = ir.Optimize(pkg)
+	}
+	pkg = ir.Prune(ir.TailCalls(pkg), ir.Roots(src.Modules[name]))
+
+	files, err := Generate(pkgName, src, cm, pkg)
+	require.NoError(t, err)
+	return files
+}
+
+// generateFixture returns the optimised Go code of the library in the given
+// file of the test data.
+func generateFixture(t *testing.T, file string) map[string]string {
+	result := make(map[string]string)
+	for name, code := range generate(t, file, "elm", true) {
+		result[name] = string(code)
+	}
+	return result
+}
+
+// goBuild writes the files in a new directory of the package and builds
+// them with the go tool, returning the directory and the path of the binary
+// if they are a program.
+func goBuild(t *testing.T, files map[string][]byte) (string, string) {
+	dir, err := ioutil.TempDir(".", "_build")
+	require.NoError(t, err)
+
+	for name, code := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), code, 0644))
+	}
+
+	bin := filepath.Join(dir, "program")
+	cmd := exec.Command("go", "build", "-o", "program")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		require.FailNow(t, "the generated code does not build", "%s\n%s", err, out)
+	}
+	return dir, bin
+}
+
+// withoutLines removes the line directives from the code.
+func withoutLines(code string) string {
+	var result []string
+	for _, l := range strings.Split(code, "\n") {
+		if !strings.HasPrefix(l, "//line ") {
+			result = append(result, l)
+		}
+	}
+	return strings.Join(result, "\n")
+}
+
+// lines joins the given lines with new lines, indenting them with tabs as
+// gofmt does, so the expected code can be indented along with the tests.
+func lines(lines ...string) string {
+	for i, l := range lines {
+		trimmed := strings.TrimLeft(l, " ")
+		lines[i] = strings.Repeat("\t", (len(l)-len(trimmed))/4) + trimmed
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestGenerate(t *testing.T) {
+	files := generateFixture(t, "Codegen.elm")
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	require.Equal(t, []string{"Codegen.elm.go", "Maybe.elm.go", SupportFile}, names)
+
+	// the line directives are tested in TestLineDirectives
+	code := withoutLines(files["Codegen.elm.go"])
+	require.True(t, strings.HasPrefix(code, lines(
+		"// Code generated by elmc. DO NOT EDIT.",
+		"",
+		"package elm",
+		"",
+		`import "github.com/elm-tangram/tangram/runtime"`,
+	)))
+
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"union", lines(
+			"// Codegen_Shape is the union type Codegen.Shape.",
+			"type Codegen_Shape interface {",
+			"    isCodegen_Shape()",
+			"}",
+			"",
+			"// Codegen_Circle is the constructor Codegen.Circle.",
+			"type Codegen_Circle struct {",
+			"    V0 runtime.Value",
+			"}",
+			"",
+			"func (Codegen_Circle) isCodegen_Shape() {}",
+		)},
+		{"type switch", lines(
+			"func Codegen_area(shape runtime.Value) runtime.Value {",
+			"    switch shape_ := shape.(type) {",
+			"    case Codegen_Circle:",
+			"        r := shape_.V0",
+			`        return runtime.Apply(runtime.Native("Native.Basics", "mul"), runtime.Apply(runtime.Native("Native.Basics", "mul"), int64(3), r), r)`,
+			"    case Codegen_Rect:",
+			"        w := shape_.V0",
+			"        h := shape_.V1",
+			`        return runtime.Apply(runtime.Native("Native.Basics", "mul"), w, h)`,
+			"    default:",
+			`        panic("unreachable")`,
+			"    }",
+			"}",
+		)},
+		{"record alias", lines(
+			"func Codegen_person(name runtime.Value) runtime.Value {",
+			"    return Codegen_Person{Name: name, Age: int64(0)}",
+			"}",
+		)},
+		{"record update", lines(
+			"func Codegen_birthday(p runtime.Value) runtime.Value {",
+			`    return set_age(p, runtime.Apply(runtime.Native("Native.Basics", "add"), get_age(p), int64(1)))`,
+			"}",
+		)},
+		{"anonymous record", lines(
+			"var lazy_Codegen_origin runtime.Lazy",
+			"",
+			"func Codegen_origin() runtime.Value {",
+			`    return lazy_Codegen_origin.Get("Codegen.origin", func() runtime.Value {`,
+			"        return record_x_y{X: int64(0), Y: int64(0)}",
+			"    })",
+			"}",
+		)},
+		{"tuple", lines(
+			"func Codegen_swap(p_1 runtime.Value) runtime.Value {",
+			"    tuple_1 := p_1.(runtime.Tuple)",
+			"    a := tuple_1[0]",
+			"    b := tuple_1[1]",
+			"    return runtime.Tuple{b, a}",
+			"}",
+		)},
+		{"literal switch", lines(
+			"func Codegen_greeting(lang runtime.Value) runtime.Value {",
+			"    switch lang {",
+			`    case "es":`,
+			`        return "hola"`,
+			`    case "fr":`,
+			`        return "salut"`,
+			"    default:",
+			`        return "hello"`,
+			"    }",
+			"}",
+		)},
+		{"loop with closures", lines(
+			"func Codegen_thunks(n_loop, acc_loop runtime.Value) runtime.Value {",
+			"    for {",
+			"        n := n_loop",
+			"        acc := acc_loop",
+		)},
+		{"recur", lines(
+			`            n_loop, acc_loop = runtime.Apply(runtime.Native("Native.Basics", "sub"), n, int64(1)), runtime.Cons{Head: runtime.F1(func(_ runtime.Value) runtime.Value {`,
+			"                return n",
+			"            }), Tail: acc}",
+			"            continue",
+		)},
+		{"depth guard", lines(
+			"func Codegen_depth(n runtime.Value) runtime.Value {",
+			`    runtime.Enter("Codegen.depth")`,
+			"    defer runtime.Leave()",
+		)},
+		{"union of other module", lines(
+			"    switch m_ := m.(type) {",
+			"    case Maybe_Just:",
+			"        v_1 := m_.V0",
+			"        return v_1",
+			"    case Maybe_Nothing:",
+			"        return int64(0)",
+		)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require.Contains(t, code, c.expected)
+		})
+	}
+
+	require.Contains(t, files["Maybe.elm.go"], lines(
+		"// Maybe_Nothing is the constructor Maybe.Nothing.",
+		"type Maybe_Nothing struct{}",
+	))
+}
+
+func TestGenerateProgram(t *testing.T) {
+	code := generateFixture(t, "Codegen.elm")[SupportFile]
+	require.Contains(t, code, lines(
+		"// Codegen_Person is the record type Codegen.Person.",
+		"type Codegen_Person struct {",
+		"    Age  runtime.Value",
+		"    Name runtime.Value",
+		"}",
+		"",
+		"// record_x_y is a record with the fields x, y.",
+		"type record_x_y struct {",
+		"    X runtime.Value",
+		"    Y runtime.Value",
+		"}",
+		"",
+		"// get_age returns the field age of a record.",
+		"func get_age(r runtime.Value) runtime.Value {",
+		"    switch r := r.(type) {",
+		"    case Codegen_Person:",
+		"        return r.Age",
+		"    }",
+		`    return runtime.Crash("the record has no field age")`,
+		"}",
+	))
+}
+
+func TestLazyValues(t *testing.T) {
+	code := withoutLines(generateFixture(t, "Init.elm")["Init.elm.go"])
+
+	// values are computed when they are first used, so they can use the
+	// values used by the functions they call, whatever their order
+	require.Contains(t, code, lines(
+		"func Init_answer() runtime.Value {",
+		`    return lazy_Init_answer.Get("Init.answer", func() runtime.Value {`,
+		"        return Init_prepend(int64(2))",
+		"    })",
+		"}",
+	))
+	require.Contains(t, code, lines(
+		"    case true:",
+		"        return Init_base()",
+	))
+}
+
+func TestLineDirectives(t *testing.T) {
+	code := generateFixture(t, "Lines.elm")["Lines.elm.go"]
+	path, err := filepath.Abs(filepath.Join("..", "ir", "_testdata", "src", "Lines.elm"))
+	require.NoError(t, err)
+
+	require.Contains(t, code, lines(
+		"//line "+path+":5:1",
+		"func Lines_half(n runtime.Value) runtime.Value {",
+		"//line "+path+":6:8",
+		`    var cond_1 runtime.Value = runtime.Apply(runtime.Native("Native.Basics", "eq"), n, int64(0))`,
+		"//line "+path+":6:5",
+		"    switch cond_1 {",
+		"    case true:",
+		"//line "+path+":7:9",
+		`        return runtime.CrashAt("Lines", 7, 9, "zero")`,
+		"    default:",
+		"//line "+path+":9:11",
+		`        return runtime.Apply(runtime.Native("Native.Basics", "div"), n, int64(2))`,
+	))
+
+	// the message of Debug.crash is folded
+	require.Contains(t, code, lines(
+		"//line "+path+":19:13",
+		`        return runtime.CrashAt("Lines", 19, 13, "nothing in unwrap")`,
+	))
+
+	// expressions without a position use the one of their definition
+	require.Contains(t, code, lines(
+		"        v := m_.V0",
+		"//line "+path+":13:1",
+		"        return v",
+	))
+	require.Contains(t, code, lines(
+		"//line "+path+":25:9",
+		"    var both runtime.Value = runtime.Tuple{n, n}",
+		"//line "+path+":23:1",
+		"    return record_both_first{First: n, Both: both}",
+	))
+}
+
+func TestLineDirectivesOverlay(t *testing.T) {
+	require := require.New(t)
+	path, err := filepath.Abs(filepath.Join("..", "ir", "_testdata", "src", "Lines.elm"))
+	require.NoError(err)
+	content, err := ioutil.ReadFile(path)
+	require.NoError(err)
+
+	b, err := parser.NewBuilder(path, parser.FullParse|parser.SkipWarnings)
+	require.NoError(err)
+	defer b.Close()
+	b.Overlay().Set(path, "\n\n"+string(content), 1)
+
+	result, err := b.Build()
+	require.NoError(err)
+	pkg, err := ir.Desugar(result.Package, b.CodeMap())
+	require.NoError(err)
+	pkg = ir.Prune(pkg, ir.Roots(result.Package.Modules["Lines"]))
+
+	files, err := Generate("elm", result.Package, b.CodeMap(), pkg)
+	require.NoError(err)
+	require.Contains(string(files["Lines.elm.go"]), lines(
+		"//line "+path+":7:1",
+		"func Lines_half(n runtime.Value) runtime.Value {",
+	))
+}
+
+func TestNames(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("snake__case", goName("snake_case"))
+	require.Equal("x_1", goName("x$1"))
+	require.Equal("_go", localName("go"))
+	require.Equal("_runtime", localName("runtime"))
+	require.Equal("_", localName("_"))
+	require.Equal("Json_Decode_map", globalName("Json.Decode", "map"))
+	require.Equal("Basics_op_lt_bar", globalName("Basics", "<|"))
+
+	box := &ir.Union{Module: "Main", Name: "Box"}
+	require.Equal("Main_Box_", ctorName(&ir.Constructor{Union: box, Name: "Box"}))
+	require.Equal("Main_Empty", ctorName(&ir.Constructor{Union: box, Name: "Empty"}))
+	require.Equal("runtime.Cons", ctorName(ir.Cons))
+	require.Equal("FirstName", fieldName("firstName"))
+}
+
+func TestBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("building the generated code is slow")
+	}
+
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the go tool is not available")
+	}
+
+	for _, file := range []string{"Codegen.elm", "Init.elm", "Lines.elm", "Compile.elm"} {
+		for _, optimize := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s optimize=%v", file, optimize), func(t *testing.T) {
+				dir, _ := goBuild(t, generate(t, file, "elm", optimize))
+				os.RemoveAll(dir)
+			})
+		}
+	}
+}
+
+// nativeMain is a program registering the natives used by Compile.elm when
+// its package is initialised, after the one of the generated code, and
+// printing the value of its main.
+const nativeMain = `package main
+
+import (
+	"fmt"
+
+	"github.com/elm-tangram/tangram/runtime"
+)
+
+func init() {
+	runtime.Register("Native.Basics", "add", runtime.F2(func(a, b runtime.Value) runtime.Value {
+		return a.(int64) + b.(int64)
+	}))
+	runtime.Register("Native.Basics", "mul", runtime.F2(func(a, b runtime.Value) runtime.Value {
+		return a.(int64) * b.(int64)
+	}))
+}
+
+func main() {
+	fmt.Print(Compile_main())
+}
+`
+
+func TestRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("building the generated code is slow")
+	}
+
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the go tool is not available")
+	}
+
+	for _, optimize := range []bool{false, true} {
+		t.Run(fmt.Sprintf("optimize=%v", optimize), func(t *testing.T) {
+			files := generate(t, "Compile.elm", "main", optimize)
+			files["main.go"] = []byte(nativeMain)
+			dir, bin := goBuild(t, files)
+			defer os.RemoveAll(dir)
+
+			out, err := exec.Command(bin).CombinedOutput()
+			require.NoError(t, err, string(out))
+			require.Equal(t, "2115", string(out))
+		})
+	}
+}
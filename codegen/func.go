@@ -0,0 +1,513 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/elm-tangram/tangram/ir"
+)
+
+// maxFuncArity is the maximum arity of the functions of the runtime package
+// built from a Go function with one parameter for each argument.
+const maxFuncArity = 9
+
+// fn generates the statements of the body of a Go function. Expressions
+// which are statements in Go, such as lets and cases, are generated before
+// the expression using them.
+type fn struct {
+	g   *generator
+	buf bytes.Buffer
+	// scope is the qualified name of the top-level definition being
+	// generated.
+	scope string
+	// pos is the position of the innermost definition being generated,
+	// used for the expressions without a position of their own.
+	pos ir.Pos
+	// loop is the innermost loop, if any.
+	loop *loop
+	// n is the number of temporary variables declared.
+	n int
+}
+
+// loop is a loop being generated.
+type loop struct {
+	// vars are the Go variables assigned at each iteration.
+	vars []string
+}
+
+func (g *generator) newFunc(scope string, pos ir.Pos) *fn {
+	return &fn{g: g, scope: scope, pos: pos}
+}
+
+func (f *fn) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&f.buf, format, args...)
+}
+
+// line writes the line directive of a position, if it is known.
+func (f *fn) line(pos ir.Pos) {
+	f.buf.WriteString(f.g.line(pos))
+}
+
+// temp returns the name of a new temporary variable.
+func (f *fn) temp(hint string) string {
+	f.n++
+	return localName(hint + "$" + strconv.Itoa(f.n))
+}
+
+// params returns the Go parameters of a lambda. The parameters of a loop
+// whose body has closures are renamed, because the closures must capture
+// the values of the variables at the iteration they were built in.
+func (f *fn) params(lambda *ir.Lambda) []string {
+	params := make([]string, len(lambda.Params))
+	for i, p := range lambda.Params {
+		params[i] = localName(p)
+		if loop, ok := lambda.Body.(*ir.Loop); ok && p != "_" && hasClosures(loop.Body) {
+			params[i] = localName(p + "$loop")
+		}
+	}
+	return params
+}
+
+// body generates the body of a lambda, which is the definition given, if
+// any, with the given qualified name.
+func (f *fn) body(lambda *ir.Lambda, d *ir.Def, name string) {
+	if d != nil && d.Recursive {
+		f.printf("runtime.Enter(%q)\ndefer runtime.Leave()\n", name)
+	}
+
+	l, ok := lambda.Body.(*ir.Loop)
+	if !ok {
+		f.tail(lambda.Body)
+		return
+	}
+
+	params := f.params(lambda)
+	f.loop = &loop{vars: params}
+	f.printf("for {\n")
+	for i, p := range lambda.Params {
+		if p != "_" && params[i] != localName(p) && uses(l.Body, p) {
+			f.printf("%s := %s\n", localName(p), params[i])
+		}
+	}
+	f.tail(l.Body)
+	f.printf("}\n")
+}
+
+// tail generates the statements returning the value of the expression.
+func (f *fn) tail(e ir.Expr) {
+	switch e := e.(type) {
+	case *ir.Let:
+		f.let(e)
+		f.tail(e.Body)
+	case *ir.Case:
+		f.switchCase(e, f.tail)
+	case *ir.Recur:
+		args := f.values(e.Args)
+		f.line(e.Pos)
+		f.printf("%s = %s\n", strings.Join(f.loop.vars, ", "), strings.Join(args, ", "))
+		f.printf("continue\n")
+	default:
+		value := f.value(e)
+		f.line(f.exprPos(e))
+		f.printf("return %s\n", value)
+	}
+}
+
+// assign generates the statements assigning the value of the expression to
+// the variable.
+func (f *fn) assign(v string, e ir.Expr) {
+	switch e := e.(type) {
+	case *ir.Let:
+		f.let(e)
+		f.assign(v, e.Body)
+	case *ir.Case:
+		f.switchCase(e, func(body ir.Expr) { f.assign(v, body) })
+	default:
+		value := f.value(e)
+		f.line(f.exprPos(e))
+		f.printf("%s = %s\n", v, value)
+	}
+}
+
+// let declares the variables of the definitions of a let, as runtime values
+// so cases can use them as the subject of type assertions. The ones used
+// before they are defined, because they are recursive, are declared first.
+func (f *fn) let(e *ir.Let) {
+	declared := make(map[string]bool)
+	for i, d := range e.Defs {
+		for _, other := range e.Defs[:i+1] {
+			if uses(other.Expr, d.Name) {
+				declared[d.Name] = true
+				f.printf("var %s runtime.Value\n", localName(d.Name))
+				break
+			}
+		}
+	}
+
+	for i, d := range e.Defs {
+		pos := f.pos
+		f.pos = d.Pos
+		value := f.local(d)
+		f.pos = pos
+		f.line(d.Pos)
+		switch {
+		case declared[d.Name]:
+			f.printf("%s = %s\n", localName(d.Name), value)
+		case !uses(e.Body, d.Name) && !defsUse(e.Defs[i+1:], d.Name):
+			f.printf("_ = %s\n", value)
+		default:
+			f.printf("var %s runtime.Value = %s\n", localName(d.Name), value)
+		}
+	}
+}
+
+// local returns the value of a local definition.
+func (f *fn) local(d *ir.Def) string {
+	if lambda, ok := d.Expr.(*ir.Lambda); ok {
+		return f.lambda(lambda, d)
+	}
+	return f.value(d.Expr)
+}
+
+// switchCase generates a switch for a case expression, with the given
+// function generating the body of each branch.
+func (f *fn) switchCase(e *ir.Case, body func(ir.Expr)) {
+	f.line(e.Pos)
+	subject := localName(e.Subject)
+	if len(e.Branches) == 1 {
+		if p, ok := e.Branches[0].Pattern.(*ir.TuplePattern); ok {
+			b := e.Branches[0]
+			for _, name := range p.Vars {
+				if name != "_" && uses(b.Body, name) {
+					tuple := f.temp("tuple")
+					f.printf("%s := %s.(runtime.Tuple)\n", tuple, subject)
+					f.bind(tuple, p.Vars, b.Body, tupleElem)
+					break
+				}
+			}
+			body(b.Body)
+			return
+		}
+	}
+
+	if len(e.Branches) > 0 {
+		if _, ok := e.Branches[0].Pattern.(*ir.CtorPattern); ok {
+			f.typeSwitch(subject, e, body)
+			return
+		}
+	}
+
+	f.printf("switch %s {\n", subject)
+	for _, b := range e.Branches {
+		f.printf("case %s:\n", literal(b.Pattern.(*ir.LitPattern).Value))
+		body(b.Body)
+	}
+	f.defaultCase(e, body)
+	f.printf("}\n")
+}
+
+// typeSwitch generates a type switch for a case on constructors.
+func (f *fn) typeSwitch(subject string, e *ir.Case, body func(ir.Expr)) {
+	v := localName(e.Subject + "$")
+	var bound bool
+	for _, b := range e.Branches {
+		p := b.Pattern.(*ir.CtorPattern)
+		for _, name := range p.Vars {
+			bound = bound || (name != "_" && uses(b.Body, name))
+		}
+	}
+
+	if bound {
+		f.printf("switch %s := %s.(type) {\n", v, subject)
+	} else {
+		f.printf("switch %s.(type) {\n", subject)
+	}
+
+	for _, b := range e.Branches {
+		p := b.Pattern.(*ir.CtorPattern)
+		f.printf("case %s:\n", ctorName(p.Ctor))
+		field := ctorField
+		if p.Ctor == ir.Cons {
+			field = consField
+		}
+		f.bind(v, p.Vars, b.Body, field)
+		body(b.Body)
+	}
+	f.defaultCase(e, body)
+	f.printf("}\n")
+}
+
+func (f *fn) defaultCase(e *ir.Case, body func(ir.Expr)) {
+	f.printf("default:\n")
+	if e.Default != nil {
+		body(e.Default)
+	} else {
+		f.printf("panic(\"unreachable\")\n")
+	}
+}
+
+// bind declares the variables used in the body, which are the parts of the
+// value given by the field function.
+func (f *fn) bind(value string, vars []string, body ir.Expr, field func(string, int) string) {
+	for i, name := range vars {
+		if name != "_" && uses(body, name) {
+			f.printf("%s := %s\n", localName(name), field(value, i))
+		}
+	}
+}
+
+func tupleElem(value string, i int) string {
+	return fmt.Sprintf("%s[%d]", value, i)
+}
+
+func ctorField(value string, i int) string {
+	return fmt.Sprintf("%s.V%d", value, i)
+}
+
+func consField(value string, i int) string {
+	if i == 0 {
+		return value + ".Head"
+	}
+	return value + ".Tail"
+}
+
+// value returns a Go expression with the value of the expression.
+func (f *fn) value(expr ir.Expr) string {
+	switch e := expr.(type) {
+	case *ir.Local:
+		return localName(e.Name)
+	case *ir.Global:
+		return f.global(*e)
+	case *ir.Constructor:
+		return f.ctor(e)
+	case *ir.Lit:
+		return literal(e.Value)
+	case *ir.Shader:
+		return strconv.Quote(e.Source)
+	case *ir.Lambda:
+		return f.lambda(e, nil)
+	case *ir.App:
+		return f.app(e)
+	case *ir.Let:
+		f.let(e)
+		return f.value(e.Body)
+	case *ir.Case:
+		v := f.temp("case")
+		f.printf("var %s runtime.Value\n", v)
+		f.assign(v, e)
+		return v
+	case *ir.Tuple:
+		return fmt.Sprintf("runtime.Tuple{%s}", strings.Join(f.values(e.Elems), ", "))
+	case *ir.Record:
+		rec := f.g.records.lookup(recordFields(e.Fields))
+		fields := make([]string, len(e.Fields))
+		for i, field := range e.Fields {
+			fields[i] = fmt.Sprintf("%s: %s", fieldName(field.Name), f.value(field.Expr))
+		}
+		return fmt.Sprintf("%s{%s}", rec.Name, strings.Join(fields, ", "))
+	case *ir.Access:
+		return fmt.Sprintf("%s(%s)", getter(e.Field), f.value(e.Record))
+	case *ir.Update:
+		result := f.value(e.Record)
+		for _, field := range e.Fields {
+			result = fmt.Sprintf("%s(%s, %s)", setter(field.Name), result, f.value(field.Expr))
+		}
+		return result
+	case *ir.Crash:
+		return f.crash(e)
+	default:
+		panic(fmt.Errorf("codegen: unexpected expression of type %T", expr))
+	}
+}
+
+// crash returns the call crashing the program with the message of the
+// Crash, and its position if it is known.
+func (f *fn) crash(e *ir.Crash) string {
+	msg := strconv.Quote(e.Message)
+	if lit, ok := e.Arg.(*ir.Lit); ok {
+		msg = f.value(lit)
+	} else if e.Arg != nil {
+		msg = f.value(e.Arg) + ".(string)"
+	}
+
+	lp, ok := f.g.position(e.Pos)
+	if !ok {
+		return fmt.Sprintf("runtime.Crash(%s)", msg)
+	}
+	return fmt.Sprintf("runtime.CrashAt(%q, %d, %d, %s)", e.Pos.Module, lp.Line, lp.Col, msg)
+}
+
+func (f *fn) values(exprs []ir.Expr) []string {
+	values := make([]string, len(exprs))
+	for i, e := range exprs {
+		values[i] = f.value(e)
+	}
+	return values
+}
+
+// global returns the value of a global, which is a function built from the
+// Go function of the definition if it is a lambda, and otherwise the result
+// of the Go function returning its value.
+func (f *fn) global(g ir.Global) string {
+	if !f.g.defs[g] {
+		return fmt.Sprintf("runtime.Native(%q, %q)", g.Module, g.Name)
+	}
+
+	name := globalName(g.Module, g.Name)
+	arity, ok := f.g.funcs[g]
+	if !ok {
+		return name + "()"
+	}
+
+	if arity <= maxFuncArity {
+		return fmt.Sprintf("runtime.F%d(%s)", arity, name)
+	}
+
+	args := make([]string, arity)
+	for i := range args {
+		args[i] = fmt.Sprintf("args[%d]", i)
+	}
+	return fmt.Sprintf(
+		"runtime.Fn(%d, func(args []runtime.Value) runtime.Value {\nreturn %s(%s)\n})",
+		arity, name, strings.Join(args, ", "),
+	)
+}
+
+// ctor returns the value of a constructor, which is a function returning a
+// new value if it has arguments.
+func (f *fn) ctor(c *ir.Constructor) string {
+	if c.Arity == 0 {
+		return ctorName(c) + "{}"
+	}
+
+	params := make([]string, c.Arity)
+	for i := range params {
+		params[i] = fmt.Sprintf("v%d", i)
+	}
+	return fmt.Sprintf(
+		"runtime.F%d(func(%s runtime.Value) runtime.Value {\nreturn %s{%s}\n})",
+		c.Arity, strings.Join(params, ", "), ctorName(c), strings.Join(params, ", "),
+	)
+}
+
+// lambda returns a function built from a Go closure. Closures of more
+// parameters than the functions of the runtime package have return another
+// function with the rest of them.
+func (f *fn) lambda(lambda *ir.Lambda, d *ir.Def) string {
+	if len(lambda.Params) > maxFuncArity {
+		rest := &ir.Lambda{Params: lambda.Params[maxFuncArity:], Body: lambda.Body}
+		lambda = &ir.Lambda{Params: lambda.Params[:maxFuncArity], Body: rest}
+	}
+
+	inner := &fn{g: f.g, scope: f.scope, pos: f.pos, n: f.n}
+	name := f.scope
+	if d != nil {
+		name += "." + d.Name
+	}
+	inner.body(lambda, d, name)
+	f.n = inner.n
+	return fmt.Sprintf(
+		"runtime.F%d(func(%s runtime.Value) runtime.Value {\n%s})",
+		len(lambda.Params), strings.Join(inner.params(lambda), ", "), inner.buf.String(),
+	)
+}
+
+// app returns the result of an application, which calls the Go function of
+// the definition directly if it is a global lambda applied to all its
+// arguments.
+func (f *fn) app(e *ir.App) string {
+	switch fn := e.Func.(type) {
+	case *ir.Global:
+		if arity, ok := f.g.funcs[*fn]; ok && arity <= len(e.Args) {
+			args := f.values(e.Args)
+			call := fmt.Sprintf("%s(%s)", globalName(fn.Module, fn.Name), strings.Join(args[:arity], ", "))
+			if arity == len(args) {
+				return call
+			}
+			return fmt.Sprintf("runtime.Apply(%s, %s)", call, strings.Join(args[arity:], ", "))
+		}
+	case *ir.Constructor:
+		if fn.Arity == len(e.Args) {
+			if fn == ir.Cons {
+				args := f.values(e.Args)
+				return fmt.Sprintf("runtime.Cons{Head: %s, Tail: %s}", args[0], args[1])
+			}
+			return fmt.Sprintf("%s{%s}", ctorName(fn), strings.Join(f.values(e.Args), ", "))
+		}
+	}
+
+	fn := f.value(e.Func)
+	return fmt.Sprintf("runtime.Apply(%s, %s)", fn, strings.Join(f.values(e.Args), ", "))
+}
+
+// literal returns the Go constant of a literal, with the type of the Elm
+// value.
+func literal(v interface{}) string {
+	switch v := v.(type) {
+	case int64:
+		return fmt.Sprintf("int64(%d)", v)
+	case float64:
+		return fmt.Sprintf("float64(%s)", strconv.FormatFloat(v, 'g', -1, 64))
+	case string:
+		return strconv.Quote(v)
+	case rune:
+		return strconv.QuoteRune(v)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		panic(fmt.Errorf("codegen: unexpected literal of type %T", v))
+	}
+}
+
+// exprPos returns the position of the expression or, if it has none, the
+// one of the innermost definition being generated.
+func (f *fn) exprPos(e ir.Expr) ir.Pos {
+	switch e := e.(type) {
+	case *ir.App:
+		if e.Pos.IsValid() {
+			return e.Pos
+		}
+	case *ir.Crash:
+		if e.Pos.IsValid() {
+			return e.Pos
+		}
+	}
+	return f.pos
+}
+
+// uses reports whether the expression uses the local variable.
+func uses(e ir.Expr, name string) bool {
+	var found bool
+	ir.WalkFunc(e, func(e ir.Expr) bool {
+		switch e := e.(type) {
+		case *ir.Local:
+			found = found || e.Name == name
+		case *ir.Case:
+			found = found || e.Subject == name
+		}
+		return !found
+	})
+	return found
+}
+
+func defsUse(defs []*ir.Def, name string) bool {
+	for _, d := range defs {
+		if uses(d.Expr, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasClosures reports whether the expression has lambdas.
+func hasClosures(e ir.Expr) bool {
+	var found bool
+	ir.WalkFunc(e, func(e ir.Expr) bool {
+		_, ok := e.(*ir.Lambda)
+		found = found || ok
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,214 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/ir"
+)
+
+// record is the struct of a set of record fields.
+type record struct {
+	// Name of the struct.
+	Name string
+	// Fields are the names of the fields, sorted.
+	Fields []string
+	// aliases are the names of the record type aliases declaring the fields,
+	// qualified by their module.
+	aliases []string
+}
+
+// records are the structs of all the sets of fields of the records in the
+// program, indexed by the key of the set.
+type records struct {
+	byKey map[string]*record
+	// accessed and updated are the fields that are accessed and updated.
+	accessed map[string]bool
+	updated  map[string]bool
+}
+
+func newRecords() *records {
+	return &records{
+		byKey:    make(map[string]*record),
+		accessed: make(map[string]bool),
+		updated:  make(map[string]bool),
+	}
+}
+
+// key returns the key of the set of the sorted fields.
+func key(fields []string) string {
+	return strings.Join(fields, " ")
+}
+
+func (r *records) add(fields []string) *record {
+	fields = sortedFields(fields)
+	k := key(fields)
+	if rec, ok := r.byKey[k]; ok {
+		return rec
+	}
+
+	rec := &record{Fields: fields}
+	r.byKey[k] = rec
+	return rec
+}
+
+// lookup returns the struct of a set of fields, which must have been added.
+func (r *records) lookup(fields []string) *record {
+	return r.byKey[key(sortedFields(fields))]
+}
+
+// collect adds the records built, accessed and updated in the expression.
+func (r *records) collect(e ir.Expr) {
+	ir.WalkFunc(e, func(e ir.Expr) bool {
+		switch e := e.(type) {
+		case *ir.Record:
+			r.add(recordFields(e.Fields))
+		case *ir.Access:
+			r.accessed[e.Field] = true
+		case *ir.Update:
+			for _, f := range e.Fields {
+				r.updated[f.Name] = true
+			}
+		}
+		return true
+	})
+}
+
+// aliases adds the records declared by the record type aliases of the
+// module.
+func (r *records) aliases(module string, mod *ast.Module) {
+	for _, decl := range mod.Decls {
+		alias, ok := decl.(*ast.AliasDecl)
+		if !ok {
+			continue
+		}
+
+		t, ok := alias.Type.(*ast.RecordType)
+		if !ok {
+			continue
+		}
+
+		fields := make([]string, len(t.Fields))
+		for i, f := range t.Fields {
+			fields[i] = f.Name.Name
+		}
+
+		rec := r.add(fields)
+		rec.aliases = append(rec.aliases, module+"."+alias.Name.Name)
+	}
+}
+
+// name names the structs of the records after their alias if there is
+// exactly one, or after their fields otherwise.
+func (r *records) name() {
+	for _, rec := range r.byKey {
+		if len(rec.aliases) == 1 {
+			i := strings.LastIndexByte(rec.aliases[0], '.')
+			rec.Name = globalName(rec.aliases[0][:i], rec.aliases[0][i+1:])
+			continue
+		}
+
+		names := make([]string, len(rec.Fields))
+		for i, f := range rec.Fields {
+			names[i] = goName(f)
+		}
+		rec.Name = "record_" + strings.Join(names, "_")
+	}
+}
+
+// sorted returns the structs sorted by name.
+func (r *records) sorted() []*record {
+	var result []*record
+	for _, rec := range r.byKey {
+		result = append(result, rec)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// with returns the structs that have the field.
+func (r *records) with(field string) []*record {
+	var result []*record
+	for _, rec := range r.sorted() {
+		for _, f := range rec.Fields {
+			if f == field {
+				result = append(result, rec)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// declare declares the structs of the records, and the functions to access
+// and update the fields.
+func (r *records) declare(buf *bytes.Buffer) {
+	for _, rec := range r.sorted() {
+		if len(rec.aliases) == 1 {
+			fmt.Fprintf(buf, "// %s is the record type %s.\n", rec.Name, rec.aliases[0])
+		} else {
+			fmt.Fprintf(buf, "// %s is a record with the fields %s.\n", rec.Name, strings.Join(rec.Fields, ", "))
+		}
+
+		fmt.Fprintf(buf, "type %s struct {\n", rec.Name)
+		for _, f := range rec.Fields {
+			fmt.Fprintf(buf, "\t%s runtime.Value\n", fieldName(f))
+		}
+		fmt.Fprintf(buf, "}\n\n")
+	}
+
+	for _, f := range sortedKeys(r.accessed) {
+		fmt.Fprintf(buf, "// %s returns the field %s of a record.\n", getter(f), f)
+		fmt.Fprintf(buf, "func %s(r runtime.Value) runtime.Value {\n", getter(f))
+		fmt.Fprintf(buf, "switch r := r.(type) {\n")
+		for _, rec := range r.with(f) {
+			fmt.Fprintf(buf, "case %s:\nreturn r.%s\n", rec.Name, fieldName(f))
+		}
+		fmt.Fprintf(buf, "}\n")
+		fmt.Fprintf(buf, "return runtime.Crash(\"the record has no field %s\")\n}\n\n", f)
+	}
+
+	for _, f := range sortedKeys(r.updated) {
+		fmt.Fprintf(buf, "// %s returns a copy of a record with the field %s changed.\n", setter(f), f)
+		fmt.Fprintf(buf, "func %s(r, v runtime.Value) runtime.Value {\n", setter(f))
+		fmt.Fprintf(buf, "switch r := r.(type) {\n")
+		for _, rec := range r.with(f) {
+			fmt.Fprintf(buf, "case %s:\nr.%s = v\nreturn r\n", rec.Name, fieldName(f))
+		}
+		fmt.Fprintf(buf, "}\n")
+		fmt.Fprintf(buf, "return runtime.Crash(\"the record has no field %s\")\n}\n\n", f)
+	}
+}
+
+// getter returns the name of the function accessing a field.
+func getter(field string) string {
+	return "get_" + goName(field)
+}
+
+// setter returns the name of the function updating a field.
+func setter(field string) string {
+	return "set_" + goName(field)
+}
+
+func recordFields(fields []*ir.Field) []string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.Name
+	}
+	return names
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
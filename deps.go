@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/elm-tangram/tangram/package"
+	"github.com/elm-tangram/tangram/parser"
+)
+
+var depsCmd = &command{
+	name:  "deps",
+	usage: "[flags] [module ...]",
+	short: "Print and query the dependency graph of the given entry modules",
+	run:   runDeps,
+}
+
+func runDeps(cmd *command, args []string) error {
+	flags := newFlagSet(cmd)
+	dir := flags.String("dir", ".", "directory of the package")
+	format := flags.String("format", "text", "output format: text, dot or json")
+	of := flags.String("of", "", "print only the dependencies of the given module")
+	transitive := flags.Bool("transitive", false, "print also the indirect dependencies with -of")
+	dependents := flags.String("dependents", "", "print only the modules that depend on the given module")
+	levels := flags.Bool("levels", false, "print the modules grouped by depth level")
+	unreachable := flags.Bool("unreachable", false, "print the source modules not reachable from any entry module")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	entries := flags.Args()
+	if len(entries) == 0 {
+		entries = []string{"Main"}
+	}
+
+	p, err := pkg.Load(*dir)
+	if err != nil {
+		return err
+	}
+
+	modules := entries
+	if *unreachable {
+		sourceModules, err := p.SourceModules()
+		if err != nil {
+			return err
+		}
+		modules = append(modules, sourceModules...)
+	}
+
+	paths := make([]string, len(modules))
+	for i, m := range modules {
+		path, err := p.FindModule(m)
+		if err != nil {
+			return fmt.Errorf("elmc: can't find module %q: %s", m, err)
+		}
+
+		if paths[i], err = filepath.Abs(path); err != nil {
+			return err
+		}
+	}
+
+	g, err := parser.ParseGraph(paths, parser.SkipWarnings)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *of != "" && *transitive:
+		return printModules(os.Stdout, *format, g.TransitiveDependencies(*of))
+	case *of != "":
+		return printModules(os.Stdout, *format, g.Dependencies(*of))
+	case *dependents != "":
+		return printModules(os.Stdout, *format, g.Dependents(*dependents))
+	case *unreachable:
+		return printModules(os.Stdout, *format, g.Unreachable(entries...))
+	case *levels:
+		levels, err := g.Levels()
+		if err != nil {
+			return err
+		}
+		return printLevels(os.Stdout, *format, levels)
+	}
+
+	return printGraph(os.Stdout, *format, g)
+}
+
+func printGraph(w io.Writer, format string, g *pkg.Graph) error {
+	switch format {
+	case "dot":
+		return g.WriteDOT(w)
+	case "json":
+		return json.NewEncoder(w).Encode(g)
+	case "text":
+		for _, m := range g.Modules() {
+			line := m + ":"
+			if deps := g.Dependencies(m); len(deps) > 0 {
+				line += " " + strings.Join(deps, " ")
+			}
+
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("elmc: unknown format %q", format)
+	}
+}
+
+func printModules(w io.Writer, format string, modules []string) error {
+	switch format {
+	case "json":
+		if modules == nil {
+			modules = []string{}
+		}
+		return json.NewEncoder(w).Encode(modules)
+	case "text":
+		for _, m := range modules {
+			if _, err := fmt.Fprintln(w, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("elmc: format %q is not supported for a list of modules", format)
+	}
+}
+
+func printLevels(w io.Writer, format string, levels [][]string) error {
+	switch format {
+	case "json":
+		return json.NewEncoder(w).Encode(levels)
+	case "text":
+		for i, l := range levels {
+			if _, err := fmt.Fprintf(w, "%d: %s\n", i, strings.Join(l, " ")); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("elmc: format %q is not supported for levels", format)
+	}
+}
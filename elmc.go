@@ -1,11 +1,72 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 )
 
+// command is a subcommand of the elmc CLI.
+type command struct {
+	name  string
+	usage string
+	short string
+	run   func(cmd *command, args []string) error
+}
+
+var commands = []*command{
+	astCmd,
+	depsCmd,
+	genCmd,
+	irCmd,
+	tokensCmd,
+	watchCmd,
+}
+
 func main() {
-	fmt.Println("CLI not implemented yet")
-	os.Exit(1)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	for _, cmd := range commands {
+		if cmd.name == name {
+			if err := cmd.run(cmd, os.Args[2:]); err != nil {
+				if err != flag.ErrHelp {
+					fmt.Fprintln(os.Stderr, err)
+				}
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "elmc: unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: elmc <command> [arguments]\n\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.short)
+	}
+}
+
+// newFlagSet creates the flag set of a command, which prints the usage of the
+// command on error.
+func newFlagSet(cmd *command) *flag.FlagSet {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: elmc %s %s\n\n%s.\n\nFlags:\n", cmd.name, cmd.usage, cmd.short)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// maxErrorsFlag defines the flag with the maximum number of errors reported
+// by a command.
+func maxErrorsFlag(flags *flag.FlagSet) *int {
+	return flags.Int("max-errors", 0, "stop reporting errors after the given number of them, 0 for no limit")
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/elm-tangram/tangram/codegen"
+	"github.com/elm-tangram/tangram/ir"
+)
+
+var genCmd = &command{
+	name:  "gen",
+	usage: "[flags] file.elm",
+	short: "Generate the Go code of the program or library whose main module is the given file",
+	run:   runGen,
+}
+
+func runGen(cmd *command, args []string) error {
+	flags := newFlagSet(cmd)
+	out := flags.String("o", "", "directory to write the Go files to, instead of printing them")
+	pkgName := flags.String("pkg", "elm", "name of the Go package")
+	optimize := flags.Bool("optimize", false, "optimize the IR before generating the code")
+	maxErrors := maxErrorsFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	mod, pkg, cm, err := parseFile(flags.Arg(0), false, false, *maxErrors)
+	if err != nil {
+		return err
+	}
+
+	result, err := ir.Desugar(pkg, cm)
+	if err != nil {
+		return err
+	}
+
+	if *optimize {
+		result = ir.Optimize(result)
+	}
+
+	result = ir.TailCalls(result)
+	result = ir.Prune(result, ir.Roots(mod))
+
+	files, err := codegen.Generate(*pkgName, pkg, cm, result)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if *out != "" {
+		if err := os.MkdirAll(*out, 0755); err != nil {
+			return err
+		}
+	}
+
+	for i, name := range names {
+		if *out != "" {
+			if err := ioutil.WriteFile(filepath.Join(*out, name), files[name], 0644); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if i > 0 {
+			fmt.Fprintln(os.Stdout)
+		}
+		fmt.Fprintf(os.Stdout, "// %s\n\n", name)
+		os.Stdout.Write(files[name])
+	}
+	return nil
+}
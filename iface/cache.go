@@ -0,0 +1,88 @@
+package iface
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const ext = ".elmi"
+
+// Cache stores interfaces in a directory, each one in a file named after the
+// hash of the source code of its module. It is safe to use a Cache from
+// multiple goroutines.
+type Cache struct {
+	dir string
+}
+
+// NewCache creates a new cache that stores the interfaces in the given
+// directory, which will be created when the first interface is stored.
+func NewCache(dir string) *Cache {
+	return &Cache{dir}
+}
+
+func (c *Cache) path(hash string) string {
+	return filepath.Join(c.dir, hash+ext)
+}
+
+// Load returns the interface of the module with the given hash. If there is
+// no such interface, or if it was stored with another version of the format,
+// it returns nil.
+func (c *Cache) Load(hash string) (*Interface, error) {
+	data, err := ioutil.ReadFile(c.path(hash))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("iface: can't read interface: %s", err)
+	}
+
+	var i Interface
+	if err := json.Unmarshal(data, &i); err != nil {
+		return nil, fmt.Errorf("iface: can't decode interface: %s", err)
+	}
+
+	if i.Version != Version || i.Hash != hash {
+		return nil, nil
+	}
+
+	return &i, nil
+}
+
+// Store writes the given interface to the cache.
+func (c *Cache) Store(i *Interface) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("iface: can't create cache directory: %s", err)
+	}
+
+	data, err := json.Marshal(i)
+	if err != nil {
+		return fmt.Errorf("iface: can't encode interface: %s", err)
+	}
+
+	// write to a temporary file first, so a concurrent Load never reads a
+	// partially written interface
+	f, err := ioutil.TempFile(c.dir, i.Hash)
+	if err != nil {
+		return fmt.Errorf("iface: can't write interface: %s", err)
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("iface: can't write interface: %s", err)
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("iface: can't write interface: %s", err)
+	}
+
+	if err := os.Rename(f.Name(), c.path(i.Hash)); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("iface: can't write interface: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,486 @@
+// Package iface implements module interfaces, which are a summary of all
+// the information about a module that is needed to parse and resolve the
+// modules importing it: its imports, operator fixities and exposed objects
+// with their types.
+package iface
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/token"
+)
+
+// Version of the interface format. Interfaces with a different version are
+// never loaded.
+const Version = 2
+
+// Interface is the summary of a resolved module.
+type Interface struct {
+	// Version of the format of the interface.
+	Version int `json:"version"`
+	// Module is the name of the module.
+	Module string `json:"module"`
+	// Hash is the hash of the source code of the module.
+	Hash string `json:"hash"`
+	// Imports of the module, including the default ones.
+	Imports []*Import `json:"imports"`
+	// Fixities of the operators declared in the module.
+	Fixities []*Fixity `json:"fixities"`
+	// Types contains all the type declarations in the module that are
+	// exposed or used by the exposed objects.
+	Types []*TypeDecl `json:"types"`
+	// Exposed contains all the objects exposed by the module.
+	Exposed []*Object `json:"exposed"`
+}
+
+// Import is an import of a module.
+type Import struct {
+	// Module is the name of the imported module.
+	Module string `json:"module"`
+	// Ops are the operators exposed by the import.
+	Ops []string `json:"ops,omitempty"`
+}
+
+// Fixity is the fixity declaration of an operator.
+type Fixity struct {
+	Op         string            `json:"op"`
+	Assoc      ast.Associativity `json:"assoc"`
+	Precedence uint              `json:"precedence"`
+}
+
+// TypeDecl is a type declaration, either a type alias or an union type.
+type TypeDecl struct {
+	// Name of the type.
+	Name string `json:"name"`
+	// Args are the names of the type variables of the type.
+	Args []string `json:"args,omitempty"`
+	// Alias is the aliased type if the declaration is a type alias.
+	Alias *Type `json:"alias,omitempty"`
+	// Ctors are the constructors if the declaration is an union type.
+	Ctors []*Ctor `json:"ctors,omitempty"`
+}
+
+// Ctor is a constructor of an union type.
+type Ctor struct {
+	Name string  `json:"name"`
+	Args []*Type `json:"args,omitempty"`
+}
+
+// Object is an exposed object.
+type Object struct {
+	// Name of the object.
+	Name string `json:"name"`
+	// Kind of object, which can only be a type, a constructor or a variable.
+	Kind ast.ObjKind `json:"kind"`
+	// Type is the annotated type of a variable, if any.
+	Type *Type `json:"type,omitempty"`
+	// Decl is the name of the type declaration of a type or a constructor.
+	Decl string `json:"decl,omitempty"`
+}
+
+// TypeKind is the kind of a type.
+type TypeKind byte
+
+const (
+	// Named is a type with a name and optional arguments.
+	Named TypeKind = iota
+	// Var is a type variable.
+	Var
+	// Func is a function type, whose last argument is the return type.
+	Func
+	// Tuple is a tuple type.
+	Tuple
+	// Record is a record type.
+	Record
+)
+
+// Type is the structural representation of a type.
+type Type struct {
+	Kind TypeKind `json:"kind"`
+	// Name of a named type or a type variable.
+	Name string `json:"name,omitempty"`
+	// Args are the arguments of a named type, the arguments and return type
+	// of a function and the elements of a tuple.
+	Args []*Type `json:"args,omitempty"`
+	// Fields of a record.
+	Fields []*Field `json:"fields,omitempty"`
+}
+
+// Field is a field of a record type.
+type Field struct {
+	Name string `json:"name"`
+	Type *Type  `json:"type"`
+}
+
+// Hash returns the hash of the given source code, which is used to know if an
+// interface is still valid for a module.
+func Hash(src []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d\n", Version)
+	h.Write(src)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// New creates the interface of the given module, which must be resolved.
+func New(mod *ast.Module, hash string) *Interface {
+	i := &Interface{
+		Version: Version,
+		Module:  mod.Name,
+		Hash:    hash,
+	}
+
+	for _, imp := range mod.Imports {
+		i.Imports = append(i.Imports, newImport(imp))
+	}
+
+	var types = make(map[string]*TypeDecl)
+	for _, d := range mod.Decls {
+		switch d := d.(type) {
+		case *ast.InfixDecl:
+			n, _ := strconv.Atoi(d.Precedence.Value)
+			i.Fixities = append(i.Fixities, &Fixity{d.Op.Name, d.Assoc, uint(n)})
+		case *ast.AliasDecl:
+			types[d.Name.Name] = &TypeDecl{
+				Name:  d.Name.Name,
+				Args:  identNames(d.Args),
+				Alias: NewType(d.Type),
+			}
+		case *ast.UnionDecl:
+			decl := &TypeDecl{Name: d.Name.Name, Args: identNames(d.Args)}
+			for _, c := range d.Ctors {
+				decl.Ctors = append(decl.Ctors, &Ctor{c.Name.Name, newTypes(c.Args)})
+			}
+			types[d.Name.Name] = decl
+		}
+	}
+
+	var used = make(map[string]struct{})
+	for _, obj := range mod.Scope.Exposed {
+		o := &Object{Name: obj.Name, Kind: obj.Kind}
+		switch obj.Kind {
+		case ast.Var:
+			o.Type = annotation(mod, obj.Name)
+		case ast.Typ:
+			o.Decl = obj.Name
+		case ast.Ctor:
+			o.Decl = ctorDecl(mod, obj.Name)
+		default:
+			continue
+		}
+
+		if o.Decl != "" {
+			used[o.Decl] = struct{}{}
+		}
+		namedTypes(o.Type, func(name string) {
+			used[name] = struct{}{}
+		})
+		i.Exposed = append(i.Exposed, o)
+	}
+
+	sort.Slice(i.Exposed, func(a, b int) bool {
+		if i.Exposed[a].Name == i.Exposed[b].Name {
+			return i.Exposed[a].Kind < i.Exposed[b].Kind
+		}
+		return i.Exposed[a].Name < i.Exposed[b].Name
+	})
+
+	// the types used by the types included are included too
+	var pending []string
+	for name := range used {
+		pending = append(pending, name)
+	}
+	for len(pending) > 0 {
+		name := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		decl, ok := types[name]
+		if !ok {
+			continue
+		}
+
+		i.Types = append(i.Types, decl)
+		use := func(name string) {
+			if _, ok := used[name]; !ok {
+				used[name] = struct{}{}
+				pending = append(pending, name)
+			}
+		}
+		namedTypes(decl.Alias, use)
+		for _, c := range decl.Ctors {
+			for _, arg := range c.Args {
+				namedTypes(arg, use)
+			}
+		}
+	}
+
+	sort.Slice(i.Types, func(a, b int) bool {
+		return i.Types[a].Name < i.Types[b].Name
+	})
+
+	return i
+}
+
+// namedTypes calls fn with the names of all the named types in the type.
+func namedTypes(t *Type, fn func(string)) {
+	if t == nil {
+		return
+	}
+
+	if t.Kind == Named {
+		fn(t.Name)
+	}
+	for _, arg := range t.Args {
+		namedTypes(arg, fn)
+	}
+	for _, f := range t.Fields {
+		namedTypes(f.Type, fn)
+	}
+}
+
+func newImport(imp *ast.ImportDecl) *Import {
+	i := &Import{Module: imp.ModuleName()}
+	if imp.Exposing != nil {
+		ast.WalkFunc(imp.Exposing, func(n ast.Node) bool {
+			if v, ok := n.(*ast.ExposedVar); ok && v.IsOp() {
+				i.Ops = append(i.Ops, v.Name)
+			}
+			return true
+		})
+	}
+	return i
+}
+
+func identNames(idents []*ast.Ident) []string {
+	if len(idents) == 0 {
+		return nil
+	}
+
+	var names = make([]string, len(idents))
+	for i, id := range idents {
+		names[i] = id.Name
+	}
+	return names
+}
+
+func annotation(mod *ast.Module, name string) *Type {
+	for _, d := range mod.Decls {
+		if def, ok := d.(*ast.Definition); ok && def.Name.Name == name {
+			if def.Annotation != nil {
+				return NewType(def.Annotation.Type)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+func ctorDecl(mod *ast.Module, name string) string {
+	for _, d := range mod.Decls {
+		if union, ok := d.(*ast.UnionDecl); ok && union.LookupCtor(name) != nil {
+			return union.Name.Name
+		}
+	}
+	return ""
+}
+
+// NewType returns the structural representation of the given type.
+func NewType(typ ast.Type) *Type {
+	switch t := typ.(type) {
+	case *ast.NamedType:
+		return &Type{Kind: Named, Name: exprName(t.Name), Args: newTypes(t.Args)}
+	case *ast.VarType:
+		return &Type{Kind: Var, Name: t.Name}
+	case *ast.FuncType:
+		return &Type{Kind: Func, Args: append(newTypes(t.Args), NewType(t.Return))}
+	case *ast.TupleType:
+		return &Type{Kind: Tuple, Args: newTypes(t.Elems)}
+	case *ast.RecordType:
+		r := &Type{Kind: Record}
+		for _, f := range t.Fields {
+			r.Fields = append(r.Fields, &Field{f.Name.Name, NewType(f.Type)})
+		}
+		return r
+	default:
+		return nil
+	}
+}
+
+func newTypes(types []ast.Type) []*Type {
+	if len(types) == 0 {
+		return nil
+	}
+
+	var result = make([]*Type, len(types))
+	for i, t := range types {
+		result[i] = NewType(t)
+	}
+	return result
+}
+
+func exprName(expr ast.Expr) string {
+	if s, ok := expr.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return "_"
+}
+
+// AST creates a module from the interface, as if it had been parsed from the
+// file at the given path and resolved. It only contains the imports,
+// fixity declarations and the declarations of the exposed objects, whose
+// definitions have no body. All the nodes in the module have no position.
+func (i *Interface) AST(path string) *ast.Module {
+	mod := &ast.Module{
+		Name: i.Module,
+		Path: path,
+		Module: &ast.ModuleDecl{
+			Name:     nameExpr(i.Module),
+			Exposing: &ast.ClosedList{},
+		},
+	}
+
+	for _, imp := range i.Imports {
+		decl := &ast.ImportDecl{Module: nameExpr(imp.Module)}
+		if len(imp.Ops) > 0 {
+			list := &ast.ClosedList{}
+			for _, op := range imp.Ops {
+				list.Exposed = append(list.Exposed, &ast.ExposedVar{Ident: ast.NewIdent(op, token.NoPos)})
+			}
+			decl.Exposing = list
+		}
+		mod.Imports = append(mod.Imports, decl)
+	}
+
+	for _, f := range i.Fixities {
+		mod.Decls = append(mod.Decls, &ast.InfixDecl{
+			Assoc: f.Assoc,
+			Op:    ast.NewIdent(f.Op, token.NoPos),
+			Precedence: &ast.BasicLit{
+				Type:    ast.Int,
+				Value:   fmt.Sprint(f.Precedence),
+				Decoded: int64(f.Precedence),
+			},
+		})
+	}
+
+	var decls = make(map[string]ast.Decl)
+	for _, t := range i.Types {
+		var decl ast.Decl
+		if t.Alias != nil {
+			decl = &ast.AliasDecl{
+				Name: ast.NewIdent(t.Name, token.NoPos),
+				Args: newIdents(t.Args),
+				Type: t.Alias.AST(),
+			}
+		} else {
+			union := &ast.UnionDecl{
+				Name: ast.NewIdent(t.Name, token.NoPos),
+				Args: newIdents(t.Args),
+			}
+			for _, c := range t.Ctors {
+				union.Ctors = append(union.Ctors, &ast.Constructor{
+					Name: ast.NewIdent(c.Name, token.NoPos),
+					Args: astTypes(c.Args),
+				})
+			}
+			decl = union
+		}
+		decls[t.Name] = decl
+		mod.Decls = append(mod.Decls, decl)
+	}
+
+	mod.Scope = ast.NewModuleScope(mod)
+	exposing := mod.Module.Exposing.(*ast.ClosedList)
+	for _, o := range i.Exposed {
+		var node ast.Node
+		switch o.Kind {
+		case ast.Var:
+			def := &ast.Definition{Name: ast.NewIdent(o.Name, token.NoPos)}
+			if o.Type != nil {
+				def.Annotation = &ast.TypeAnnotation{
+					Name: ast.NewIdent(o.Name, token.NoPos),
+					Type: o.Type.AST(),
+				}
+			}
+			mod.Decls = append(mod.Decls, def)
+			node = def.Name
+		case ast.Typ:
+			node = decls[o.Decl]
+		case ast.Ctor:
+			if union, ok := decls[o.Decl].(*ast.UnionDecl); ok {
+				if ctor := union.LookupCtor(o.Name); ctor != nil {
+					node = ctor
+				}
+			}
+		}
+
+		obj := ast.NewObject(o.Name, o.Kind, node)
+		mod.Scope.Add(obj)
+		mod.Scope.Expose(obj)
+		if o.Kind != ast.Ctor {
+			exposing.Exposed = append(exposing.Exposed, &ast.ExposedVar{Ident: ast.NewIdent(o.Name, token.NoPos)})
+		}
+	}
+
+	return mod
+}
+
+// AST returns the type as an AST node with no positions.
+func (t *Type) AST() ast.Type {
+	switch t.Kind {
+	case Named:
+		return &ast.NamedType{Name: nameExpr(t.Name), Args: astTypes(t.Args)}
+	case Var:
+		return &ast.VarType{Ident: ast.NewIdent(t.Name, token.NoPos)}
+	case Func:
+		args := astTypes(t.Args)
+		return &ast.FuncType{Args: args[:len(args)-1], Return: args[len(args)-1]}
+	case Tuple:
+		return &ast.TupleType{Elems: astTypes(t.Args)}
+	case Record:
+		r := &ast.RecordType{}
+		for _, f := range t.Fields {
+			r.Fields = append(r.Fields, &ast.RecordField{
+				Name: ast.NewIdent(f.Name, token.NoPos),
+				Type: f.Type.AST(),
+			})
+		}
+		return r
+	default:
+		return nil
+	}
+}
+
+func astTypes(types []*Type) []ast.Type {
+	var result = make([]ast.Type, len(types))
+	for i, t := range types {
+		result[i] = t.AST()
+	}
+	return result
+}
+
+func newIdents(names []string) []*ast.Ident {
+	var idents = make([]*ast.Ident, len(names))
+	for i, n := range names {
+		idents[i] = ast.NewIdent(n, token.NoPos)
+	}
+	return idents
+}
+
+// nameExpr returns the expression for the given possibly qualified name.
+func nameExpr(name string) ast.Expr {
+	parts := strings.Split(name, ".")
+	if len(parts) == 1 {
+		return ast.NewIdent(name, token.NoPos)
+	}
+
+	var idents = make([]*ast.Ident, len(parts))
+	for i, p := range parts {
+		idents[i] = ast.NewIdent(p, token.NoPos)
+	}
+	return ast.NewSelectorExpr(idents...)
+}
@@ -0,0 +1,214 @@
+package iface
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/token"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ident(name string) *ast.Ident {
+	return ast.NewIdent(name, token.NoPos)
+}
+
+func named(name string, args ...ast.Type) *ast.NamedType {
+	return &ast.NamedType{Name: ident(name), Args: args}
+}
+
+// testModule creates the following module, already resolved:
+//
+//	module Foo exposing (Cmp(..), Point, compare, (<=>))
+//	import Bar exposing ((?))
+//	infixl 4 <=>
+//	type Cmp = Eq | Lt | Gt
+//	type alias Point = { x : Int, y : Int }
+//	compare : Point -> Point -> Cmp
+//	(<=>) : a -> a -> Bool
+//	hidden = 1
+func testModule() *ast.Module {
+	cmp := &ast.UnionDecl{
+		Name: ident("Cmp"),
+		Ctors: []*ast.Constructor{
+			{Name: ident("Eq")},
+			{Name: ident("Lt")},
+			{Name: ident("Gt")},
+		},
+	}
+	point := &ast.AliasDecl{
+		Name: ident("Point"),
+		Type: &ast.RecordType{Fields: []*ast.RecordField{
+			{Name: ident("x"), Type: named("Int")},
+			{Name: ident("y"), Type: named("Int")},
+		}},
+	}
+	compare := &ast.Definition{
+		Name: ident("compare"),
+		Annotation: &ast.TypeAnnotation{
+			Name: ident("compare"),
+			Type: &ast.FuncType{
+				Args:   []ast.Type{named("Point"), named("Point")},
+				Return: named("Cmp"),
+			},
+		},
+	}
+	op := &ast.Definition{
+		Name: ident("<=>"),
+		Annotation: &ast.TypeAnnotation{
+			Name: ident("<=>"),
+			Type: &ast.FuncType{
+				Args:   []ast.Type{&ast.VarType{Ident: ident("a")}, &ast.VarType{Ident: ident("a")}},
+				Return: named("Bool"),
+			},
+		},
+	}
+	hidden := &ast.Definition{Name: ident("hidden")}
+
+	mod := &ast.Module{
+		Name: "Foo",
+		Path: "Foo.elm",
+		Imports: []*ast.ImportDecl{
+			{
+				Module: ident("Bar"),
+				Exposing: &ast.ClosedList{Exposed: []ast.ExposedIdent{
+					&ast.ExposedVar{Ident: ident("?")},
+				}},
+			},
+		},
+		Decls: []ast.Decl{
+			&ast.InfixDecl{
+				Assoc:      ast.Left,
+				Op:         ident("<=>"),
+				Precedence: &ast.BasicLit{Type: ast.Int, Value: "4"},
+			},
+			cmp, point, compare, op, hidden,
+		},
+	}
+
+	mod.Scope = ast.NewModuleScope(mod)
+	objs := []*ast.Object{
+		ast.NewObject("Cmp", ast.Typ, cmp),
+		ast.NewObject("Eq", ast.Ctor, cmp.Ctors[0]),
+		ast.NewObject("Lt", ast.Ctor, cmp.Ctors[1]),
+		ast.NewObject("Gt", ast.Ctor, cmp.Ctors[2]),
+		ast.NewObject("Point", ast.Typ, point),
+		ast.NewObject("compare", ast.Var, compare.Name),
+		ast.NewObject("<=>", ast.Var, op.Name),
+	}
+	for _, obj := range objs {
+		mod.Scope.Add(obj)
+		mod.Scope.Expose(obj)
+	}
+	mod.Scope.Add(ast.NewObject("hidden", ast.Var, hidden.Name))
+	return mod
+}
+
+func TestInterfaceRoundTrip(t *testing.T) {
+	require := require.New(t)
+
+	i := New(testModule(), Hash([]byte("module Foo")))
+	data, err := json.Marshal(i)
+	require.NoError(err)
+
+	var decoded Interface
+	require.NoError(json.Unmarshal(data, &decoded))
+	require.Equal(i, &decoded)
+
+	require.Equal([]*Import{{"Bar", []string{"?"}}}, decoded.Imports)
+	require.Equal([]*Fixity{{"<=>", ast.Left, 4}}, decoded.Fixities)
+	require.Len(decoded.Types, 2)
+	require.Len(decoded.Exposed, 7)
+
+	mod := decoded.AST("Foo.elm")
+	require.Equal("Foo", mod.Name)
+	require.Equal("Foo", mod.Module.ModuleName())
+	require.Equal("Bar", mod.Imports[0].ModuleName())
+	require.Nil(mod.Scope.LookupExposed("hidden", ast.Var))
+
+	cmp := mod.Scope.LookupExposed("Cmp", ast.Typ)
+	require.NotNil(cmp)
+	union, ok := cmp.Node.(*ast.UnionDecl)
+	require.True(ok)
+	require.Len(union.Ctors, 3)
+
+	eq := mod.Scope.LookupExposed("Eq", ast.Ctor)
+	require.NotNil(eq)
+	require.Equal(union.Ctors[0], eq.Node)
+
+	compare := mod.Scope.LookupExposed("compare", ast.Var)
+	require.NotNil(compare)
+	require.Equal(
+		&Type{Kind: Func, Args: []*Type{
+			{Kind: Named, Name: "Point"},
+			{Kind: Named, Name: "Point"},
+			{Kind: Named, Name: "Cmp"},
+		}},
+		annotation(mod, "compare"),
+	)
+
+	point := mod.Scope.LookupExposed("Point", ast.Typ)
+	require.NotNil(point)
+	alias, ok := point.Node.(*ast.AliasDecl)
+	require.True(ok)
+	require.Equal(NewType(alias.Type), decoded.Types[1].Alias)
+}
+
+func TestInterfaceUsedTypes(t *testing.T) {
+	require := require.New(t)
+
+	// type alias Score = Int
+	// type Result = Won Score | Lost
+	// result : Result
+	mod := testModule()
+	score := &ast.AliasDecl{Name: ident("Score"), Type: named("Int")}
+	result := &ast.UnionDecl{
+		Name: ident("Result"),
+		Ctors: []*ast.Constructor{
+			{Name: ident("Won"), Args: []ast.Type{named("Score")}},
+			{Name: ident("Lost")},
+		},
+	}
+	def := &ast.Definition{
+		Name:       ident("result"),
+		Annotation: &ast.TypeAnnotation{Name: ident("result"), Type: named("Result")},
+	}
+	mod.Decls = append(mod.Decls, score, result, def)
+	obj := ast.NewObject("result", ast.Var, def.Name)
+	mod.Scope.Add(obj)
+	mod.Scope.Expose(obj)
+
+	i := New(mod, Hash([]byte("module Foo")))
+	var names []string
+	for _, t := range i.Types {
+		names = append(names, t.Name)
+	}
+	require.Equal([]string{"Cmp", "Point", "Result", "Score"}, names)
+}
+
+func TestCache(t *testing.T) {
+	require := require.New(t)
+	dir, err := ioutil.TempDir("", "iface")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cache := NewCache(dir)
+	hash := Hash([]byte("module Foo"))
+	i, err := cache.Load(hash)
+	require.NoError(err)
+	require.Nil(i)
+
+	expected := New(testModule(), hash)
+	require.NoError(cache.Store(expected))
+
+	i, err = cache.Load(hash)
+	require.NoError(err)
+	require.Equal(expected, i)
+
+	i, err = cache.Load(Hash([]byte("module Bar")))
+	require.NoError(err)
+	require.Nil(i)
+}
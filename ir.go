@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/elm-tangram/tangram/ir"
+)
+
+var irCmd = &command{
+	name:  "ir",
+	usage: "[flags] file.elm",
+	short: "Print the core intermediate representation of the given file",
+	run:   runIR,
+}
+
+func runIR(cmd *command, args []string) error {
+	flags := newFlagSet(cmd)
+	all := flags.Bool("all", false, "print all the modules of the package, not only the one in the file")
+	optimize := flags.Bool("optimize", false, "optimize the IR before printing it")
+	prune := flags.Bool("prune", false, "remove the definitions that are not reachable from main or, if there is no main, from the values exposed by the module")
+	maxErrors := maxErrorsFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	mod, pkg, cm, err := parseFile(flags.Arg(0), false, false, *maxErrors)
+	if err != nil {
+		return err
+	}
+
+	result, err := ir.Desugar(pkg, cm)
+	if err != nil {
+		return err
+	}
+
+	if *optimize {
+		result = ir.Optimize(result)
+	}
+
+	result = ir.TailCalls(result)
+
+	if *prune {
+		result = ir.Prune(result, ir.Roots(mod))
+	}
+
+	names := []string{mod.Name}
+	if *all {
+		names = result.Order
+	}
+
+	for i, name := range names {
+		m, ok := result.Modules[name]
+		if !ok {
+			// everything in the module was pruned
+			continue
+		}
+
+		if i > 0 {
+			fmt.Fprintln(os.Stdout)
+		}
+
+		if err := ir.Fprint(os.Stdout, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
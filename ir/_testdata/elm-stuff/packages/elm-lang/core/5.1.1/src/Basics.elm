@@ -0,0 +1,70 @@
+module Basics exposing
+  ( (+), (-), (*), (==), (<|), (|>), (<<), (>>), (++), (//), (&&)
+  , negate, not
+  )
+
+import Native.Basics
+
+infixl 6 +
+infixl 6 -
+infixl 7 *
+infix  4 ==
+infixr 0 <|
+infixl 0 |>
+infixr 9 <<
+infixl 9 >>
+infixr 5 ++
+infixl 7 //
+infixr 3 &&
+
+(+) : number -> number -> number
+(+) =
+    Native.Basics.add
+
+(-) : number -> number -> number
+(-) =
+    Native.Basics.sub
+
+(*) : number -> number -> number
+(*) =
+    Native.Basics.mul
+
+(==) : a -> a -> Bool
+(==) =
+    Native.Basics.eq
+
+negate : number -> number
+negate n =
+    0 - n
+
+(<|) : (a -> b) -> a -> b
+(<|) f x =
+    f x
+
+(|>) : a -> (a -> b) -> b
+(|>) x f =
+    f x
+
+(<<) : (b -> c) -> (a -> b) -> (a -> c)
+(<<) g f x =
+    g (f x)
+
+(>>) : (a -> b) -> (b -> c) -> (a -> c)
+(>>) f g x =
+    g (f x)
+
+(++) : appendable -> appendable -> appendable
+(++) =
+    Native.Basics.append
+
+(//) : Int -> Int -> Int
+(//) =
+    Native.Basics.div
+
+(&&) : Bool -> Bool -> Bool
+(&&) =
+    Native.Basics.and
+
+not : Bool -> Bool
+not =
+    Native.Basics.not
@@ -0,0 +1,10 @@
+module Debug exposing (..)
+
+import Native.Debug
+
+placeholder = "foo"
+
+
+crash : String -> a
+crash =
+    Native.Debug.crash
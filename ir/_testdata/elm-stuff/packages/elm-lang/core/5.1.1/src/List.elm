@@ -0,0 +1,9 @@
+module List exposing (..)
+
+import Native.List
+
+infixr 5 ::
+
+(::) : a -> List a -> List a
+(::) =
+    Native.List.cons
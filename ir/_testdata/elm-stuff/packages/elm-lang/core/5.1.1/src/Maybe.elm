@@ -0,0 +1,15 @@
+module Maybe exposing (..)
+
+type Maybe a
+    = Just a
+    | Nothing
+
+
+withDefault : Maybe a -> a -> a
+withDefault m default =
+    case m of
+        Just v ->
+            v
+        
+        Nothing ->
+            default
\ No newline at end of file
@@ -0,0 +1,5 @@
+module Result exposing (..)
+
+type Result a b
+    = Ok a
+    | Err b
\ No newline at end of file
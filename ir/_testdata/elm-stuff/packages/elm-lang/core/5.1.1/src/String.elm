@@ -0,0 +1,3 @@
+module String exposing (..)
+
+placeholder = "foo"
\ No newline at end of file
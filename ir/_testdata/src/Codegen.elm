@@ -0,0 +1,86 @@
+module Codegen exposing (..)
+
+
+type Shape
+    = Circle Int
+    | Rect Int Int
+
+
+type alias Person =
+    { name : String
+    , age : Int
+    }
+
+
+area : Shape -> Int
+area shape =
+    case shape of
+        Circle r ->
+            3 * r * r
+
+        Rect w h ->
+            w * h
+
+
+person : String -> Person
+person name =
+    { name = name, age = 0 }
+
+
+birthday : Person -> Person
+birthday p =
+    { p | age = p.age + 1 }
+
+
+origin : { x : Int, y : Int }
+origin =
+    { x = 0, y = 0 }
+
+
+swap : ( a, b ) -> ( b, a )
+swap ( a, b ) =
+    ( b, a )
+
+
+greeting : String -> String
+greeting lang =
+    case lang of
+        "es" ->
+            "hola"
+
+        "fr" ->
+            "salut"
+
+        _ ->
+            "hello"
+
+
+thunks : Int -> List (Int -> Int) -> List (Int -> Int)
+thunks n acc =
+    if n == 0 then
+        acc
+    else
+        thunks (n - 1) ((\_ -> n) :: acc)
+
+
+answer : Int
+answer =
+    area (Rect base 2)
+
+
+base : Int
+base =
+    21
+
+
+fromMaybe : Maybe Int -> Int
+fromMaybe m =
+    Maybe.withDefault m 0
+
+
+depth : Int -> Int
+depth n =
+    if n == 0 then
+        0
+    else
+        1 + depth (n - 1)
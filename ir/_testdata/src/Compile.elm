@@ -0,0 +1,41 @@
+module Compile exposing (..)
+
+
+swapped : Int -> Int -> Int
+swapped x y =
+    let
+        ( a, b ) =
+            ( x, y )
+
+        ( c, d ) =
+            ( b, a )
+    in
+        a * 10 + b + c * 1000 + d * 100
+
+
+unwrap : Int -> Int
+unwrap n =
+    let
+        m =
+            Just n
+    in
+        case m of
+            Just v ->
+                v
+
+            Nothing ->
+                0
+
+
+pair : ( Int, Int )
+pair =
+    ( swapped 1 2, unwrap 3 )
+
+
+main : Int
+main =
+    let
+        ( a, b ) =
+            pair
+    in
+        a + b
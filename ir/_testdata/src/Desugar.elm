@@ -0,0 +1,156 @@
+module Desugar exposing (..)
+
+
+type Shape
+    = Circle Float
+    | Rect Float Float
+
+
+type Boxed
+    = Box Int
+
+
+area : Shape -> Float
+area shape =
+    case shape of
+        Circle r ->
+            r * r
+
+        Rect w h ->
+            w * h
+
+
+unbox : Boxed -> Int
+unbox box =
+    case box of
+        Box n ->
+            n
+
+
+first : List a -> Maybe a
+first list =
+    case list of
+        x :: _ ->
+            Just x
+
+        [] ->
+            Nothing
+
+
+pair : ( Int, Int ) -> Int
+pair ( a, b ) =
+    a + b
+
+
+( one, two ) =
+    ( 1, 2 )
+
+
+point : { x : Int, y : Int }
+point =
+    { x = 1, y = 2 }
+
+
+move : { x : Int, y : Int } -> { x : Int, y : Int }
+move p =
+    { p | x = p.x + 1 }
+
+
+getX : { x : Int, y : Int } -> Int
+getX =
+    .x
+
+
+tuple3 : a -> b -> c -> ( a, b, c )
+tuple3 =
+    (,,)
+
+
+list : List Int
+list =
+    [ 1, 2 ]
+
+
+sign : Int -> Int
+sign n =
+    if n == 0 then
+        0
+    else
+        1
+
+
+negated : Int -> Int
+negated n =
+    -n
+
+
+letIn : Int
+letIn =
+    let
+        ( a, b ) =
+            ( 1, 2 )
+
+        double x =
+            x * 2
+    in
+        double a + b
+
+
+lambda : Int -> Int
+lambda =
+    \x -> x + 1
+
+
+nested : Maybe (Maybe Int) -> Int
+nested m =
+    case m of
+        Just (Just n) ->
+            n
+
+        _ ->
+            0
+
+
+literals : List Int
+literals =
+    case "a" of
+        "a" ->
+            [ 1 ]
+
+        _ ->
+            []
+
+
+bools : Bool -> Int
+bools b =
+    case b of
+        True ->
+            1
+
+        False ->
+            0
+
+
+pipe : Int
+pipe =
+    1 |> negate
+
+
+record : { x : Int } -> Int
+record { x } =
+    x
+
+
+aliased : Maybe Int -> Maybe Int
+aliased m =
+    case m of
+        (Just _) as j ->
+            j
+
+        Nothing ->
+            Nothing
+
+
+fail : String -> a
+fail msg =
+    Debug.crash msg
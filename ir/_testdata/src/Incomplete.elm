@@ -0,0 +1,11 @@
+module Incomplete exposing (..)
+
+
+incomplete : Maybe (Maybe Int) -> List Int -> Int
+incomplete m l =
+    case ( m, l ) of
+        ( Just (Just n), [] ) ->
+            n
+
+        ( Nothing, _ :: _ ) ->
+            0
@@ -0,0 +1,19 @@
+module Init exposing (..)
+
+
+answer : List Int
+answer =
+    prepend 2
+
+
+prepend : Int -> List Int
+prepend n =
+    if n == 0 then
+        base
+    else
+        n :: prepend (n - 1)
+
+
+base : List Int
+base =
+    [ 21 ]
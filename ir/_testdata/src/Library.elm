@@ -0,0 +1,21 @@
+module Library exposing (Shape(..), area)
+
+
+type Shape
+    = Square Int
+
+
+type Hidden
+    = Hidden
+
+
+area : Shape -> Int
+area shape =
+    case shape of
+        Square side ->
+            side * side
+
+
+helper : Int
+helper =
+    1
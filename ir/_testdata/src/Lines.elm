@@ -0,0 +1,28 @@
+module Lines exposing (..)
+
+
+half : Int -> Int
+half n =
+    if n == 0 then
+        Debug.crash "zero"
+    else
+        n // 2
+
+
+unwrap : Maybe a -> a
+unwrap m =
+    case m of
+        Just v ->
+            v
+
+        Nothing ->
+            Debug.crash ("nothing in " ++ "unwrap")
+
+
+pair : Int -> { first : Int, both : ( Int, Int ) }
+pair n =
+    let
+        both =
+            ( n, n )
+    in
+        { first = n, both = both }
@@ -0,0 +1,50 @@
+module Match exposing (..)
+
+
+type Color
+    = Red
+    | Green
+    | Blue
+    | Other Int
+
+
+both : Maybe Int -> Maybe Int -> Int
+both a b =
+    case ( a, b ) of
+        ( Just x, Just y ) ->
+            x + y
+
+        ( Just x, Nothing ) ->
+            x
+
+        ( Nothing, _ ) ->
+            0
+
+
+colors : Color -> Int
+colors c =
+    case c of
+        Red ->
+            1
+
+        Other 0 ->
+            2
+
+        Other n ->
+            n
+
+        _ ->
+            3
+
+
+lists : List Int -> Int
+lists l =
+    case l of
+        [] ->
+            0
+
+        [ x ] ->
+            x
+
+        x :: y :: _ ->
+            x + y
@@ -0,0 +1,76 @@
+module Optimize exposing (..)
+
+
+double : Int -> Int
+double x =
+    x * 2
+
+
+constant : Int
+constant =
+    1 + 2 * 3 - 10 // 3
+
+
+concat : String
+concat =
+    "a" ++ "b" ++ "c"
+
+
+inlined : Int
+inlined =
+    double 21
+
+
+pipeline : Int -> Int
+pipeline n =
+    n |> double |> negate
+
+
+backwards : Int -> Int
+backwards n =
+    negate <| double n
+
+
+composed : Int -> Int
+composed =
+    double >> negate
+
+
+applied : Int -> Int
+applied =
+    (\x y -> x + y) 1
+
+
+condition : String
+condition =
+    if 1 == 1 && not False then
+        "yes"
+    else
+        "no"
+
+
+fact : Int -> Int
+fact n =
+    if n == 0 then
+        1
+    else
+        n * fact (n - 1)
+
+
+factFive : Int
+factFive =
+    fact 5
+
+
+
+power : Int -> Int -> Int
+power base n =
+    if n == 0 then
+        1
+    else
+        base * power base (n - 1)
+
+
+powers : Int -> Int
+powers =
+    power (fact 2) >> negate
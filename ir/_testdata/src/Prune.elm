@@ -0,0 +1,30 @@
+module Prune exposing (main)
+
+
+type Used
+    = A
+    | B
+
+
+type Unused
+    = C
+
+
+main : Int
+main =
+    Maybe.withDefault 0 (Just used)
+
+
+used : Int
+used =
+    case A of
+        A ->
+            1
+
+        B ->
+            2
+
+
+unused : Int
+unused =
+    main + 1
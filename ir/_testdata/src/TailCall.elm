@@ -0,0 +1,57 @@
+module TailCall exposing (..)
+
+
+sum : Int -> List Int -> Int
+sum acc list =
+    case list of
+        [] ->
+            acc
+
+        x :: rest ->
+            sum (acc + x) rest
+
+
+length : List a -> Int
+length list =
+    let
+        go n xs =
+            case xs of
+                [] ->
+                    n
+
+                _ :: rest ->
+                    go (n + 1) rest
+    in
+        go 0 list
+
+
+fact : Int -> Int
+fact n =
+    if n == 0 then
+        1
+    else
+        n * fact (n - 1)
+
+
+isEven : Int -> Bool
+isEven n =
+    if n == 0 then
+        True
+    else
+        isOdd (n - 1)
+
+
+isOdd : Int -> Bool
+isOdd n =
+    if n == 0 then
+        False
+    else
+        isEven (n - 1)
+
+
+partial : Int -> Int -> Int
+partial a b =
+    if a == 0 then
+        b
+    else
+        partial (a - 1) |> (\f -> f b)
@@ -0,0 +1,483 @@
+package ir
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/source"
+	"github.com/elm-tangram/tangram/token"
+)
+
+// Error is an error found while desugaring a package, either because some
+// patterns do not match all the possible values or because the package was
+// not completely resolved.
+type Error struct {
+	// Module in which the error was found.
+	Module string
+	// Path is the path of the source file of the module.
+	Path string
+	// Pos is the position of the node that could not be desugared.
+	Pos token.Pos
+	// Line and Column are the position of the node in the source file, or
+	// zero if the source of the module is not known.
+	Line, Column int
+	// Msg is the error message.
+	Msg string
+}
+
+func (e *Error) Error() string {
+	pos := fmt.Sprintf("%s:%d:%d", e.Path, e.Line, e.Column)
+	if e.Line == 0 {
+		pos = fmt.Sprintf("%s (offset %d)", e.Path, e.Pos)
+	}
+	return fmt.Sprintf("ir: %s: %s", pos, e.Msg)
+}
+
+// Desugar lowers all the modules of a resolved package to the IR. The
+// modules must have been fully parsed, so modules loaded from their
+// interface cannot be desugared.
+//
+// Operators and qualified names become references to the globals they were
+// resolved to, accessors and tuple constructors become lambdas, list
+// literals become applications of the list constructors, ifs become cases
+// on booleans and the patterns of function arguments, cases and
+// destructuring definitions are compiled to decision trees of simple cases.
+// It is an error if those patterns do not match all the possible values.
+// Calls to Debug.crash become crashes, and definitions, applications and
+// cases keep their position in the source code.
+//
+// cm has the source files the package was parsed from, which are used to
+// find the line and column of the errors. It can be nil.
+func Desugar(pkg *ast.Package, cm *source.CodeMap) (result *Package, err error) {
+	d := &desugarer{
+		cm:      cm,
+		globals: make(map[ast.Node]*Global),
+		ctors:   make(map[*ast.Constructor]*Constructor),
+		unions:  make(map[string][]*Union),
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			result, err = nil, e
+		}
+	}()
+
+	for _, name := range pkg.Order {
+		if mod := pkg.Modules[name]; mod != nil {
+			d.declare(name, mod)
+		}
+	}
+
+	result = &Package{Modules: make(map[string]*Module)}
+	for _, name := range pkg.Order {
+		if mod := pkg.Modules[name]; mod != nil {
+			result.Order = append(result.Order, name)
+			result.Modules[name] = d.module(name, mod)
+		}
+	}
+	return result, nil
+}
+
+type desugarer struct {
+	// cm has the source files of the modules, if they are known.
+	cm *source.CodeMap
+	// globals are the top-level definitions of all modules, indexed by the
+	// node of their object.
+	globals map[ast.Node]*Global
+	ctors   map[*ast.Constructor]*Constructor
+	unions  map[string][]*Union
+
+	// mod is the name of the module being desugared and path the path of
+	// its source file.
+	mod, path string
+	// locals are the names of the local variables in the top-level
+	// definition being desugared, indexed by the node of their object.
+	locals map[ast.Node]string
+	// used are the names of the local variables already bound in the
+	// top-level definition being desugared.
+	used map[string]bool
+	// ignored are the variables of patterns that must not be bound.
+	ignored map[ast.Node]bool
+	// n is the number of fresh names generated in the module.
+	n int
+}
+
+func (d *desugarer) errorf(node ast.Node, format string, args ...interface{}) {
+	e := &Error{Module: d.mod, Path: d.path, Pos: node.Pos(), Msg: fmt.Sprintf(format, args...)}
+	if d.cm != nil {
+		if src := d.cm.Source(d.path); src != nil {
+			if lp, err := src.LinePos(e.Pos); err == nil {
+				e.Line, e.Column = lp.Line, lp.Col
+			}
+		}
+	}
+	panic(e)
+}
+
+// declare registers the top-level definitions and the union types of a
+// module, so they can be referenced from any module.
+func (d *desugarer) declare(name string, mod *ast.Module) {
+	for _, decl := range mod.Decls {
+		switch decl := decl.(type) {
+		case *ast.Definition:
+			d.globals[decl.Name] = &Global{Module: name, Name: decl.Name.Name}
+		case *ast.DestructuringAssignment:
+			for _, v := range patternVars(decl.Pattern) {
+				d.globals[v] = &Global{Module: name, Name: v.Name.Name}
+			}
+		case *ast.UnionDecl:
+			union := &Union{Module: name, Name: decl.Name.Name}
+			for i, c := range decl.Ctors {
+				ctor := &Constructor{Union: union, Name: c.Name.Name, Tag: i, Arity: len(c.Args)}
+				union.Ctors = append(union.Ctors, ctor)
+				d.ctors[c] = ctor
+			}
+			d.unions[name] = append(d.unions[name], union)
+		}
+	}
+}
+
+func (d *desugarer) module(name string, mod *ast.Module) *Module {
+	d.mod, d.path = name, mod.Path
+	d.n = 0
+	m := &Module{Name: name, Unions: d.unions[name]}
+	for _, decl := range mod.Decls {
+		switch decl := decl.(type) {
+		case *ast.Definition:
+			d.reset()
+			m.Defs = append(m.Defs, &Def{
+				Pos:  d.pos(decl.Name),
+				Name: decl.Name.Name,
+				Expr: d.function(decl.Args, decl.Body),
+			})
+		case *ast.DestructuringAssignment:
+			d.reset()
+			tmp := d.fresh("pattern")
+			m.Defs = append(m.Defs, &Def{Pos: d.pos(decl), Name: tmp, Expr: d.expr(decl.Expr)})
+			for _, v := range patternVars(decl.Pattern) {
+				d.reset()
+				m.Defs = append(m.Defs, &Def{
+					Pos:  d.pos(v),
+					Name: v.Name.Name,
+					Expr: d.destructure(decl.Pattern, v, &Global{Module: name, Name: tmp}),
+				})
+			}
+		}
+	}
+	return m
+}
+
+// reset forgets the local variables before desugaring a new top-level
+// definition.
+func (d *desugarer) reset() {
+	d.locals = make(map[ast.Node]string)
+	d.used = make(map[string]bool)
+	d.ignored = make(map[ast.Node]bool)
+}
+
+// fresh returns a new name for a variable introduced by the desugaring.
+func (d *desugarer) fresh(hint string) string {
+	d.n++
+	return hint + "$" + strconv.Itoa(d.n)
+}
+
+// bind returns the name of a new local variable declared by the given node,
+// which is its name in the source code unless it is already used in the
+// top-level definition.
+func (d *desugarer) bind(node ast.Node, name string) string {
+	name = d.unique(name)
+	d.locals[node] = name
+	return name
+}
+
+// unique returns the given name, or a fresh one based on it if it is already
+// used in the top-level definition, and marks it as used.
+func (d *desugarer) unique(name string) string {
+	if d.used[name] {
+		name = d.fresh(name)
+	}
+	d.used[name] = true
+	return name
+}
+
+// pos returns the position of a node of the module being desugared.
+func (d *desugarer) pos(node ast.Node) Pos {
+	return Pos{Module: d.mod, Offset: node.Pos()}
+}
+
+func (d *desugarer) crash(pos Pos) Expr {
+	return &Crash{
+		Pos:     pos,
+		Message: fmt.Sprintf("a pattern in module %s does not match all the possible values", d.mod),
+	}
+}
+
+func unit() Expr {
+	return new(Tuple)
+}
+
+// patternVars returns all the variable patterns in a pattern.
+func patternVars(pattern ast.Pattern) []*ast.VarPattern {
+	var vars []*ast.VarPattern
+	ast.WalkFunc(pattern, func(n ast.Node) bool {
+		if v, ok := n.(*ast.VarPattern); ok {
+			vars = append(vars, v)
+		}
+		return true
+	})
+	return vars
+}
+
+// subject calls fn with the name of a variable holding the value of the
+// given expression, which is bound in a let unless it is already a local
+// variable. pos is the position of the expression.
+func (d *desugarer) subject(pos Pos, e Expr, hint string, fn func(string) Expr) Expr {
+	if l, ok := e.(*Local); ok {
+		return fn(l.Name)
+	}
+
+	name := d.fresh(hint)
+	return &Let{
+		Defs: []*Def{{Pos: pos, Name: name, Expr: e}},
+		Body: fn(name),
+	}
+}
+
+func (d *desugarer) exprs(exprs []ast.Expr) []Expr {
+	result := make([]Expr, len(exprs))
+	for i, e := range exprs {
+		result[i] = d.expr(e)
+	}
+	return result
+}
+
+func (d *desugarer) fields(fields []*ast.FieldAssign) []*Field {
+	result := make([]*Field, len(fields))
+	for i, f := range fields {
+		result[i] = &Field{Name: f.Field.Name, Expr: d.expr(f.Expr)}
+	}
+	return result
+}
+
+func (d *desugarer) literal(lit *ast.BasicLit) interface{} {
+	if lit.Decoded == nil {
+		d.errorf(lit, "invalid literal %s", lit.Value)
+	}
+	return lit.Decoded
+}
+
+func (d *desugarer) expr(expr ast.Expr) Expr {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return d.ident(e)
+	case *ast.SelectorExpr:
+		return d.selector(e)
+	case *ast.BasicLit:
+		return &Lit{d.literal(e)}
+	case *ast.ShaderExpr:
+		return &Shader{e.Source}
+	case *ast.TupleLit:
+		return &Tuple{d.exprs(e.Elems)}
+	case *ast.FuncApp:
+		return d.app(e)
+	case *ast.RecordLit:
+		return &Record{d.fields(e.Fields)}
+	case *ast.RecordUpdate:
+		return &Update{Record: d.ident(e.Record), Fields: d.fields(e.Fields)}
+	case *ast.LetExpr:
+		return d.let(e)
+	case *ast.IfExpr:
+		return d.subject(d.pos(e.Cond), d.expr(e.Cond), "cond", func(s string) Expr {
+			return &Case{
+				Pos:      d.pos(e),
+				Subject:  s,
+				Branches: []*Branch{{&LitPattern{true}, d.expr(e.ThenExpr)}},
+				Default:  d.expr(e.ElseExpr),
+			}
+		})
+	case *ast.CaseExpr:
+		return d.subject(d.pos(e.Expr), d.expr(e.Expr), "case", func(s string) Expr {
+			return d.cases(e, s)
+		})
+	case *ast.ListLit:
+		elems := d.exprs(e.Elems)
+		var list Expr = Nil
+		for i := len(elems) - 1; i >= 0; i-- {
+			list = &App{Pos: d.pos(e.Elems[i]), Func: Cons, Args: []Expr{elems[i], list}}
+		}
+		return list
+	case *ast.UnaryOp:
+		if e.Op.Name != "-" {
+			d.errorf(e, "unknown unary operator %s", e.Op.Name)
+		}
+		return &App{
+			Pos:  d.pos(e),
+			Func: &Global{Module: "Basics", Name: "negate"},
+			Args: []Expr{d.expr(e.Expr)},
+		}
+	case *ast.BinaryOp:
+		return &App{
+			Pos:  d.pos(e.Op),
+			Func: d.ident(e.Op),
+			Args: []Expr{d.expr(e.Lhs), d.expr(e.Rhs)},
+		}
+	case *ast.AccessorExpr:
+		r := d.fresh("r")
+		return &Lambda{
+			Params: []string{r},
+			Body:   &Access{Record: &Local{r}, Field: e.Field.Name},
+		}
+	case *ast.TupleCtor:
+		lambda := &Lambda{Params: make([]string, e.Elems)}
+		tuple := &Tuple{Elems: make([]Expr, e.Elems)}
+		for i := range lambda.Params {
+			lambda.Params[i] = d.fresh("x")
+			tuple.Elems[i] = &Local{lambda.Params[i]}
+		}
+		lambda.Body = tuple
+		return lambda
+	case *ast.Lambda:
+		return d.function(e.Args, e.Expr)
+	case *ast.ParensExpr:
+		return d.expr(e.Expr)
+	default:
+		d.errorf(expr, "unexpected expression of type %T", expr)
+		return nil
+	}
+}
+
+func (d *desugarer) ident(id *ast.Ident) Expr {
+	if id.Obj == nil {
+		d.errorf(id, "unresolved identifier %s", id.Name)
+	}
+
+	switch id.Obj.Kind {
+	case ast.Ctor:
+		if c, ok := id.Obj.Node.(*ast.Constructor); ok && d.ctors[c] != nil {
+			return d.ctors[c]
+		}
+	case ast.Var:
+		if name, ok := d.locals[id.Obj.Node]; ok {
+			return &Local{name}
+		}
+
+		if g, ok := d.globals[id.Obj.Node]; ok {
+			if g.Module == List.Module && g.Name == Cons.Name {
+				return Cons
+			}
+
+			global := *g
+			return &global
+		}
+	}
+
+	d.errorf(id, "unresolved %s %s", id.Obj.Kind, id.Name)
+	return nil
+}
+
+// selector desugars a qualified name or the access to the fields of a
+// record.
+func (d *desugarer) selector(e *ast.SelectorExpr) Expr {
+	path := flatten(e)
+	var i int
+	for i < len(path) && path[i].Obj != nil && (path[i].Obj.Kind == ast.Mod || path[i].Obj.Kind == ast.NativeMod) {
+		i++
+	}
+
+	if i == len(path) {
+		d.errorf(e, "a module cannot be used as a value")
+	}
+
+	var result Expr
+	if i > 0 && path[0].Obj.Kind == ast.NativeMod {
+		names := make([]string, i)
+		for j, id := range path[:i] {
+			names[j] = id.Name
+		}
+
+		module := strings.Join(names, ".")
+		if imp, ok := path[0].Obj.Node.(*ast.ImportDecl); ok {
+			module = imp.ModuleName()
+		}
+		result = &Global{Module: module, Name: path[i].Name}
+	} else {
+		result = d.ident(path[i])
+	}
+
+	for _, f := range path[i+1:] {
+		result = &Access{Record: result, Field: f.Name}
+	}
+	return result
+}
+
+// app desugars a function application. The calls to Debug.crash become a
+// Crash with the message as argument.
+func (d *desugarer) app(e *ast.FuncApp) Expr {
+	pos := d.pos(e)
+	f := d.expr(e.Func)
+	args := d.exprs(e.Args)
+	if g, ok := f.(*Global); !ok || g.Module != "Debug" || g.Name != "crash" {
+		return &App{Pos: pos, Func: f, Args: args}
+	}
+
+	crash := &Crash{Pos: pos, Arg: args[0]}
+	if len(args) == 1 {
+		return crash
+	}
+	return &App{Pos: pos, Func: crash, Args: args[1:]}
+}
+
+// flatten returns the identifiers of a possibly qualified name in the order
+// they appear in the source code.
+func flatten(expr ast.Expr) []*ast.Ident {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return []*ast.Ident{expr}
+	case *ast.SelectorExpr:
+		return append([]*ast.Ident{expr.Selector}, flatten(expr.Expr)...)
+	}
+	return nil
+}
+
+func (d *desugarer) let(e *ast.LetExpr) Expr {
+	for _, decl := range e.Decls {
+		switch decl := decl.(type) {
+		case *ast.Definition:
+			d.bind(decl.Name, decl.Name.Name)
+		case *ast.DestructuringAssignment:
+			for _, v := range patternVars(decl.Pattern) {
+				d.bind(v, v.Name.Name)
+			}
+		}
+	}
+
+	var defs []*Def
+	for _, decl := range e.Decls {
+		switch decl := decl.(type) {
+		case *ast.Definition:
+			defs = append(defs, &Def{
+				Pos:  d.pos(decl.Name),
+				Name: d.locals[decl.Name],
+				Expr: d.function(decl.Args, decl.Body),
+			})
+		case *ast.DestructuringAssignment:
+			tmp := d.fresh("pattern")
+			defs = append(defs, &Def{Pos: d.pos(decl), Name: tmp, Expr: d.expr(decl.Expr)})
+			for _, v := range patternVars(decl.Pattern) {
+				defs = append(defs, &Def{
+					Pos:  d.pos(v),
+					Name: d.locals[v],
+					Expr: d.destructure(decl.Pattern, v, &Local{tmp}),
+				})
+			}
+		}
+	}
+
+	return &Let{Defs: defs, Body: d.expr(e.Body)}
+}
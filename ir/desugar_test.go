@@ -0,0 +1,248 @@
+package ir
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/parser"
+	"github.com/elm-tangram/tangram/source"
+	"github.com/elm-tangram/tangram/token"
+
+	"github.com/stretchr/testify/require"
+)
+
+// parseFixture parses the package of the given file in the source
+// directory of the test data and returns it along with its sources.
+func parseFixture(t *testing.T, file string) (*ast.Package, *source.CodeMap) {
+	path, err := filepath.Abs(filepath.Join("_testdata", "src", file))
+	require.NoError(t, err)
+
+	pkg, cm, err := parser.ParseSources(path, parser.FullParse|parser.SkipWarnings, 0)
+	require.NoError(t, err)
+	return pkg, cm
+}
+
+func desugarFixture(t *testing.T, file string) *Package {
+	result, err := Desugar(parseFixture(t, file))
+	require.NoError(t, err)
+	return result
+}
+
+// lines joins the given lines with new lines, so the expected
+// representations of the definitions can be indented along with the tests.
+func lines(lines ...string) string {
+	return strings.Join(lines, "\n")
+}
+
+func TestDesugar(t *testing.T) {
+	pkg := desugarFixture(t, "Desugar.elm")
+	mod := pkg.Modules["Desugar"]
+	require.NotNil(t, mod)
+
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"unbox", lines(
+			"unbox =",
+			"    \\box ->",
+			"        case box of",
+			"            Desugar.Box n ->",
+			"                n",
+		)},
+		{"first", lines(
+			"first =",
+			"    \\list ->",
+			"        case list of",
+			"            (::) x _ ->",
+			"                Maybe.Just x",
+			"            [] ->",
+			"                Maybe.Nothing",
+		)},
+		{"pair", lines(
+			"pair =",
+			"    \\p$1 ->",
+			"        case p$1 of",
+			"            (a, b) ->",
+			"                Basics.(+) a b",
+		)},
+		{"pattern$2", lines(
+			"pattern$2 =",
+			"    (1, 2)",
+		)},
+		{"two", lines(
+			"two =",
+			"    let",
+			"        pattern$5 =",
+			"            Desugar.pattern$2",
+			"    in",
+			"        case pattern$5 of",
+			"            (_, two$6) ->",
+			"                two$6",
+		)},
+		{"move", lines(
+			"move =",
+			"    \\p -> { p | x = Basics.(+) p.x 1 }",
+		)},
+		{"getX", lines(
+			"getX =",
+			"    \\r$7 -> r$7.x",
+		)},
+		{"tuple3", lines(
+			"tuple3 =",
+			"    \\x$8 x$9 x$10 -> (x$8, x$9, x$10)",
+		)},
+		{"list", lines(
+			"list =",
+			"    (::) 1 ((::) 2 [])",
+		)},
+		{"sign", lines(
+			"sign =",
+			"    \\n ->",
+			"        let",
+			"            cond$11 =",
+			"                Basics.(==) n 0",
+			"        in",
+			"            case cond$11 of",
+			"                True ->",
+			"                    0",
+			"                _ ->",
+			"                    1",
+		)},
+		{"negated", lines(
+			"negated =",
+			"    \\n -> Basics.negate n",
+		)},
+		{"letIn", lines(
+			"letIn =",
+			"    let",
+			"        pattern$12 =",
+			"            (1, 2)",
+			"        a =",
+			"            case pattern$12 of",
+			"                (a$13, _) ->",
+			"                    a$13",
+			"        b =",
+			"            case pattern$12 of",
+			"                (_, b$14) ->",
+			"                    b$14",
+			"        double =",
+			"            \\x -> Basics.(*) x 2",
+			"    in",
+			"        Basics.(+) (double a) b",
+		)},
+		{"nested", lines(
+			"nested =",
+			"    \\m ->",
+			"        let",
+			"            body$16 =",
+			"                \\_ -> 0",
+			"        in",
+			"            case m of",
+			"                Maybe.Just p$15 ->",
+			"                    case p$15 of",
+			"                        Maybe.Just n ->",
+			"                            n",
+			"                        _ ->",
+			"                            body$16 ()",
+			"                _ ->",
+			"                    body$16 ()",
+		)},
+		{"pipe", lines(
+			"pipe =",
+			"    Basics.(|>) 1 Basics.negate",
+		)},
+		{"record", lines(
+			"record =",
+			"    \\p$18 ->",
+			"        let",
+			"            x =",
+			"                p$18.x",
+			"        in",
+			"            x",
+		)},
+		{"aliased", lines(
+			"aliased =",
+			"    \\m ->",
+			"        case m of",
+			"            Maybe.Just _ ->",
+			"                m",
+			"            Maybe.Nothing ->",
+			"                Maybe.Nothing",
+		)},
+		{"fail", lines(
+			"fail =",
+			"    \\msg -> crash msg",
+		)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			def := mod.Lookup(c.name)
+			require.NotNil(t, def)
+			require.Equal(t, c.expected, DefString(def))
+		})
+	}
+}
+
+func TestDesugarPositions(t *testing.T) {
+	require := require.New(t)
+	pkg := desugarFixture(t, "Desugar.elm")
+
+	path, err := filepath.Abs(filepath.Join("_testdata", "src", "Desugar.elm"))
+	require.NoError(err)
+	data, err := ioutil.ReadFile(path)
+	require.NoError(err)
+	offset := func(code string) token.Pos {
+		return token.Pos(strings.Index(string(data), code))
+	}
+
+	pipe := pkg.Modules["Desugar"].Lookup("pipe")
+	require.Equal(Pos{"Desugar", offset("pipe =")}, pipe.Pos)
+	require.Equal(Pos{"Desugar", offset("|> negate")}, pipe.Expr.(*App).Pos)
+
+	fail := pkg.Modules["Desugar"].Lookup("fail")
+	crash := fail.Expr.(*Lambda).Body.(*Crash)
+	require.Equal(Pos{"Desugar", offset("Debug.crash msg")}, crash.Pos)
+}
+
+func TestDesugarUnions(t *testing.T) {
+	require := require.New(t)
+	pkg := desugarFixture(t, "Desugar.elm")
+
+	require.Equal([]string{"Basics", "List", "Maybe", "Result", "String", "Tuple", "Debug", "Desugar"}, pkg.Order)
+
+	unions := pkg.Modules["Desugar"].Unions
+	require.Len(unions, 2)
+	require.Equal("Shape", unions[0].Name)
+	require.Len(unions[0].Ctors, 2)
+
+	rect := unions[0].Ctors[1]
+	require.Equal("Rect", rect.Name)
+	require.Equal(1, rect.Tag)
+	require.Equal(2, rect.Arity)
+	require.Equal(unions[0], rect.Union)
+}
+
+func TestDesugarUnresolved(t *testing.T) {
+	require := require.New(t)
+
+	mod, err := parser.ParseFrom("Foo.elm", strings.NewReader("module Foo exposing (..)\n\nfoo = bar\n"), parser.FullParse|parser.SkipWarnings)
+	require.NoError(err)
+
+	_, err = Desugar(&ast.Package{
+		Order:   []string{"Foo"},
+		Modules: map[string]*ast.Module{"Foo": mod},
+	}, nil)
+	require.Error(err)
+
+	e, ok := err.(*Error)
+	require.True(ok)
+	require.Equal("Foo", e.Module)
+	require.Equal("unresolved identifier bar", e.Msg)
+	require.Equal(0, e.Line)
+	require.Equal("ir: Foo.elm (offset 32): unresolved identifier bar", e.Error())
+}
@@ -0,0 +1,225 @@
+package ir
+
+// fold returns the result of applying the operator or function of Basics
+// with the given name to the arguments if all of them are literals and the
+// result can be computed at compile time, otherwise nil.
+func fold(name string, args []Expr) Expr {
+	lits := make([]interface{}, len(args))
+	for i, a := range args {
+		l, ok := a.(*Lit)
+		if !ok {
+			return foldPartial(name, args)
+		}
+		lits[i] = l.Value
+	}
+
+	var result interface{}
+	switch len(lits) {
+	case 1:
+		result = foldUnary(name, lits[0])
+	case 2:
+		result = foldBinary(name, lits[0], lits[1])
+	}
+
+	if result == nil {
+		return nil
+	}
+	return &Lit{result}
+}
+
+// foldPartial folds the boolean operators whose result is known from the
+// left operand, which is the only one evaluated in that case.
+func foldPartial(name string, args []Expr) Expr {
+	if len(args) != 2 {
+		return nil
+	}
+
+	l, ok := args[0].(*Lit)
+	if !ok {
+		return nil
+	}
+
+	switch b := l.Value.(type) {
+	case bool:
+		switch {
+		case name == "&&" && !b, name == "||" && b:
+			return l
+		case name == "&&", name == "||":
+			return args[1]
+		}
+	}
+	return nil
+}
+
+func foldUnary(name string, v interface{}) interface{} {
+	switch name {
+	case "negate":
+		switch v := v.(type) {
+		case int64:
+			return -v
+		case float64:
+			return -v
+		}
+	case "not":
+		if b, ok := v.(bool); ok {
+			return !b
+		}
+	}
+	return nil
+}
+
+func foldBinary(name string, a, b interface{}) interface{} {
+	switch name {
+	case "==":
+		if sameType(a, b) {
+			return a == b
+		}
+	case "/=":
+		if sameType(a, b) {
+			return a != b
+		}
+	case "<", ">", "<=", ">=":
+		if c, ok := compare(a, b); ok {
+			switch name {
+			case "<":
+				return c < 0
+			case ">":
+				return c > 0
+			case "<=":
+				return c <= 0
+			default:
+				return c >= 0
+			}
+		}
+	case "&&", "||":
+		x, okx := a.(bool)
+		y, oky := b.(bool)
+		if okx && oky {
+			if name == "&&" {
+				return x && y
+			}
+			return x || y
+		}
+	case "++":
+		x, okx := a.(string)
+		y, oky := b.(string)
+		if okx && oky {
+			return x + y
+		}
+	case "+", "-", "*":
+		switch x := a.(type) {
+		case int64:
+			if y, ok := b.(int64); ok {
+				return arithInt(name, x, y)
+			}
+		case float64:
+			if y, ok := b.(float64); ok {
+				return arithFloat(name, x, y)
+			}
+		}
+	case "/":
+		x, okx := a.(float64)
+		y, oky := b.(float64)
+		if okx && oky && y != 0 {
+			return x / y
+		}
+	case "//", "%", "rem":
+		x, okx := a.(int64)
+		y, oky := b.(int64)
+		if !okx || !oky || y == 0 {
+			return nil
+		}
+
+		switch name {
+		case "//":
+			return x / y
+		case "rem":
+			return x % y
+		default:
+			// the result of % has the sign of the divisor
+			r := x % y
+			if r != 0 && (r < 0) != (y < 0) {
+				r += y
+			}
+			return r
+		}
+	}
+	return nil
+}
+
+func arithInt(op string, x, y int64) int64 {
+	switch op {
+	case "+":
+		return x + y
+	case "-":
+		return x - y
+	default:
+		return x * y
+	}
+}
+
+func arithFloat(op string, x, y float64) float64 {
+	switch op {
+	case "+":
+		return x + y
+	case "-":
+		return x - y
+	default:
+		return x * y
+	}
+}
+
+func sameType(a, b interface{}) bool {
+	switch a.(type) {
+	case int64:
+		_, ok := b.(int64)
+		return ok
+	case float64:
+		_, ok := b.(float64)
+		return ok
+	case string:
+		_, ok := b.(string)
+		return ok
+	case rune:
+		_, ok := b.(rune)
+		return ok
+	case bool:
+		_, ok := b.(bool)
+		return ok
+	}
+	return false
+}
+
+// compare returns -1, 0 or 1 depending on whether a is less than, equal to
+// or greater than b, if they are comparable literals of the same type.
+func compare(a, b interface{}) (int, bool) {
+	switch x := a.(type) {
+	case int64:
+		if y, ok := b.(int64); ok {
+			return order(x < y, x > y), true
+		}
+	case float64:
+		if y, ok := b.(float64); ok {
+			return order(x < y, x > y), true
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return order(x < y, x > y), true
+		}
+	case rune:
+		if y, ok := b.(rune); ok {
+			return order(x < y, x > y), true
+		}
+	}
+	return 0, false
+}
+
+func order(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
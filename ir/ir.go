@@ -0,0 +1,276 @@
+// Package ir defines the core intermediate representation of Elm programs.
+// It is a small functional language with lambdas, applications, lets, cases
+// on simple patterns and literals, to which every construct of the surface
+// syntax is lowered by Desugar, so backends and interpreters only have to
+// deal with a handful of expressions.
+//
+// Names of variables introduced by the desugaring contain a "$", which is
+// not valid in Elm identifiers, so they never clash with the names in the
+// source code.
+package ir
+
+import "github.com/elm-tangram/tangram/token"
+
+// Package is the representation of all the modules of a package.
+type Package struct {
+	// Order in which modules depend on each other, the modules in it only
+	// depend on the modules before them.
+	Order []string
+	// Modules is a mapping between a module name and the module.
+	Modules map[string]*Module
+}
+
+// Module is the representation of a module.
+type Module struct {
+	// Name of the module.
+	Name string
+	// Unions are the union types declared in the module.
+	Unions []*Union
+	// Defs are the top-level definitions of the module, in the same order
+	// they were declared.
+	Defs []*Def
+}
+
+// Lookup returns the top-level definition with the given name, if any.
+func (m *Module) Lookup(name string) *Def {
+	for _, d := range m.Defs {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// Union is a union type.
+type Union struct {
+	// Module in which the union type is declared.
+	Module string
+	// Name of the union type.
+	Name string
+	// Ctors are the constructors of the union, ordered by their tag.
+	Ctors []*Constructor
+}
+
+// Constructor is a constructor of a union type. As an expression, it is a
+// function that takes as many arguments as its arity and returns a value of
+// the union, or the value itself if its arity is zero.
+type Constructor struct {
+	// Union the constructor belongs to.
+	Union *Union
+	// Name of the constructor.
+	Name string
+	// Tag is the index of the constructor in its union.
+	Tag int
+	// Arity is the number of arguments of the constructor.
+	Arity int
+}
+
+var (
+	// List is the builtin list type, which is a union of the empty list and
+	// the cons of an element to a list.
+	List = &Union{Module: "List", Name: "List"}
+	// Nil is the constructor of the empty list "[]".
+	Nil = &Constructor{Union: List, Name: "[]", Tag: 0}
+	// Cons is the constructor "::" that prepends an element to a list.
+	Cons = &Constructor{Union: List, Name: "::", Tag: 1, Arity: 2}
+)
+
+func init() {
+	List.Ctors = []*Constructor{Nil, Cons}
+}
+
+// Pos is a position in the source code of a module. Expressions can be
+// inlined in other modules, so their positions have the module they come
+// from. The zero value is an unknown position.
+type Pos struct {
+	// Module in whose source code the position is.
+	Module string
+	// Offset of the position in the source code.
+	Offset token.Pos
+}
+
+// IsValid reports whether the position is known.
+func (p Pos) IsValid() bool {
+	return p.Module != ""
+}
+
+// Def is a definition of a name, either top-level or in a let expression.
+type Def struct {
+	// Pos is the position of the name being defined.
+	Pos Pos
+	// Name being defined.
+	Name string
+	// Expr is the value of the definition.
+	Expr Expr
+	// Recursive reports whether the definition calls itself, directly or
+	// through other definitions, in some place that is not a tail call
+	// turned into a loop, so the depth of the stack is not bounded. It is
+	// set by TailCalls.
+	Recursive bool
+}
+
+// Expr is an expression of the IR.
+type Expr interface {
+	isExpr()
+}
+
+// Local is a reference to a variable bound by a lambda, a let or a case.
+type Local struct {
+	Name string
+}
+
+// Global is a reference to a top-level definition of a module, which can be
+// a native module.
+type Global struct {
+	Module string
+	Name   string
+}
+
+// Lit is a literal value, whose value is an int64, a float64, a string, a
+// rune or a bool.
+type Lit struct {
+	Value interface{}
+}
+
+// Shader is a GLSL shader block.
+type Shader struct {
+	Source string
+}
+
+// Lambda is an anonymous function. Parameters that are not used are named
+// "_".
+type Lambda struct {
+	Params []string
+	Body   Expr
+}
+
+// App is the application of a function to some arguments.
+type App struct {
+	Pos  Pos
+	Func Expr
+	Args []Expr
+}
+
+// Let binds some definitions in the scope of its body. The definitions can
+// refer to each other and to themselves.
+type Let struct {
+	Defs []*Def
+	Body Expr
+}
+
+// Case matches the value of a variable against the patterns of its branches
+// in order. The body of the first branch whose pattern matches is evaluated
+// with the variables of the pattern bound. If no pattern matches, the
+// default is evaluated instead, which is nil if some branch always matches.
+type Case struct {
+	Pos      Pos
+	Subject  string
+	Branches []*Branch
+	Default  Expr
+}
+
+// Branch is a branch of a case expression.
+type Branch struct {
+	Pattern Pattern
+	Body    Expr
+}
+
+// Tuple is a tuple of any number of elements. The tuple with no elements is
+// the unit value.
+type Tuple struct {
+	Elems []Expr
+}
+
+// Record is a record literal.
+type Record struct {
+	Fields []*Field
+}
+
+// Field is the value of a field in a record literal or update.
+type Field struct {
+	Name string
+	Expr Expr
+}
+
+// Access is the access to a field of a record.
+type Access struct {
+	Record Expr
+	Field  string
+}
+
+// Update is a copy of a record with some of its fields changed.
+type Update struct {
+	Record Expr
+	Fields []*Field
+}
+
+// Crash stops the program with an error message when it is evaluated. It
+// is used for the values that are not matched by any branch of a case, and
+// for the calls to Debug.crash, whose message is the value of Arg instead.
+type Crash struct {
+	Pos     Pos
+	Message string
+	Arg     Expr
+}
+
+// Loop evaluates its body repeatedly. If the body evaluates a Recur, its
+// variables are bound to the arguments of the Recur and the body is
+// evaluated again, otherwise the value of the body is the value of the
+// loop. It is the body of the lambda whose parameters are its variables.
+type Loop struct {
+	Vars []string
+	Body Expr
+}
+
+// Recur starts the next iteration of the innermost loop, binding its
+// variables to the arguments, which are all evaluated before binding any of
+// them. It only appears in a tail position of the body of a loop.
+type Recur struct {
+	Pos  Pos
+	Args []Expr
+}
+
+func (*Local) isExpr()       {}
+func (*Global) isExpr()      {}
+func (*Constructor) isExpr() {}
+func (*Lit) isExpr()         {}
+func (*Shader) isExpr()      {}
+func (*Lambda) isExpr()      {}
+func (*App) isExpr()         {}
+func (*Let) isExpr()         {}
+func (*Case) isExpr()        {}
+func (*Tuple) isExpr()       {}
+func (*Record) isExpr()      {}
+func (*Access) isExpr()      {}
+func (*Update) isExpr()      {}
+func (*Crash) isExpr()       {}
+func (*Loop) isExpr()        {}
+func (*Recur) isExpr()       {}
+
+// Pattern is a simple pattern, which does not contain other patterns.
+type Pattern interface {
+	isPattern()
+}
+
+// CtorPattern matches the values built with a constructor and binds its
+// arguments to variables. Arguments that are not used are named "_".
+type CtorPattern struct {
+	Ctor *Constructor
+	Vars []string
+}
+
+// TuplePattern matches any tuple with as many elements as variables, and
+// binds each element to its variable. Elements that are not used are named
+// "_".
+type TuplePattern struct {
+	Vars []string
+}
+
+// LitPattern matches a literal value.
+type LitPattern struct {
+	Value interface{}
+}
+
+func (*CtorPattern) isPattern()  {}
+func (*TuplePattern) isPattern() {}
+func (*LitPattern) isPattern()   {}
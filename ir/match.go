@@ -0,0 +1,706 @@
+package ir
+
+import (
+	"strings"
+
+	"github.com/elm-tangram/tangram/ast"
+)
+
+// Patterns are compiled to decision trees, which test the value of each
+// variable at most once in every path, instead of trying the patterns one
+// after the other. The tree is built from a matrix with a row for each
+// pattern and a column for each value being matched. At every step, the
+// first column tested by the first row is switched on, and the rows are
+// specialised for every constructor that appears in it, so the rows that
+// test the same constructor share the test.
+//
+// Every case of the tree becomes a Case of the IR, which backends can emit
+// as a switch statement. The paths of the tree where no row matches are the
+// values that the patterns do not cover, so they are reported as an error.
+
+// pat is a pattern normalised for the match compiler, in which lists, bools
+// and aliases are no longer special.
+type pat struct {
+	kind patKind
+	ctor *Constructor
+	lit  interface{}
+	args []*pat
+	// vars are the variables bound to the value matched by the pattern.
+	vars []binding
+}
+
+type patKind byte
+
+const (
+	anyPat patKind = iota
+	ctorPat
+	tuplePat
+	litPat
+)
+
+// wildcard is a pattern that matches anything and binds nothing.
+var wildcard = new(pat)
+
+// binding is a variable bound by a pattern, which is declared by node. If
+// field is not empty, the variable is bound to that field of the matched
+// value instead of the value itself.
+type binding struct {
+	node  ast.Node
+	name  string
+	field string
+}
+
+// bound is a variable bound to the value of a subject.
+type bound struct {
+	binding
+	subject string
+}
+
+// row is a row of the pattern matrix.
+type row struct {
+	pats []*pat
+	// bound are the variables bound by the patterns already matched.
+	bound []bound
+	// vars are all the variables bound by the patterns of the row.
+	vars []binding
+	body func() Expr
+	// origin is the row of the original matrix this row comes from.
+	origin *row
+}
+
+// decision is a node of a decision tree, which is either a *leaf, a *fail
+// or a *test.
+type decision interface{}
+
+// leaf evaluates the body of a row, with its variables bound.
+type leaf struct {
+	row   *row
+	bound []bound
+}
+
+// fail is reached when no row matches.
+type fail struct{}
+
+// test switches on the value of a subject.
+type test struct {
+	subject string
+	edges   []*edge
+	// def is followed if the pattern of no edge matches, it is nil if the
+	// patterns of the edges cover all the possible values.
+	def decision
+}
+
+type edge struct {
+	pattern Pattern
+	next    decision
+}
+
+// constraint is what is known about the value of a subject in a path of the
+// decision tree, used to give examples of the values that are not matched.
+type constraint struct {
+	// pattern the value matches, if any.
+	pattern Pattern
+	// not are the constructors or literals the value does not match.
+	not []interface{}
+}
+
+type matcher struct {
+	d *desugarer
+	// pos is the position of the patterns being matched.
+	pos Pos
+	// subjects are the subjects of the original matrix.
+	subjects []string
+	// uses is the number of leaves of each row.
+	uses    map[*row]int
+	missing []string
+}
+
+// function desugars a function with the given arguments and body, or just
+// the body if there are no arguments.
+func (d *desugarer) function(args []ast.Pattern, body ast.Expr) Expr {
+	if len(args) == 0 {
+		return d.expr(body)
+	}
+
+	params := make([]string, len(args))
+	pats := make([]*pat, len(args))
+	for i, arg := range args {
+		switch a := arg.(type) {
+		case *ast.VarPattern:
+			params[i] = d.bind(a, a.Name.Name)
+			pats[i] = wildcard
+		case *ast.AnythingPattern:
+			params[i] = "_"
+			pats[i] = wildcard
+		default:
+			params[i] = d.fresh("p")
+			pats[i] = d.normalize(a)
+		}
+	}
+
+	return &Lambda{
+		Params: params,
+		Body: d.compile(args[0], params, []*row{
+			d.row(pats, func() Expr { return d.expr(body) }),
+		}),
+	}
+}
+
+// cases desugars the branches of a case expression on the value of the
+// given variable.
+func (d *desugarer) cases(e *ast.CaseExpr, subject string) Expr {
+	rows := make([]*row, len(e.Branches))
+	for i, b := range e.Branches {
+		b := b
+		rows[i] = d.row(
+			[]*pat{d.normalize(b.Pattern)},
+			func() Expr { return d.expr(b.Expr) },
+		)
+	}
+	return d.compile(e, []string{subject}, rows)
+}
+
+// destructure returns the value of the given variable of a pattern when it
+// is matched against the value of subject.
+func (d *desugarer) destructure(pattern ast.Pattern, v *ast.VarPattern, subject Expr) Expr {
+	saved := d.locals[v]
+	for _, other := range patternVars(pattern) {
+		d.ignored[other] = other != v
+	}
+	d.used[v.Name.Name] = true
+
+	result := d.subject(d.pos(pattern), subject, "pattern", func(s string) Expr {
+		return d.compile(pattern, []string{s}, []*row{
+			d.row([]*pat{d.normalize(pattern)}, func() Expr {
+				return &Local{d.locals[v]}
+			}),
+		})
+	})
+
+	for _, other := range patternVars(pattern) {
+		delete(d.ignored, other)
+	}
+	d.locals[v] = saved
+	return result
+}
+
+func (d *desugarer) row(pats []*pat, body func() Expr) *row {
+	r := &row{pats: pats, body: body}
+	r.origin = r
+	for _, p := range pats {
+		r.vars = appendVars(r.vars, p)
+	}
+	return r
+}
+
+// appendVars appends the variables bound by the pattern to vars.
+func appendVars(vars []binding, p *pat) []binding {
+	vars = append(vars, p.vars...)
+	for _, arg := range p.args {
+		vars = appendVars(vars, arg)
+	}
+	return vars
+}
+
+// normalize returns the normalised version of a pattern.
+func (d *desugarer) normalize(pattern ast.Pattern) *pat {
+	switch p := pattern.(type) {
+	case *ast.AnythingPattern:
+		return wildcard
+	case *ast.VarPattern:
+		if d.ignored[p] {
+			return wildcard
+		}
+		return &pat{vars: []binding{{node: p, name: p.Name.Name}}}
+	case *ast.AliasPattern:
+		result := *d.normalize(p.Pattern)
+		result.vars = append([]binding{{node: p.Pattern, name: p.Name.Name}}, result.vars...)
+		return &result
+	case *ast.LiteralPattern:
+		return &pat{kind: litPat, lit: d.literal(p.Literal)}
+	case *ast.CtorPattern:
+		if b, ok := boolCtor(p); ok {
+			return &pat{kind: litPat, lit: b}
+		}
+
+		ctor := d.ctorOf(p)
+		if len(p.Args) != ctor.Arity {
+			d.errorf(p, "the constructor %s has %d arguments, but the pattern has %d", ctor.Name, ctor.Arity, len(p.Args))
+		}
+		return &pat{kind: ctorPat, ctor: ctor, args: d.normalizeAll(p.Args)}
+	case *ast.TuplePattern:
+		return &pat{kind: tuplePat, args: d.normalizeAll(p.Elems)}
+	case *ast.RecordPattern:
+		result := new(pat)
+		for _, f := range p.Fields {
+			v, ok := f.(*ast.VarPattern)
+			if !ok {
+				d.errorf(f, "the fields of a record pattern must be variables")
+			}
+
+			if !d.ignored[v] {
+				result.vars = append(result.vars, binding{v, v.Name.Name, v.Name.Name})
+			}
+		}
+		return result
+	case *ast.ListPattern:
+		result := &pat{kind: ctorPat, ctor: Nil}
+		for i := len(p.Elems) - 1; i >= 0; i-- {
+			result = &pat{
+				kind: ctorPat,
+				ctor: Cons,
+				args: []*pat{d.normalize(p.Elems[i]), result},
+			}
+		}
+		return result
+	default:
+		d.errorf(pattern, "unexpected pattern of type %T", pattern)
+		return nil
+	}
+}
+
+func (d *desugarer) normalizeAll(patterns []ast.Pattern) []*pat {
+	result := make([]*pat, len(patterns))
+	for i, p := range patterns {
+		result[i] = d.normalize(p)
+	}
+	return result
+}
+
+// boolCtor returns the value of the pattern if it is True or False.
+func boolCtor(p *ast.CtorPattern) (value bool, ok bool) {
+	if id, isIdent := p.Ctor.(*ast.Ident); isIdent && id.Obj == nil && len(p.Args) == 0 {
+		switch id.Name {
+		case "True":
+			return true, true
+		case "False":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// ctorOf returns the constructor matched by the pattern.
+func (d *desugarer) ctorOf(p *ast.CtorPattern) *Constructor {
+	path := flatten(p.Ctor)
+	if len(path) == 0 {
+		d.errorf(p, "unexpected constructor expression of type %T", p.Ctor)
+	}
+
+	id := path[len(path)-1]
+	if id.Name == Cons.Name {
+		return Cons
+	}
+
+	if id.Obj != nil && id.Obj.Kind == ast.Ctor {
+		if c, ok := id.Obj.Node.(*ast.Constructor); ok && d.ctors[c] != nil {
+			return d.ctors[c]
+		}
+	}
+
+	d.errorf(p, "unresolved constructor %s", id.Name)
+	return nil
+}
+
+// compile matches the values of the subjects against the rows and returns
+// the body of the first row that matches. It is an error if there are
+// values that no row matches, which is reported at the given node.
+func (d *desugarer) compile(node ast.Node, subjects []string, rows []*row) Expr {
+	m := &matcher{d: d, pos: d.pos(node), subjects: subjects, uses: make(map[*row]int)}
+	tree := m.compile(subjects, rows, nil)
+	if len(m.missing) > 0 {
+		d.errorf(node, "the patterns do not match all the possible values, these are missing: %s", strings.Join(m.missing, ", "))
+	}
+
+	// the rows with more than one leaf are bound to a function, so their
+	// bodies are not duplicated
+	var joins []*Def
+	var names = make(map[*row]string)
+	for _, r := range rows {
+		if m.uses[r] < 2 {
+			continue
+		}
+
+		params := []string{"_"}
+		if len(r.vars) > 0 {
+			params = make([]string, len(r.vars))
+			for i, v := range r.vars {
+				params[i] = d.bind(v.node, v.name)
+			}
+		}
+
+		names[r] = d.fresh("body")
+		joins = append(joins, &Def{
+			Name: names[r],
+			Expr: &Lambda{Params: params, Body: r.body()},
+		})
+	}
+
+	result := m.expr(tree, names)
+	if len(joins) > 0 {
+		return &Let{Defs: joins, Body: result}
+	}
+	return result
+}
+
+// compile builds the decision tree of a pattern matrix. The constraints are
+// what is known about the subjects in the path to the tree.
+func (m *matcher) compile(subjects []string, rows []*row, known map[string]*constraint) decision {
+	if len(rows) == 0 {
+		examples := make([]string, len(m.subjects))
+		for i, s := range m.subjects {
+			examples[i] = example(s, known, len(m.subjects) > 1)
+		}
+		m.missing = append(m.missing, strings.Join(examples, " "))
+		return new(fail)
+	}
+
+	first := rows[0]
+	col := -1
+	for i, p := range first.pats {
+		if p.kind != anyPat {
+			col = i
+			break
+		}
+	}
+
+	if col < 0 {
+		bound := append([]bound(nil), first.bound...)
+		for i, p := range first.pats {
+			bound = appendBound(bound, p, subjects[i])
+		}
+		m.uses[first.origin]++
+		return &leaf{first, bound}
+	}
+
+	head := first.pats[col]
+	subject := subjects[col]
+	t := &test{subject: subject}
+
+	switch head.kind {
+	case tuplePat:
+		isTuple := func(p *pat) bool { return p.kind == tuplePat }
+		args := m.args(rows, col, len(head.args), isTuple)
+		t.edges = append(t.edges, &edge{
+			&TuplePattern{args},
+			m.compile(
+				expand(subjects, col, args),
+				specialize(rows, col, subject, len(args), isTuple),
+				with(known, subject, &constraint{pattern: &TuplePattern{args}}),
+			),
+		})
+		return t
+	case ctorPat:
+		var seen []interface{}
+		for _, r := range rows {
+			p := r.pats[col]
+			if p.kind != ctorPat || contains(seen, p.ctor) {
+				continue
+			}
+			seen = append(seen, p.ctor)
+
+			ctor := p.ctor
+			isCtor := func(p *pat) bool { return p.kind == ctorPat && p.ctor == ctor }
+			args := m.args(rows, col, ctor.Arity, isCtor)
+			pattern := &CtorPattern{ctor, args}
+			t.edges = append(t.edges, &edge{
+				pattern,
+				m.compile(
+					expand(subjects, col, args),
+					specialize(rows, col, subject, len(args), isCtor),
+					with(known, subject, &constraint{pattern: pattern}),
+				),
+			})
+		}
+
+		if len(seen) < len(head.ctor.Union.Ctors) {
+			t.def = m.compile(
+				expand(subjects, col, nil),
+				specialize(rows, col, subject, 0, nothing),
+				with(known, subject, &constraint{not: seen}),
+			)
+		}
+		return t
+	default:
+		var seen []interface{}
+		for _, r := range rows {
+			p := r.pats[col]
+			if p.kind != litPat || contains(seen, p.lit) {
+				continue
+			}
+			seen = append(seen, p.lit)
+
+			lit := p.lit
+			isLit := func(p *pat) bool { return p.kind == litPat && p.lit == lit }
+			t.edges = append(t.edges, &edge{
+				&LitPattern{lit},
+				m.compile(
+					expand(subjects, col, nil),
+					specialize(rows, col, subject, 0, isLit),
+					with(known, subject, &constraint{pattern: &LitPattern{lit}}),
+				),
+			})
+		}
+
+		if _, isBool := head.lit.(bool); !isBool || len(seen) < 2 {
+			t.def = m.compile(
+				expand(subjects, col, nil),
+				specialize(rows, col, subject, 0, nothing),
+				with(known, subject, &constraint{not: seen}),
+			)
+		}
+		return t
+	}
+}
+
+func nothing(*pat) bool {
+	return false
+}
+
+func contains(values []interface{}, v interface{}) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// args returns the names of the variables for the arguments of the patterns
+// in the given column that match. If the pattern of the first row binds an
+// argument to a variable, its name is used. Arguments that are never used
+// are named "_".
+func (m *matcher) args(rows []*row, col, n int, match func(*pat) bool) []string {
+	args := make([]string, n)
+	for i := range args {
+		var hint string
+		var used bool
+		for _, r := range rows {
+			p := r.pats[col]
+			if !match(p) {
+				continue
+			}
+
+			arg := p.args[i]
+			if arg.kind != anyPat || len(arg.vars) > 0 {
+				used = true
+			}
+
+			if hint == "" && len(arg.vars) > 0 && arg.vars[0].field == "" {
+				hint = arg.vars[0].name
+			}
+		}
+
+		switch {
+		case !used:
+			args[i] = "_"
+		case hint != "":
+			args[i] = m.d.unique(hint)
+		default:
+			args[i] = m.d.fresh("p")
+		}
+	}
+	return args
+}
+
+// expand replaces the subject in the given column with the given ones.
+func expand(subjects []string, col int, with []string) []string {
+	result := make([]string, 0, len(subjects)+len(with)-1)
+	result = append(result, subjects[:col]...)
+	result = append(result, with...)
+	return append(result, subjects[col+1:]...)
+}
+
+// specialize returns the rows that match a pattern with n arguments in the
+// given column, which are either the rows whose pattern in the column
+// matches or the rows that match anything. The column is replaced with the
+// arguments of the pattern.
+func specialize(rows []*row, col int, subject string, n int, match func(*pat) bool) []*row {
+	var result []*row
+	for _, r := range rows {
+		p := r.pats[col]
+		var args []*pat
+		switch {
+		case p.kind == anyPat:
+			args = make([]*pat, n)
+			for i := range args {
+				args[i] = wildcard
+			}
+		case match(p):
+			args = p.args
+		default:
+			continue
+		}
+
+		pats := make([]*pat, 0, len(r.pats)+n-1)
+		pats = append(pats, r.pats[:col]...)
+		pats = append(pats, args...)
+		pats = append(pats, r.pats[col+1:]...)
+
+		result = append(result, &row{
+			pats:   pats,
+			bound:  appendBound(append([]bound(nil), r.bound...), p, subject),
+			vars:   r.vars,
+			body:   r.body,
+			origin: r.origin,
+		})
+	}
+	return result
+}
+
+// appendBound binds the variables of the pattern to the subject.
+func appendBound(result []bound, p *pat, subject string) []bound {
+	for _, v := range p.vars {
+		result = append(result, bound{v, subject})
+	}
+	return result
+}
+
+func with(known map[string]*constraint, subject string, c *constraint) map[string]*constraint {
+	result := make(map[string]*constraint, len(known)+1)
+	for k, v := range known {
+		result[k] = v
+	}
+	result[subject] = c
+	return result
+}
+
+// expr returns the expression of a decision tree. The leaves of the rows
+// bound to a function in names call it.
+func (m *matcher) expr(tree decision, names map[*row]string) Expr {
+	switch n := tree.(type) {
+	case *leaf:
+		if name, ok := names[n.row.origin]; ok {
+			return m.call(name, n)
+		}
+		return m.leaf(n)
+	case *test:
+		c := &Case{Pos: m.pos, Subject: n.subject}
+		for _, e := range n.edges {
+			c.Branches = append(c.Branches, &Branch{e.pattern, m.expr(e.next, names)})
+		}
+
+		if n.def != nil {
+			c.Default = m.expr(n.def, names)
+		}
+		return c
+	default:
+		return m.d.crash(m.pos)
+	}
+}
+
+// leaf returns the body of the row in the leaf, with its variables bound to
+// the subjects.
+func (m *matcher) leaf(n *leaf) Expr {
+	var defs []*Def
+	for _, b := range n.bound {
+		if b.field == "" {
+			m.d.locals[b.node] = b.subject
+			continue
+		}
+
+		defs = append(defs, &Def{
+			Name: m.d.bind(b.node, b.name),
+			Expr: &Access{Record: &Local{b.subject}, Field: b.field},
+		})
+	}
+
+	if len(defs) > 0 {
+		return &Let{Defs: defs, Body: n.row.body()}
+	}
+	return n.row.body()
+}
+
+// call returns the call to the function the body of the row in the leaf is
+// bound to.
+func (m *matcher) call(name string, n *leaf) Expr {
+	if len(n.row.vars) == 0 {
+		return &App{Func: &Local{name}, Args: []Expr{unit()}}
+	}
+
+	args := make([]Expr, len(n.row.vars))
+	for i, v := range n.row.vars {
+		for _, b := range n.bound {
+			if b.node != v.node {
+				continue
+			}
+
+			if b.field == "" {
+				args[i] = &Local{b.subject}
+			} else {
+				args[i] = &Access{Record: &Local{b.subject}, Field: b.field}
+			}
+		}
+	}
+	return &App{Func: &Local{name}, Args: args}
+}
+
+// example returns an example of the values of the subject that satisfy the
+// known constraints, wrapped in parenthesis if nested and it has spaces.
+func example(subject string, known map[string]*constraint, nested bool) string {
+	c, ok := known[subject]
+	if !ok {
+		return "_"
+	}
+
+	var s string
+	switch p := c.pattern.(type) {
+	case *CtorPattern:
+		args := make([]string, len(p.Vars))
+		for i, v := range p.Vars {
+			args[i] = example(v, known, true)
+		}
+
+		switch {
+		case p.Ctor == Cons:
+			s = args[0] + " :: " + example(p.Vars[1], known, false)
+		case p.Ctor == Nil:
+			s = "[]"
+		default:
+			s = strings.Join(append([]string{p.Ctor.Name}, args...), " ")
+		}
+	case *TuplePattern:
+		args := make([]string, len(p.Vars))
+		for i, v := range p.Vars {
+			args[i] = example(v, known, false)
+		}
+		return "(" + strings.Join(args, ", ") + ")"
+	case *LitPattern:
+		s = literal(p.Value)
+	default:
+		s = notExample(c.not)
+	}
+
+	if nested && strings.Contains(s, " ") {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+// notExample returns an example of a value that is none of the given
+// constructors or literals.
+func notExample(not []interface{}) string {
+	if len(not) == 0 {
+		return "_"
+	}
+
+	switch v := not[0].(type) {
+	case *Constructor:
+		for _, c := range v.Union.Ctors {
+			if contains(not, c) {
+				continue
+			}
+
+			switch c {
+			case Nil:
+				return "[]"
+			case Cons:
+				return "_ :: _"
+			}
+			return c.Name + strings.Repeat(" _", c.Arity)
+		}
+	case bool:
+		return literal(!v)
+	}
+	return "_"
+}
@@ -0,0 +1,115 @@
+package ir
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+	mod := desugarFixture(t, "Match.elm").Modules["Match"]
+	require.NotNil(t, mod)
+
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"both", lines(
+			"both =",
+			"    \\a b ->",
+			"        let",
+			"            case$1 =",
+			"                (a, b)",
+			"        in",
+			"            case case$1 of",
+			"                (p$2, p$3) ->",
+			"                    case p$2 of",
+			"                        Maybe.Just x ->",
+			"                            case p$3 of",
+			"                                Maybe.Just y ->",
+			"                                    Basics.(+) x y",
+			"                                Maybe.Nothing ->",
+			"                                    x",
+			"                        Maybe.Nothing ->",
+			"                            0",
+		)},
+		{"colors", lines(
+			"colors =",
+			"    \\c ->",
+			"        case c of",
+			"            Match.Red ->",
+			"                1",
+			"            Match.Other n ->",
+			"                case n of",
+			"                    0 ->",
+			"                        2",
+			"                    _ ->",
+			"                        n",
+			"            _ ->",
+			"                3",
+		)},
+		{"lists", lines(
+			"lists =",
+			"    \\l ->",
+			"        case l of",
+			"            [] ->",
+			"                0",
+			"            (::) x p$4 ->",
+			"                case p$4 of",
+			"                    [] ->",
+			"                        x",
+			"                    (::) y _ ->",
+			"                        Basics.(+) x y",
+		)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			def := mod.Lookup(c.name)
+			require.NotNil(t, def)
+			require.Equal(t, c.expected, DefString(def))
+		})
+	}
+}
+
+func TestMatchIncomplete(t *testing.T) {
+	require := require.New(t)
+
+	_, err := Desugar(parseFixture(t, "Incomplete.elm"))
+	require.Error(err)
+
+	e, ok := err.(*Error)
+	require.True(ok)
+	require.Equal("Incomplete", e.Module)
+	require.Equal(
+		"the patterns do not match all the possible values, these are missing: (Just (Just _), _ :: _), (Just Nothing, _), (Nothing, [])",
+		e.Msg,
+	)
+	require.Equal(6, e.Line)
+	require.Equal(5, e.Column)
+	require.Equal("ir: "+e.Path+":6:5: "+e.Msg, e.Error())
+	require.Contains(e.Path, filepath.Join("_testdata", "src", "Incomplete.elm"))
+}
+
+func TestMatchExample(t *testing.T) {
+	maybe := &Union{Module: "Maybe", Name: "Maybe"}
+	just := &Constructor{Union: maybe, Name: "Just", Tag: 0, Arity: 1}
+	nothing := &Constructor{Union: maybe, Name: "Nothing", Tag: 1}
+	maybe.Ctors = []*Constructor{just, nothing}
+
+	known := map[string]*constraint{
+		"a": {pattern: &CtorPattern{Cons, []string{"b", "c"}}},
+		"b": {pattern: &CtorPattern{just, []string{"d"}}},
+		"c": {not: []interface{}{Cons}},
+		"d": {not: []interface{}{true}},
+		"e": {not: []interface{}{just}},
+		"f": {not: []interface{}{int64(1)}},
+	}
+
+	require.Equal(t, "(Just False) :: []", example("a", known, false))
+	require.Equal(t, "((Just False) :: [])", example("a", known, true))
+	require.Equal(t, "Nothing", example("e", known, true))
+	require.Equal(t, "_", example("f", known, false))
+	require.Equal(t, "_", example("g", known, false))
+}
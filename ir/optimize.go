@@ -0,0 +1,621 @@
+package ir
+
+import (
+	"strconv"
+	"strings"
+)
+
+// maxInlineSize is the maximum size, in number of nodes, of the top-level
+// definitions that are inlined.
+const maxInlineSize = 12
+
+// Optimize returns an optimised version of the package, in which:
+//
+//   - Small top-level definitions that are not recursive are inlined where
+//     they are called, as well as the definitions that are just an alias of a
+//     literal or another global.
+//   - Applications of the pipeline operators |>, <|, >> and << of Basics are
+//     replaced by direct calls of their operands.
+//   - Immediately applied lambdas are beta-reduced.
+//   - Arithmetic, comparisons and string concatenation on literals are
+//     folded, along with the cases on literals.
+//
+// The package given is not modified.
+func Optimize(pkg *Package) *Package {
+	o := &optimizer{
+		defs:      make(map[Global]*Def),
+		recursive: make(map[Global]bool),
+	}
+
+	for _, name := range pkg.Order {
+		for _, d := range pkg.Modules[name].Defs {
+			o.defs[Global{name, d.Name}] = d
+		}
+	}
+
+	result := &Package{
+		Order:   pkg.Order,
+		Modules: make(map[string]*Module, len(pkg.Modules)),
+	}
+
+	for _, name := range pkg.Order {
+		mod := pkg.Modules[name]
+		m := &Module{Name: mod.Name, Unions: mod.Unions}
+		for _, d := range mod.Defs {
+			m.Defs = append(m.Defs, o.def(d))
+		}
+		result.Modules[name] = m
+	}
+	return result
+}
+
+type optimizer struct {
+	// defs are the top-level definitions of the package. They are inlined
+	// before being optimised, so they are optimised along with the code
+	// they are inlined into.
+	defs map[Global]*Def
+	// recursive caches which definitions are recursive.
+	recursive map[Global]bool
+
+	// used are the names of the local variables in the top-level definition
+	// being optimised.
+	used map[string]bool
+	// subst are the local variables that are replaced by another
+	// expression.
+	subst map[string]Expr
+	// pos is the position of the application being optimised.
+	pos Pos
+}
+
+func (o *optimizer) def(d *Def) *Def {
+	o.used = make(map[string]bool)
+	o.subst = make(map[string]Expr)
+	walkBinders(d.Expr, func(name string) { o.used[name] = true })
+	return &Def{Pos: d.Pos, Name: d.Name, Expr: o.expr(d.Expr)}
+}
+
+// fresh returns a new name for a local variable, based on the given one.
+func (o *optimizer) fresh(name string) string {
+	if i := strings.IndexByte(name, '$'); i >= 0 {
+		name = name[:i]
+	}
+
+	for i := 1; ; i++ {
+		n := name + "$" + strconv.Itoa(i)
+		if !o.used[n] {
+			o.used[n] = true
+			return n
+		}
+	}
+}
+
+func (o *optimizer) exprs(exprs []Expr) []Expr {
+	result := make([]Expr, len(exprs))
+	for i, e := range exprs {
+		result[i] = o.expr(e)
+	}
+	return result
+}
+
+func (o *optimizer) fields(fields []*Field) []*Field {
+	result := make([]*Field, len(fields))
+	for i, f := range fields {
+		result[i] = &Field{Name: f.Name, Expr: o.expr(f.Expr)}
+	}
+	return result
+}
+
+func (o *optimizer) expr(expr Expr) Expr {
+	switch e := expr.(type) {
+	case *Local:
+		return o.local(e)
+	case *Global:
+		if def := o.inlinable(*e); def != nil && isAtom(def.Expr) {
+			return o.expr(def.Expr)
+		}
+		return e
+	case *App:
+		f := e.Func
+		if _, ok := f.(*Global); !ok {
+			f = o.expr(f)
+		}
+
+		args := o.exprs(e.Args)
+		pos := o.pos
+		o.pos = e.Pos
+		result := o.app(f, args)
+		o.pos = pos
+		return result
+	case *Lambda:
+		return &Lambda{Params: e.Params, Body: o.expr(e.Body)}
+	case *Let:
+		return o.let(e)
+	case *Case:
+		return o.caseExpr(e)
+	case *Tuple:
+		return &Tuple{o.exprs(e.Elems)}
+	case *Record:
+		return &Record{o.fields(e.Fields)}
+	case *Access:
+		return &Access{Record: o.expr(e.Record), Field: e.Field}
+	case *Update:
+		return &Update{Record: o.expr(e.Record), Fields: o.fields(e.Fields)}
+	case *Crash:
+		if e.Arg == nil {
+			return e
+		}
+		return &Crash{Pos: e.Pos, Arg: o.expr(e.Arg)}
+	default:
+		return expr
+	}
+}
+
+// local returns the expression that replaces the local variable, if any.
+func (o *optimizer) local(l *Local) Expr {
+	var result Expr = l
+	for i := 0; i <= len(o.subst); i++ {
+		s, ok := o.subst[l.Name]
+		if !ok {
+			break
+		}
+
+		result = s
+		if l, ok = s.(*Local); !ok {
+			break
+		}
+	}
+	return result
+}
+
+// app returns the optimised application of an optimised function to
+// optimised arguments, except for globals, which are optimised here.
+func (o *optimizer) app(f Expr, args []Expr) Expr {
+	if len(args) == 0 {
+		return f
+	}
+
+	switch fn := f.(type) {
+	case *App:
+		return o.app(fn.Func, append(append([]Expr(nil), fn.Args...), args...))
+	case *Lambda:
+		return o.beta(fn, args)
+	case *Global:
+		if fn.Module == "Basics" {
+			if result := o.pipeline(fn.Name, args); result != nil {
+				return result
+			}
+
+			if result := fold(fn.Name, args); result != nil {
+				return result
+			}
+		}
+
+		if def := o.inlinable(*fn); def != nil {
+			return o.app(o.rename(def.Expr), args)
+		}
+	}
+	return &App{Pos: o.pos, Func: f, Args: args}
+}
+
+// pipeline returns the direct call of the operands of a pipeline operator,
+// or nil if the operator is not a pipeline.
+func (o *optimizer) pipeline(op string, args []Expr) Expr {
+	if len(args) < 2 {
+		return nil
+	}
+
+	switch op {
+	case "|>":
+		return o.app(o.app(args[1], args[:1]), args[2:])
+	case "<|":
+		return o.app(o.app(args[0], args[1:2]), args[2:])
+	case ">>", "<<":
+		f, g := args[0], args[1]
+		if op == "<<" {
+			f, g = g, f
+		}
+
+		if len(args) > 2 {
+			return o.app(o.app(g, []Expr{o.app(f, args[2:3])}), args[3:])
+		}
+
+		// the operands are moved inside the lambda, so the ones that would
+		// be evaluated on every call are bound before it
+		var defs []*Def
+		f, defs = o.bind("f", f, defs)
+		g, defs = o.bind("g", g, defs)
+
+		x := o.fresh("x")
+		var result Expr = &Lambda{
+			Params: []string{x},
+			Body:   o.app(g, []Expr{o.app(f, []Expr{&Local{x}})}),
+		}
+		if len(defs) > 0 {
+			result = &Let{Defs: defs, Body: result}
+		}
+		return result
+	}
+	return nil
+}
+
+// bind returns the expression itself if it is an atom or a lambda, and
+// otherwise a local bound to it by a definition appended to defs.
+func (o *optimizer) bind(name string, e Expr, defs []*Def) (Expr, []*Def) {
+	if _, ok := e.(*Lambda); ok || isAtom(e) {
+		return e, defs
+	}
+
+	name = o.fresh(name)
+	return &Local{name}, append(defs, &Def{Name: name, Expr: e})
+}
+
+// beta returns the result of applying the lambda to the arguments. The
+// arguments that are atoms replace the parameters in the body, and the
+// rest are bound in a let.
+func (o *optimizer) beta(fn *Lambda, args []Expr) Expr {
+	n := len(fn.Params)
+	if len(args) < n {
+		n = len(args)
+	}
+
+	var defs []*Def
+	for i, p := range fn.Params[:n] {
+		switch {
+		case p == "_" && isAtom(args[i]):
+		case p == "_":
+			defs = append(defs, &Def{Name: o.fresh("unused"), Expr: args[i]})
+		case isAtom(args[i]):
+			o.subst[p] = args[i]
+		default:
+			defs = append(defs, &Def{Name: p, Expr: args[i]})
+		}
+	}
+
+	var body Expr
+	if n < len(fn.Params) {
+		body = &Lambda{Params: fn.Params[n:], Body: o.expr(fn.Body)}
+	} else {
+		body = o.app(o.expr(fn.Body), args[n:])
+	}
+
+	if len(defs) > 0 {
+		return &Let{Defs: defs, Body: body}
+	}
+	return body
+}
+
+func (o *optimizer) let(e *Let) Expr {
+	var defs []*Def
+	for _, d := range e.Defs {
+		value := o.expr(d.Expr)
+		if isAtom(value) && !references(value, d.Name) {
+			o.subst[d.Name] = value
+			continue
+		}
+		defs = append(defs, &Def{Pos: d.Pos, Name: d.Name, Expr: value})
+	}
+
+	body := o.expr(e.Body)
+
+	// lambdas that are no longer used are removed, the rest of definitions
+	// are kept in case they crash
+	var result []*Def
+	for i, d := range defs {
+		if _, ok := d.Expr.(*Lambda); ok && !defsReference(defs, i, d.Name) && !references(body, d.Name) {
+			continue
+		}
+		result = append(result, d)
+	}
+
+	if len(result) == 0 {
+		return body
+	}
+	return &Let{Defs: result, Body: body}
+}
+
+func (o *optimizer) caseExpr(e *Case) Expr {
+	subject := e.Subject
+	var known Expr
+	if s, ok := o.subst[subject]; ok {
+		if l, ok := s.(*Local); ok {
+			subject = l.Name
+		} else {
+			known = s
+		}
+	}
+
+	if known != nil {
+		if body, ok := knownBranch(e, known); ok {
+			return o.expr(body)
+		}
+		delete(o.subst, e.Subject)
+	}
+
+	c := &Case{Pos: e.Pos, Subject: subject}
+	for _, b := range e.Branches {
+		c.Branches = append(c.Branches, &Branch{Pattern: b.Pattern, Body: o.expr(b.Body)})
+	}
+
+	if e.Default != nil {
+		c.Default = o.expr(e.Default)
+	}
+
+	if known != nil {
+		// the subject is no longer a variable, so it is bound again
+		return &Let{Defs: []*Def{{Name: e.Subject, Expr: known}}, Body: c}
+	}
+	return c
+}
+
+// knownBranch returns the body of the branch of the case that matches the given
+// known value, if it can be known at compile time.
+func knownBranch(c *Case, value Expr) (Expr, bool) {
+	for _, b := range c.Branches {
+		switch p := b.Pattern.(type) {
+		case *LitPattern:
+			lit, ok := value.(*Lit)
+			if !ok {
+				return nil, false
+			}
+
+			if lit.Value == p.Value {
+				return b.Body, true
+			}
+		case *CtorPattern:
+			ctor, ok := value.(*Constructor)
+			if !ok || ctor.Arity > 0 {
+				return nil, false
+			}
+
+			if ctor == p.Ctor {
+				return b.Body, true
+			}
+		default:
+			return nil, false
+		}
+	}
+
+	if c.Default != nil {
+		return c.Default, true
+	}
+	return nil, false
+}
+
+// inlinable returns the definition of the global if it can be inlined.
+func (o *optimizer) inlinable(g Global) *Def {
+	def, ok := o.defs[g]
+	if !ok {
+		return nil
+	}
+
+	if !isAtom(def.Expr) {
+		if _, ok := def.Expr.(*Lambda); !ok || size(def.Expr) > maxInlineSize {
+			return nil
+		}
+	}
+
+	if o.isRecursive(g) {
+		return nil
+	}
+	return def
+}
+
+// isRecursive reports whether the definition of the global references
+// itself, directly or through other top-level definitions.
+func (o *optimizer) isRecursive(g Global) bool {
+	if r, ok := o.recursive[g]; ok {
+		return r
+	}
+
+	visited := make(map[Global]bool)
+	var reaches func(from Global) bool
+	reaches = func(from Global) bool {
+		def, ok := o.defs[from]
+		if !ok || visited[from] {
+			return false
+		}
+		visited[from] = true
+
+		var found bool
+		walkGlobals(def.Expr, func(ref Global) {
+			if !found && (ref == g || reaches(ref)) {
+				found = true
+			}
+		})
+		return found
+	}
+
+	o.recursive[g] = reaches(g)
+	return o.recursive[g]
+}
+
+// rename returns a copy of the expression in which all the variables it
+// binds have fresh names, so it can be inlined without clashing with the
+// variables in scope.
+func (o *optimizer) rename(expr Expr) Expr {
+	names := make(map[string]string)
+	walkBinders(expr, func(name string) {
+		if name != "_" {
+			names[name] = o.fresh(name)
+		}
+	})
+	return renameExpr(expr, names)
+}
+
+func renameAll(names map[string]string, vars []string) []string {
+	result := make([]string, len(vars))
+	for i, v := range vars {
+		if n, ok := names[v]; ok {
+			result[i] = n
+		} else {
+			result[i] = v
+		}
+	}
+	return result
+}
+
+func renameDefs(defs []*Def, names map[string]string) []*Def {
+	result := make([]*Def, len(defs))
+	for i, d := range defs {
+		result[i] = &Def{Pos: d.Pos, Name: renameAll(names, []string{d.Name})[0], Expr: renameExpr(d.Expr, names)}
+	}
+	return result
+}
+
+func renameFields(fields []*Field, names map[string]string) []*Field {
+	result := make([]*Field, len(fields))
+	for i, f := range fields {
+		result[i] = &Field{Name: f.Name, Expr: renameExpr(f.Expr, names)}
+	}
+	return result
+}
+
+func renameExpr(expr Expr, names map[string]string) Expr {
+	switch e := expr.(type) {
+	case *Local:
+		return &Local{renameAll(names, []string{e.Name})[0]}
+	case *Lambda:
+		return &Lambda{Params: renameAll(names, e.Params), Body: renameExpr(e.Body, names)}
+	case *App:
+		args := make([]Expr, len(e.Args))
+		for i, a := range e.Args {
+			args[i] = renameExpr(a, names)
+		}
+		return &App{Pos: e.Pos, Func: renameExpr(e.Func, names), Args: args}
+	case *Let:
+		return &Let{Defs: renameDefs(e.Defs, names), Body: renameExpr(e.Body, names)}
+	case *Case:
+		c := &Case{Pos: e.Pos, Subject: renameAll(names, []string{e.Subject})[0]}
+		for _, b := range e.Branches {
+			var p Pattern
+			switch bp := b.Pattern.(type) {
+			case *CtorPattern:
+				p = &CtorPattern{Ctor: bp.Ctor, Vars: renameAll(names, bp.Vars)}
+			case *TuplePattern:
+				p = &TuplePattern{Vars: renameAll(names, bp.Vars)}
+			default:
+				p = bp
+			}
+			c.Branches = append(c.Branches, &Branch{Pattern: p, Body: renameExpr(b.Body, names)})
+		}
+
+		if e.Default != nil {
+			c.Default = renameExpr(e.Default, names)
+		}
+		return c
+	case *Tuple:
+		elems := make([]Expr, len(e.Elems))
+		for i, el := range e.Elems {
+			elems[i] = renameExpr(el, names)
+		}
+		return &Tuple{elems}
+	case *Record:
+		return &Record{renameFields(e.Fields, names)}
+	case *Access:
+		return &Access{Record: renameExpr(e.Record, names), Field: e.Field}
+	case *Update:
+		return &Update{Record: renameExpr(e.Record, names), Fields: renameFields(e.Fields, names)}
+	case *Crash:
+		if e.Arg == nil {
+			return e
+		}
+		return &Crash{Pos: e.Pos, Arg: renameExpr(e.Arg, names)}
+	case *Loop:
+		return &Loop{Vars: renameAll(names, e.Vars), Body: renameExpr(e.Body, names)}
+	case *Recur:
+		args := make([]Expr, len(e.Args))
+		for i, a := range e.Args {
+			args[i] = renameExpr(a, names)
+		}
+		return &Recur{Pos: e.Pos, Args: args}
+	default:
+		return expr
+	}
+}
+
+// isAtom reports whether the expression is so simple that it can be
+// duplicated freely.
+func isAtom(e Expr) bool {
+	switch e.(type) {
+	case *Local, *Global, *Constructor, *Lit:
+		return true
+	}
+	return false
+}
+
+// size returns the number of nodes of an expression.
+func size(e Expr) int {
+	n := 0
+	WalkFunc(e, func(Expr) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// references reports whether the expression references the local variable.
+func references(e Expr, name string) bool {
+	var found bool
+	WalkFunc(e, func(e Expr) bool {
+		switch e := e.(type) {
+		case *Local:
+			found = found || e.Name == name
+		case *Case:
+			found = found || e.Subject == name
+		}
+		return !found
+	})
+	return found
+}
+
+// defsReference reports whether any definition but the one at index i
+// references the local variable.
+func defsReference(defs []*Def, i int, name string) bool {
+	for j, d := range defs {
+		if j != i && references(d.Expr, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// walkGlobals calls fn with all the globals referenced in the expression.
+func walkGlobals(e Expr, fn func(Global)) {
+	WalkFunc(e, func(e Expr) bool {
+		if g, ok := e.(*Global); ok {
+			fn(*g)
+		}
+		return true
+	})
+}
+
+// walkBinders calls fn with the names of all the variables bound in the
+// expression.
+func walkBinders(e Expr, fn func(string)) {
+	WalkFunc(e, func(e Expr) bool {
+		switch e := e.(type) {
+		case *Lambda:
+			for _, p := range e.Params {
+				fn(p)
+			}
+		case *Let:
+			for _, d := range e.Defs {
+				fn(d.Name)
+			}
+		case *Case:
+			for _, b := range e.Branches {
+				switch p := b.Pattern.(type) {
+				case *CtorPattern:
+					for _, v := range p.Vars {
+						fn(v)
+					}
+				case *TuplePattern:
+					for _, v := range p.Vars {
+						fn(v)
+					}
+				}
+			}
+		}
+		return true
+	})
+}
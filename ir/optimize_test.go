@@ -0,0 +1,120 @@
+package ir
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptimize(t *testing.T) {
+	pkg := desugarFixture(t, "Optimize.elm")
+	mod := Optimize(pkg).Modules["Optimize"]
+	require.NotNil(t, mod)
+
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"constant", lines(
+			"constant =",
+			"    4",
+		)},
+		{"concat", lines(
+			"concat =",
+			`    "abc"`,
+		)},
+		{"inlined", lines(
+			"inlined =",
+			"    42",
+		)},
+		{"pipeline", lines(
+			"pipeline =",
+			"    \\n ->",
+			"        let",
+			"            n$1 =",
+			"                Native.Basics.mul n 2",
+			"        in",
+			"            Native.Basics.sub 0 n$1",
+		)},
+		{"composed", lines(
+			"composed =",
+			"    \\x$1 ->",
+			"        let",
+			"            n$1 =",
+			"                Native.Basics.mul x$1 2",
+			"        in",
+			"            Native.Basics.sub 0 n$1",
+		)},
+		{"powers", lines(
+			"powers =",
+			"    let",
+			"        f$1 =",
+			"            Optimize.power (Optimize.fact 2)",
+			"    in",
+			"        \\x$1 ->",
+			"            let",
+			"                n$1 =",
+			"                    f$1 x$1",
+			"            in",
+			"                Native.Basics.sub 0 n$1",
+		)},
+		{"applied", lines(
+			"applied =",
+			"    \\y -> Native.Basics.add 1 y",
+		)},
+		{"condition", lines(
+			"condition =",
+			`    "yes"`,
+		)},
+		{"factFive", lines(
+			"factFive =",
+			"    Optimize.fact 5",
+		)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			def := mod.Lookup(c.name)
+			require.NotNil(t, def)
+			require.Equal(t, c.expected, DefString(def))
+		})
+	}
+
+	// the original package is not modified
+	require.Equal(t, lines(
+		"inlined =",
+		"    Optimize.double 21",
+	), DefString(pkg.Modules["Optimize"].Lookup("inlined")))
+}
+
+func TestFold(t *testing.T) {
+	x := &Local{"x"}
+	cases := []struct {
+		name     string
+		args     []Expr
+		expected Expr
+	}{
+		{"+", []Expr{&Lit{int64(1)}, &Lit{int64(2)}}, &Lit{int64(3)}},
+		{"*", []Expr{&Lit{1.5}, &Lit{2.0}}, &Lit{3.0}},
+		{"+", []Expr{&Lit{int64(1)}, &Lit{2.0}}, nil},
+		{"/", []Expr{&Lit{1.0}, &Lit{0.0}}, nil},
+		{"//", []Expr{&Lit{int64(-7)}, &Lit{int64(2)}}, &Lit{int64(-3)}},
+		{"//", []Expr{&Lit{int64(1)}, &Lit{int64(0)}}, nil},
+		{"%", []Expr{&Lit{int64(-1)}, &Lit{int64(4)}}, &Lit{int64(3)}},
+		{"rem", []Expr{&Lit{int64(-1)}, &Lit{int64(4)}}, &Lit{int64(-1)}},
+		{"++", []Expr{&Lit{"a"}, &Lit{"b"}}, &Lit{"ab"}},
+		{"<", []Expr{&Lit{'a'}, &Lit{'b'}}, &Lit{true}},
+		{">=", []Expr{&Lit{"a"}, &Lit{"b"}}, &Lit{false}},
+		{"/=", []Expr{&Lit{int64(1)}, &Lit{int64(1)}}, &Lit{false}},
+		{"&&", []Expr{&Lit{false}, x}, &Lit{false}},
+		{"||", []Expr{&Lit{false}, x}, x},
+		{"&&", []Expr{x, &Lit{false}}, nil},
+		{"negate", []Expr{&Lit{2.5}}, &Lit{-2.5}},
+		{"not", []Expr{&Lit{true}}, &Lit{false}},
+		{"max", []Expr{&Lit{int64(1)}, &Lit{int64(2)}}, nil},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.expected, fold(c.name, c.args), "%s %v", c.name, c.args)
+	}
+}
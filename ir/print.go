@@ -0,0 +1,286 @@
+package ir
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Fprint writes a human readable representation of the module to w, which
+// looks like Elm code, mostly for debugging and testing purposes.
+func Fprint(w io.Writer, mod *Module) error {
+	p := new(printer)
+	p.module(mod)
+	_, err := w.Write(p.buf.Bytes())
+	return err
+}
+
+// ExprString returns a human readable representation of an expression.
+func ExprString(e Expr) string {
+	p := new(printer)
+	p.expr(e)
+	return p.buf.String()
+}
+
+// DefString returns a human readable representation of a definition.
+func DefString(d *Def) string {
+	p := new(printer)
+	p.def(d)
+	return p.buf.String()
+}
+
+type printer struct {
+	buf    bytes.Buffer
+	indent int
+}
+
+func (p *printer) print(args ...interface{}) {
+	for _, arg := range args {
+		fmt.Fprint(&p.buf, arg)
+	}
+}
+
+func (p *printer) newline() {
+	p.buf.WriteByte('\n')
+	p.buf.WriteString(strings.Repeat("    ", p.indent))
+}
+
+// block prints the given function indented in a new line.
+func (p *printer) block(fn func()) {
+	p.indent++
+	p.newline()
+	fn()
+	p.indent--
+}
+
+func (p *printer) module(mod *Module) {
+	p.print("module ", mod.Name)
+	p.newline()
+	for _, u := range mod.Unions {
+		p.newline()
+		p.union(u)
+		p.newline()
+	}
+
+	for _, d := range mod.Defs {
+		p.newline()
+		p.def(d)
+		p.newline()
+	}
+}
+
+func (p *printer) union(u *Union) {
+	p.print("type ", u.Name, " =")
+	for i, c := range u.Ctors {
+		if i > 0 {
+			p.print(" |")
+		}
+		p.print(" ", c.Name, strings.Repeat(" _", c.Arity))
+	}
+}
+
+func (p *printer) def(d *Def) {
+	p.print(name(d.Name), " =")
+	p.block(func() { p.expr(d.Expr) })
+}
+
+// name returns the name of a variable, wrapped in parenthesis if it is an
+// operator.
+func name(n string) string {
+	r, _ := utf8.DecodeRuneInString(n)
+	if unicode.IsLetter(r) || r == '_' {
+		return n
+	}
+	return "(" + n + ")"
+}
+
+func (p *printer) expr(e Expr) {
+	switch e := e.(type) {
+	case nil:
+		p.print("<nil>")
+	case *Local:
+		p.print(e.Name)
+	case *Global:
+		p.print(e.Module, ".", name(e.Name))
+	case *Constructor:
+		p.ctor(e)
+	case *Lit:
+		p.print(literal(e.Value))
+	case *Shader:
+		p.print("[glsl|", e.Source, "|]")
+	case *Lambda:
+		p.print("\\", strings.Join(e.Params, " "), " ->")
+		p.body(e.Body)
+	case *App:
+		p.atom(e.Func)
+		for _, arg := range e.Args {
+			p.print(" ")
+			p.atom(arg)
+		}
+	case *Let:
+		p.print("let")
+		p.indent++
+		for _, d := range e.Defs {
+			p.newline()
+			p.def(d)
+		}
+		p.indent--
+		p.newline()
+		p.print("in")
+		p.block(func() { p.expr(e.Body) })
+	case *Case:
+		p.print("case ", e.Subject, " of")
+		p.indent++
+		for _, b := range e.Branches {
+			p.newline()
+			p.pattern(b.Pattern)
+			p.print(" ->")
+			p.block(func() { p.expr(b.Body) })
+		}
+		if e.Default != nil {
+			p.newline()
+			p.print("_ ->")
+			p.block(func() { p.expr(e.Default) })
+		}
+		p.indent--
+	case *Tuple:
+		p.print("(")
+		for i, el := range e.Elems {
+			if i > 0 {
+				p.print(", ")
+			}
+			p.expr(el)
+		}
+		p.print(")")
+	case *Record:
+		p.print("{")
+		p.fields(e.Fields)
+		p.print("}")
+	case *Access:
+		p.atom(e.Record)
+		p.print(".", e.Field)
+	case *Update:
+		p.print("{ ")
+		p.atom(e.Record)
+		p.print(" |")
+		p.fields(e.Fields)
+		p.print("}")
+	case *Crash:
+		if e.Arg == nil {
+			p.print("crash ", strconv.Quote(e.Message))
+		} else {
+			p.print("crash ")
+			p.atom(e.Arg)
+		}
+	case *Loop:
+		p.print("loop ", strings.Join(e.Vars, " "), " ->")
+		p.body(e.Body)
+	case *Recur:
+		p.print("recur")
+		for _, arg := range e.Args {
+			p.print(" ")
+			p.atom(arg)
+		}
+	default:
+		panic(fmt.Errorf("ir: unable to print expression of type %T", e))
+	}
+}
+
+// body prints the body of a lambda, in a new line if it spans multiple
+// lines.
+func (p *printer) body(e Expr) {
+	switch e.(type) {
+	case *Let, *Case, *Loop:
+		p.block(func() { p.expr(e) })
+	default:
+		p.print(" ")
+		p.expr(e)
+	}
+}
+
+// atom prints the expression wrapped in parenthesis unless it is a simple
+// one.
+func (p *printer) atom(e Expr) {
+	switch e := e.(type) {
+	case *App, *Lambda, *Let, *Case, *Crash, *Loop, *Recur:
+		p.print("(")
+		p.expr(e)
+		p.print(")")
+	case *Lit:
+		if s := literal(e.Value); strings.HasPrefix(s, "-") {
+			p.print("(", s, ")")
+		} else {
+			p.print(s)
+		}
+	default:
+		p.expr(e)
+	}
+}
+
+func (p *printer) fields(fields []*Field) {
+	for i, f := range fields {
+		if i > 0 {
+			p.print(",")
+		}
+		p.print(" ", f.Name, " = ")
+		p.expr(f.Expr)
+		p.print(" ")
+	}
+}
+
+func (p *printer) ctor(c *Constructor) {
+	switch c {
+	case Nil:
+		p.print(c.Name)
+		return
+	case Cons:
+		p.print(name(c.Name))
+		return
+	}
+	p.print(c.Union.Module, ".", c.Name)
+}
+
+func (p *printer) pattern(pat Pattern) {
+	switch pat := pat.(type) {
+	case *CtorPattern:
+		p.ctor(pat.Ctor)
+		for _, v := range pat.Vars {
+			p.print(" ", v)
+		}
+	case *TuplePattern:
+		p.print("(", strings.Join(pat.Vars, ", "), ")")
+	case *LitPattern:
+		p.print(literal(pat.Value))
+	default:
+		panic(fmt.Errorf("ir: unable to print pattern of type %T", pat))
+	}
+}
+
+// literal returns the Elm representation of a literal value.
+func literal(v interface{}) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eIN") {
+			s += ".0"
+		}
+		return s
+	case string:
+		return strconv.Quote(v)
+	case rune:
+		return strconv.QuoteRune(v)
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	default:
+		return fmt.Sprintf("<%T %v>", v, v)
+	}
+}
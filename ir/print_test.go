@@ -0,0 +1,44 @@
+package ir
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFprint(t *testing.T) {
+	require := require.New(t)
+
+	maybe := &Union{Module: "Maybe", Name: "Maybe"}
+	just := &Constructor{Union: maybe, Name: "Just", Tag: 0, Arity: 1}
+	maybe.Ctors = []*Constructor{just, {Union: maybe, Name: "Nothing", Tag: 1}}
+
+	mod := &Module{
+		Name:   "Maybe",
+		Unions: []*Union{maybe},
+		Defs: []*Def{
+			{Name: "negative", Expr: &App{Func: &Global{"Basics", "negate"}, Args: []Expr{&Lit{int64(-1)}}}},
+			{Name: "values", Expr: &Tuple{[]Expr{&Lit{2.0}, &Lit{'a'}, &Lit{"b"}, &Lit{false}}}},
+			{Name: "wrap", Expr: &Lambda{[]string{"x"}, &App{Func: just, Args: []Expr{&Local{"x"}}}}},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(Fprint(&buf, mod))
+	require.Equal(lines(
+		"module Maybe",
+		"",
+		"type Maybe = Just _ | Nothing",
+		"",
+		"negative =",
+		"    Basics.negate (-1)",
+		"",
+		"values =",
+		`    (2.0, 'a', "b", False)`,
+		"",
+		"wrap =",
+		"    \\x -> Maybe.Just x",
+		"",
+	), buf.String())
+}
@@ -0,0 +1,151 @@
+package ir
+
+import (
+	"sort"
+
+	"github.com/elm-tangram/tangram/ast"
+)
+
+// Roots returns the entry points of the program or library whose main
+// module is the given one, which must have been resolved. If the module
+// defines main, it is the only root. Otherwise, the roots are all the values
+// and union types exposed by the module, sorted by name.
+func Roots(mod *ast.Module) []Global {
+	if obj := mod.Scope.LookupSelf("main", ast.Var); obj != nil {
+		return []Global{{Module: mod.Name, Name: "main"}}
+	}
+
+	var roots []Global
+	for name, obj := range mod.Scope.Exposed {
+		switch obj.Kind {
+		case ast.Var:
+			roots = append(roots, Global{Module: mod.Name, Name: name})
+		case ast.Ctor:
+			if c, ok := obj.Node.(*ast.Constructor); ok {
+				if u := unionOf(mod, c); u != nil {
+					roots = append(roots, Global{Module: mod.Name, Name: u.Name.Name})
+				}
+			}
+		}
+	}
+
+	sort.Slice(roots, func(i, j int) bool {
+		return roots[i].Name < roots[j].Name
+	})
+	return roots
+}
+
+// unionOf returns the declaration of the union type the constructor belongs
+// to.
+func unionOf(mod *ast.Module, ctor *ast.Constructor) *ast.UnionDecl {
+	for _, decl := range mod.Decls {
+		if u, ok := decl.(*ast.UnionDecl); ok {
+			for _, c := range u.Ctors {
+				if c == ctor {
+					return u
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Prune returns a copy of the package with only the top-level definitions
+// that are reachable from the given roots, and the union types of the
+// constructors they use. A root can also name a union type, which is kept
+// along with all its constructors. Modules left with nothing are removed.
+// The package given is not modified.
+func Prune(pkg *Package, roots []Global) *Package {
+	p := &pruner{
+		pkg:    pkg,
+		defs:   make(map[Global]bool),
+		unions: make(map[*Union]bool),
+	}
+
+	for _, r := range roots {
+		p.global(r)
+		if mod, ok := pkg.Modules[r.Module]; ok {
+			for _, u := range mod.Unions {
+				if u.Name == r.Name {
+					p.unions[u] = true
+				}
+			}
+		}
+	}
+
+	for len(p.queue) > 0 {
+		d := p.queue[0]
+		p.queue = p.queue[1:]
+		p.expr(d.Expr)
+	}
+
+	result := &Package{Modules: make(map[string]*Module)}
+	for _, name := range pkg.Order {
+		mod := pkg.Modules[name]
+		m := &Module{Name: mod.Name}
+		for _, u := range mod.Unions {
+			if p.unions[u] {
+				m.Unions = append(m.Unions, u)
+			}
+		}
+
+		for _, d := range mod.Defs {
+			if p.defs[Global{name, d.Name}] {
+				m.Defs = append(m.Defs, d)
+			}
+		}
+
+		if len(m.Unions) > 0 || len(m.Defs) > 0 {
+			result.Order = append(result.Order, name)
+			result.Modules[name] = m
+		}
+	}
+	return result
+}
+
+type pruner struct {
+	pkg *Package
+	// defs are the top-level definitions reached.
+	defs map[Global]bool
+	// unions are the union types reached.
+	unions map[*Union]bool
+	// queue are the definitions reached whose references are not visited
+	// yet.
+	queue []*Def
+}
+
+// global marks the top-level definition as reached, if it is not a native
+// one.
+func (p *pruner) global(g Global) {
+	if p.defs[g] {
+		return
+	}
+
+	mod, ok := p.pkg.Modules[g.Module]
+	if !ok {
+		return
+	}
+
+	if d := mod.Lookup(g.Name); d != nil {
+		p.defs[g] = true
+		p.queue = append(p.queue, d)
+	}
+}
+
+func (p *pruner) expr(e Expr) {
+	WalkFunc(e, func(e Expr) bool {
+		switch e := e.(type) {
+		case *Global:
+			p.global(*e)
+		case *Constructor:
+			p.unions[e.Union] = true
+		case *Case:
+			for _, b := range e.Branches {
+				if c, ok := b.Pattern.(*CtorPattern); ok {
+					p.unions[c.Ctor.Union] = true
+				}
+			}
+		}
+		return true
+	})
+}
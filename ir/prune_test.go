@@ -0,0 +1,61 @@
+package ir
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func defNames(mod *Module) []string {
+	var names []string
+	for _, d := range mod.Defs {
+		names = append(names, d.Name)
+	}
+	return names
+}
+
+func unionNames(mod *Module) []string {
+	var names []string
+	for _, u := range mod.Unions {
+		names = append(names, u.Name)
+	}
+	return names
+}
+
+func TestPrune(t *testing.T) {
+	require := require.New(t)
+
+	parsed, cm := parseFixture(t, "Prune.elm")
+	pkg, err := Desugar(parsed, cm)
+	require.NoError(err)
+
+	roots := Roots(parsed.Modules["Prune"])
+	require.Equal([]Global{{"Prune", "main"}}, roots)
+
+	result := Prune(pkg, roots)
+	require.Equal([]string{"Maybe", "Prune"}, result.Order)
+	require.Equal([]string{"withDefault"}, defNames(result.Modules["Maybe"]))
+	require.Equal([]string{"Maybe"}, unionNames(result.Modules["Maybe"]))
+	require.Equal([]string{"main", "used"}, defNames(result.Modules["Prune"]))
+	require.Equal([]string{"Used"}, unionNames(result.Modules["Prune"]))
+
+	// the original package is not modified
+	require.Len(pkg.Modules["Prune"].Defs, 3)
+}
+
+func TestPruneLibrary(t *testing.T) {
+	require := require.New(t)
+
+	parsed, cm := parseFixture(t, "Library.elm")
+	pkg, err := Desugar(parsed, cm)
+	require.NoError(err)
+
+	roots := Roots(parsed.Modules["Library"])
+	require.Equal([]Global{{"Library", "Shape"}, {"Library", "area"}}, roots)
+
+	result := Prune(pkg, roots)
+	require.Equal([]string{"Basics", "Library"}, result.Order)
+	require.Equal([]string{"*"}, defNames(result.Modules["Basics"]))
+	require.Equal([]string{"area"}, defNames(result.Modules["Library"]))
+	require.Equal([]string{"Shape"}, unionNames(result.Modules["Library"]))
+}
@@ -0,0 +1,243 @@
+package ir
+
+// TailCalls returns a copy of the package in which the functions that call
+// themselves in tail position with all their arguments evaluate their body
+// in a Loop, and those calls are replaced by a Recur, so they run in
+// constant stack space. This applies to the top-level definitions as well as
+// to the functions defined in lets.
+//
+// The definitions that are still recursive afterwards, because they call
+// themselves somewhere else or through other definitions, are marked as
+// Recursive, so backends can guard the depth of the stack when they are
+// called and report an error instead of overflowing it.
+//
+// The package given is not modified.
+func TailCalls(pkg *Package) *Package {
+	result := &Package{
+		Order:   pkg.Order,
+		Modules: make(map[string]*Module, len(pkg.Modules)),
+	}
+
+	refs := make(map[Global][]Global)
+	for _, name := range pkg.Order {
+		mod := pkg.Modules[name]
+		m := &Module{Name: mod.Name, Unions: mod.Unions}
+		for _, d := range mod.Defs {
+			self := Global{name, d.Name}
+			def := loopDef(d, func(e Expr) bool {
+				g, ok := e.(*Global)
+				return ok && *g == self
+			}, "")
+
+			walkGlobals(def.Expr, func(g Global) {
+				refs[self] = append(refs[self], g)
+			})
+			m.Defs = append(m.Defs, def)
+		}
+		result.Modules[name] = m
+	}
+
+	cycles := cyclic(refs)
+	for _, name := range result.Order {
+		for _, d := range result.Modules[name].Defs {
+			d.Recursive = cycles[Global{name, d.Name}]
+		}
+	}
+	return result
+}
+
+// loopDef returns a copy of the definition in which the self tail calls are
+// turned into a loop. isSelf reports whether an expression refers to the
+// definition, and local is its name if it is not a top-level one, so the
+// places where it is shadowed are skipped.
+func loopDef(d *Def, isSelf func(Expr) bool, local string) *Def {
+	expr := tailCalls(d.Expr)
+	if fn, ok := expr.(*Lambda); ok && !hasName(fn.Params, local) {
+		l := &looper{isSelf: isSelf, local: local, arity: len(fn.Params)}
+		if body := l.tail(fn.Body); l.found {
+			expr = &Lambda{
+				Params: fn.Params,
+				Body:   &Loop{Vars: fn.Params, Body: body},
+			}
+		}
+	}
+	return &Def{Pos: d.Pos, Name: d.Name, Expr: expr}
+}
+
+// looper replaces the self tail calls of a function by a Recur.
+type looper struct {
+	isSelf func(Expr) bool
+	local  string
+	arity  int
+	// found reports whether some call was replaced.
+	found bool
+}
+
+// tail returns a copy of the expression, which is in tail position, with
+// the self tail calls replaced.
+func (l *looper) tail(expr Expr) Expr {
+	switch e := expr.(type) {
+	case *App:
+		if l.isSelf(e.Func) && len(e.Args) == l.arity {
+			l.found = true
+			return &Recur{Pos: e.Pos, Args: e.Args}
+		}
+	case *Let:
+		for _, d := range e.Defs {
+			if d.Name == l.local {
+				return e
+			}
+		}
+		return &Let{Defs: e.Defs, Body: l.tail(e.Body)}
+	case *Case:
+		c := &Case{Pos: e.Pos, Subject: e.Subject}
+		for _, b := range e.Branches {
+			body := b.Body
+			if !binds(b.Pattern, l.local) {
+				body = l.tail(body)
+			}
+			c.Branches = append(c.Branches, &Branch{Pattern: b.Pattern, Body: body})
+		}
+
+		if e.Default != nil {
+			c.Default = l.tail(e.Default)
+		}
+		return c
+	}
+	return expr
+}
+
+// tailCalls returns a copy of the expression in which the self tail calls of
+// the functions defined in its lets are turned into loops.
+func tailCalls(expr Expr) Expr {
+	switch e := expr.(type) {
+	case *Lambda:
+		return &Lambda{Params: e.Params, Body: tailCalls(e.Body)}
+	case *App:
+		return &App{Pos: e.Pos, Func: tailCalls(e.Func), Args: tailCallsAll(e.Args)}
+	case *Let:
+		return &Let{Defs: letLoops(e.Defs), Body: tailCalls(e.Body)}
+	case *Case:
+		c := &Case{Pos: e.Pos, Subject: e.Subject}
+		for _, b := range e.Branches {
+			c.Branches = append(c.Branches, &Branch{Pattern: b.Pattern, Body: tailCalls(b.Body)})
+		}
+
+		if e.Default != nil {
+			c.Default = tailCalls(e.Default)
+		}
+		return c
+	case *Tuple:
+		return &Tuple{tailCallsAll(e.Elems)}
+	case *Record:
+		return &Record{tailCallsFields(e.Fields)}
+	case *Access:
+		return &Access{Record: tailCalls(e.Record), Field: e.Field}
+	case *Update:
+		return &Update{Record: tailCalls(e.Record), Fields: tailCallsFields(e.Fields)}
+	case *Crash:
+		if e.Arg == nil {
+			return e
+		}
+		return &Crash{Pos: e.Pos, Arg: tailCalls(e.Arg)}
+	case *Loop:
+		return &Loop{Vars: e.Vars, Body: tailCalls(e.Body)}
+	case *Recur:
+		return &Recur{Pos: e.Pos, Args: tailCallsAll(e.Args)}
+	default:
+		return expr
+	}
+}
+
+// letLoops turns the self tail calls of the definitions of a let into loops
+// and marks the ones that are still recursive.
+func letLoops(defs []*Def) []*Def {
+	result := make([]*Def, len(defs))
+	for i, d := range defs {
+		name := d.Name
+		result[i] = loopDef(d, func(e Expr) bool {
+			l, ok := e.(*Local)
+			return ok && l.Name == name
+		}, name)
+	}
+
+	refs := make(map[Global][]Global)
+	for _, d := range result {
+		self := Global{Name: d.Name}
+		for _, other := range result {
+			if references(d.Expr, other.Name) {
+				refs[self] = append(refs[self], Global{Name: other.Name})
+			}
+		}
+	}
+
+	cycles := cyclic(refs)
+	for _, d := range result {
+		d.Recursive = cycles[Global{Name: d.Name}]
+	}
+	return result
+}
+
+func tailCallsAll(exprs []Expr) []Expr {
+	result := make([]Expr, len(exprs))
+	for i, e := range exprs {
+		result[i] = tailCalls(e)
+	}
+	return result
+}
+
+func tailCallsFields(fields []*Field) []*Field {
+	result := make([]*Field, len(fields))
+	for i, f := range fields {
+		result[i] = &Field{Name: f.Name, Expr: tailCalls(f.Expr)}
+	}
+	return result
+}
+
+// cyclic returns the nodes of the graph given by the edges that are part of
+// a cycle, that is, the ones that can reach themselves.
+func cyclic(edges map[Global][]Global) map[Global]bool {
+	result := make(map[Global]bool)
+	for start := range edges {
+		visited := make(map[Global]bool)
+		var reaches func(from Global) bool
+		reaches = func(from Global) bool {
+			for _, to := range edges[from] {
+				if to == start {
+					return true
+				}
+
+				if !visited[to] {
+					visited[to] = true
+					if reaches(to) {
+						return true
+					}
+				}
+			}
+			return false
+		}
+
+		result[start] = reaches(start)
+	}
+	return result
+}
+
+// binds reports whether the pattern binds a variable with the given name.
+func binds(pattern Pattern, name string) bool {
+	switch p := pattern.(type) {
+	case *CtorPattern:
+		return hasName(p.Vars, name)
+	case *TuplePattern:
+		return hasName(p.Vars, name)
+	}
+	return false
+}
+
+func hasName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package ir
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTailCalls(t *testing.T) {
+	pkg := desugarFixture(t, "TailCall.elm")
+	mod := TailCalls(pkg).Modules["TailCall"]
+	require.NotNil(t, mod)
+
+	cases := []struct {
+		name      string
+		recursive bool
+		expected  string
+	}{
+		{"sum", false, lines(
+			"sum =",
+			"    \\acc list ->",
+			"        loop acc list ->",
+			"            case list of",
+			"                [] ->",
+			"                    acc",
+			"                (::) x rest ->",
+			"                    recur (Basics.(+) acc x) rest",
+		)},
+		{"length", false, lines(
+			"length =",
+			"    \\list ->",
+			"        let",
+			"            go =",
+			"                \\n xs ->",
+			"                    loop n xs ->",
+			"                        case xs of",
+			"                            [] ->",
+			"                                n",
+			"                            (::) _ rest ->",
+			"                                recur (Basics.(+) n 1) rest",
+			"        in",
+			"            go 0 list",
+		)},
+		{"fact", true, ""},
+		{"isEven", true, ""},
+		{"isOdd", true, ""},
+		{"partial", true, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			def := mod.Lookup(c.name)
+			require.NotNil(t, def)
+			require.Equal(t, c.recursive, def.Recursive)
+			if c.expected != "" {
+				require.Equal(t, c.expected, DefString(def))
+			}
+		})
+	}
+
+	let := mod.Lookup("length").Expr.(*Lambda).Body.(*Let)
+	require.False(t, let.Defs[0].Recursive, "go")
+
+	// the original package is not modified
+	_, ok := pkg.Modules["TailCall"].Lookup("sum").Expr.(*Lambda).Body.(*Loop)
+	require.False(t, ok)
+}
+
+func TestTailCallsLet(t *testing.T) {
+	require := require.New(t)
+
+	// go is shadowed by the variable bound in the branch, so the call in it
+	// is not a self call
+	call := &App{Func: &Local{"go"}, Args: []Expr{&Local{"x"}}}
+	expr := &Let{
+		Defs: []*Def{{Name: "go", Expr: &Lambda{[]string{"x"}, &Case{
+			Subject: "x",
+			Branches: []*Branch{
+				{&CtorPattern{Cons, []string{"go", "_"}}, call},
+			},
+			Default: &App{Func: &Local{"go"}, Args: []Expr{&Tuple{}}},
+		}}}},
+		Body: call,
+	}
+
+	let := tailCalls(expr).(*Let)
+	require.Equal(lines(
+		"go =",
+		"    \\x ->",
+		"        loop x ->",
+		"            case x of",
+		"                (::) go _ ->",
+		"                    go x",
+		"                _ ->",
+		"                    recur ()",
+	), DefString(let.Defs[0]))
+}
@@ -0,0 +1,55 @@
+package ir
+
+// WalkFunc traverses an expression in depth-first order, calling fn with
+// every expression it contains, starting with the expression itself. The
+// children of an expression are not visited if fn returns false. The bodies
+// of the definitions in lets and of the branches of cases are visited, but
+// the definitions and branches themselves are not expressions.
+func WalkFunc(e Expr, fn func(Expr) bool) {
+	if e == nil || !fn(e) {
+		return
+	}
+
+	switch e := e.(type) {
+	case *Lambda:
+		WalkFunc(e.Body, fn)
+	case *App:
+		WalkFunc(e.Func, fn)
+		for _, a := range e.Args {
+			WalkFunc(a, fn)
+		}
+	case *Let:
+		for _, d := range e.Defs {
+			WalkFunc(d.Expr, fn)
+		}
+		WalkFunc(e.Body, fn)
+	case *Case:
+		for _, b := range e.Branches {
+			WalkFunc(b.Body, fn)
+		}
+		WalkFunc(e.Default, fn)
+	case *Tuple:
+		for _, el := range e.Elems {
+			WalkFunc(el, fn)
+		}
+	case *Record:
+		for _, f := range e.Fields {
+			WalkFunc(f.Expr, fn)
+		}
+	case *Access:
+		WalkFunc(e.Record, fn)
+	case *Update:
+		WalkFunc(e.Record, fn)
+		for _, f := range e.Fields {
+			WalkFunc(f.Expr, fn)
+		}
+	case *Crash:
+		WalkFunc(e.Arg, fn)
+	case *Loop:
+		WalkFunc(e.Body, fn)
+	case *Recur:
+		for _, a := range e.Args {
+			WalkFunc(a, fn)
+		}
+	}
+}
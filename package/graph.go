@@ -1,12 +1,20 @@
 package pkg
 
-import "fmt"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
 
 // Graph represents a dependency graph.
 type Graph struct {
 	// Node is the root node of the graph, which is always one module.
 	root  *node
 	nodes map[string]*node
+	// modules are all the modules in the graph in the order they were added.
+	modules []string
 }
 
 // NewGraph creates a new graph with the given root module.
@@ -17,6 +25,7 @@ func NewGraph(root string) *Graph {
 		nodes: map[string]*node{
 			root: rootNode,
 		},
+		modules: []string{root},
 	}
 }
 
@@ -26,6 +35,13 @@ func (g *Graph) Add(to, from string) *Graph {
 	return g
 }
 
+// AddModule adds a module to the graph, even if nothing depends on it and it
+// does not depend on anything.
+func (g *Graph) AddModule(module string) *Graph {
+	g.node(module)
+	return g
+}
+
 func (g *Graph) node(module string) *node {
 	if n, ok := g.nodes[module]; ok {
 		return n
@@ -33,9 +49,135 @@ func (g *Graph) node(module string) *node {
 
 	n := newNode(module)
 	g.nodes[module] = n
+	g.modules = append(g.modules, module)
 	return n
 }
 
+// Root returns the root module of the graph.
+func (g *Graph) Root() string {
+	return g.root.module
+}
+
+// Modules returns all the modules in the graph in the order they were added.
+func (g *Graph) Modules() []string {
+	modules := make([]string, len(g.modules))
+	copy(modules, g.modules)
+	return modules
+}
+
+// Dependencies returns the modules the given module directly depends on, in
+// the order they were added.
+func (g *Graph) Dependencies(module string) []string {
+	n, ok := g.nodes[module]
+	if !ok {
+		return nil
+	}
+
+	deps := make([]string, len(n.dependants))
+	copy(deps, n.dependants)
+	return deps
+}
+
+// TransitiveDependencies returns all the modules the given module depends on,
+// either directly or through other modules. The module itself is only
+// included if it's part of a cycle.
+func (g *Graph) TransitiveDependencies(module string) []string {
+	n, ok := g.nodes[module]
+	if !ok {
+		return nil
+	}
+
+	var deps []string
+	visited := make(moduleSet)
+	var visit func(*node)
+	visit = func(n *node) {
+		for _, mod := range n.dependants {
+			if !visited.contains(mod) {
+				visited.add(mod)
+				deps = append(deps, mod)
+				visit(n.edges[mod])
+			}
+		}
+	}
+	visit(n)
+	return deps
+}
+
+// Dependents returns the modules that directly depend on the given module,
+// in the order they were added to the graph.
+func (g *Graph) Dependents(module string) []string {
+	var dependents []string
+	for _, mod := range g.modules {
+		if _, ok := g.nodes[mod].edges[module]; ok {
+			dependents = append(dependents, mod)
+		}
+	}
+	return dependents
+}
+
+// Unreachable returns the modules that are not reachable from any of the
+// given entry modules, in the order they were added to the graph. If no entry
+// modules are given, the root of the graph is used.
+func (g *Graph) Unreachable(entries ...string) []string {
+	if len(entries) == 0 {
+		entries = []string{g.root.module}
+	}
+
+	reachable := make(moduleSet)
+	for _, e := range entries {
+		reachable.add(e)
+		for _, mod := range g.TransitiveDependencies(e) {
+			reachable.add(mod)
+		}
+	}
+
+	var unreachable []string
+	for _, mod := range g.modules {
+		if !reachable.contains(mod) {
+			unreachable = append(unreachable, mod)
+		}
+	}
+	return unreachable
+}
+
+// Levels returns all the modules in the graph grouped by their depth level.
+// Modules with no dependencies are in the first level and the rest of the
+// modules are in the level right after the deepest of their dependencies. All
+// the modules in a level can be resolved once the previous levels have been
+// resolved. If there is a cycle in the graph, a CircularDependencyError is
+// returned.
+func (g *Graph) Levels() ([][]string, error) {
+	ctx := newResolutionCtx()
+	for _, mod := range g.modules {
+		if ctx.resolved.contains(mod) {
+			continue
+		}
+
+		if err := g.nodes[mod].resolve(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var levels [][]string
+	depth := make(map[string]int, len(ctx.nodes))
+	for _, mod := range ctx.nodes {
+		var level int
+		for _, dep := range g.nodes[mod].dependants {
+			if depth[dep]+1 > level {
+				level = depth[dep] + 1
+			}
+		}
+
+		depth[mod] = level
+		if level == len(levels) {
+			levels = append(levels, nil)
+		}
+		levels[level] = append(levels[level], mod)
+	}
+
+	return levels, nil
+}
+
 // Resolve returns a list of nodes in the exact order in which they need to be
 // resolved. A graph with the exact same nodes in the exact same order produces
 // an output exactly equal no matter how many times it's called.
@@ -48,6 +190,51 @@ func (g *Graph) Resolve() ([]string, error) {
 	return ctx.nodes, nil
 }
 
+// WriteDOT writes the graph in the DOT language used by Graphviz. Every edge
+// goes from a module to one of its dependencies.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("digraph dependencies {\n")
+	for _, mod := range g.modules {
+		n := g.nodes[mod]
+		if len(n.dependants) == 0 {
+			fmt.Fprintf(&buf, "\t%q;\n", mod)
+		}
+
+		for _, dep := range n.dependants {
+			fmt.Fprintf(&buf, "\t%q -> %q;\n", mod, dep)
+		}
+	}
+	buf.WriteString("}\n")
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+type jsonGraph struct {
+	Root    string       `json:"root"`
+	Modules []jsonModule `json:"modules"`
+}
+
+type jsonModule struct {
+	Name         string   `json:"name"`
+	Dependencies []string `json:"dependencies"`
+}
+
+// MarshalJSON encodes the graph as a JSON object with the root module and a
+// list of all the modules with their direct dependencies.
+func (g *Graph) MarshalJSON() ([]byte, error) {
+	graph := jsonGraph{Root: g.root.module}
+	for _, mod := range g.modules {
+		deps := g.Dependencies(mod)
+		if deps == nil {
+			deps = []string{}
+		}
+		graph.Modules = append(graph.Modules, jsonModule{mod, deps})
+	}
+	return json.Marshal(graph)
+}
+
 type node struct {
 	module     string
 	edges      map[string]*node
@@ -70,11 +257,12 @@ func (n *node) add(node *node) {
 
 func (n *node) resolve(ctx *resolutionCtx) error {
 	ctx.unresolved.add(n.module)
+	ctx.stack = append(ctx.stack, n.module)
 
 	for _, mod := range n.dependants {
 		if !ctx.resolved.contains(mod) {
 			if ctx.unresolved.contains(mod) {
-				return NewCircularDependencyError(n.module, mod)
+				return NewCircularDependencyError(ctx.cycle(mod)...)
 			}
 
 			if err := n.edges[mod].resolve(ctx); err != nil {
@@ -84,6 +272,7 @@ func (n *node) resolve(ctx *resolutionCtx) error {
 	}
 
 	delete(ctx.unresolved, n.module)
+	ctx.stack = ctx.stack[:len(ctx.stack)-1]
 	ctx.resolved.add(n.module)
 	ctx.nodes = append(ctx.nodes, n.module)
 	return nil
@@ -104,6 +293,24 @@ type resolutionCtx struct {
 	nodes      []string
 	unresolved moduleSet
 	resolved   moduleSet
+	// stack contains the modules being resolved, each one being a dependency
+	// of the previous one.
+	stack []string
+}
+
+// cycle returns the cycle that is formed when the module at the top of the
+// stack depends on the given module, which is already in the stack.
+func (ctx *resolutionCtx) cycle(module string) []string {
+	var i int
+	for i = len(ctx.stack) - 1; i > 0; i-- {
+		if ctx.stack[i] == module {
+			break
+		}
+	}
+
+	cycle := make([]string, len(ctx.stack)-i, len(ctx.stack)-i+1)
+	copy(cycle, ctx.stack[i:])
+	return append(cycle, module)
 }
 
 func newResolutionCtx() *resolutionCtx {
@@ -114,21 +321,22 @@ func newResolutionCtx() *resolutionCtx {
 }
 
 // CircularDependencyError describes an error because there was a circular
-// dependency between two dependencies.
+// dependency between some modules.
 type CircularDependencyError struct {
-	// Modules that depended on each other.
-	Modules [2]string
+	// Modules that form the cycle, in which each module depends on the next
+	// one. The last module is always the same as the first one.
+	Modules []string
 }
 
-// NewCircularDependencyError returns a new CircularDependencyError.
-func NewCircularDependencyError(a, b string) *CircularDependencyError {
-	return &CircularDependencyError{[2]string{a, b}}
+// NewCircularDependencyError returns a new CircularDependencyError with the
+// given cycle of modules.
+func NewCircularDependencyError(modules ...string) *CircularDependencyError {
+	return &CircularDependencyError{modules}
 }
 
 func (e CircularDependencyError) Error() string {
 	return fmt.Sprintf(
-		"circular dependency error: %s -> %s",
-		e.Modules[0],
-		e.Modules[1],
+		"circular dependency error: %s",
+		strings.Join(e.Modules, " -> "),
 	)
 }
@@ -1,6 +1,8 @@
 package pkg
 
 import (
+	"bytes"
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -45,6 +47,86 @@ func TestCircularDep(t *testing.T) {
 	require.Error(t, err)
 	circular, ok := err.(*CircularDependencyError)
 	require.True(t, ok, "expected a CircularDependencyError")
-	require.Equal(t, [2]string{"f", "b"}, circular.Modules)
+	require.Equal(t, []string{"b", "e", "f", "b"}, circular.Modules)
 	require.Nil(t, nodes)
 }
+
+func newQueryGraph() *Graph {
+	return NewGraph("a").
+		Add("b", "a").
+		Add("c", "a").
+		Add("d", "b").
+		Add("d", "c").
+		Add("e", "d").
+		Add("f", "g").
+		AddModule("h")
+}
+
+func TestGraphQueries(t *testing.T) {
+	require := require.New(t)
+	g := newQueryGraph()
+
+	require.Equal([]string{"a", "b", "c", "d", "e", "g", "f", "h"}, g.Modules())
+	require.Equal([]string{"b", "c"}, g.Dependencies("a"))
+	require.Len(g.Dependencies("e"), 0)
+	require.Nil(g.Dependencies("z"))
+	require.Equal([]string{"b", "d", "e", "c"}, g.TransitiveDependencies("a"))
+	require.Equal([]string{"b", "c"}, g.Dependents("d"))
+	require.Len(g.Dependents("a"), 0)
+	require.Equal([]string{"g", "f", "h"}, g.Unreachable())
+	require.Equal([]string{"h"}, g.Unreachable("a", "g"))
+}
+
+func TestGraphLevels(t *testing.T) {
+	require := require.New(t)
+
+	levels, err := newQueryGraph().Levels()
+	require.NoError(err)
+	require.Equal([][]string{
+		{"e", "f", "h"},
+		{"d", "g"},
+		{"b", "c"},
+		{"a"},
+	}, levels)
+
+	_, err = NewGraph("a").Add("b", "a").Add("a", "b").Levels()
+	require.Error(err)
+}
+
+const expectedDOT = `digraph dependencies {
+	"a" -> "b";
+	"a" -> "c";
+	"b" -> "d";
+	"c" -> "d";
+	"d" -> "e";
+	"e";
+	"g" -> "f";
+	"f";
+	"h";
+}
+`
+
+func TestGraphExport(t *testing.T) {
+	require := require.New(t)
+	g := newQueryGraph()
+
+	var buf bytes.Buffer
+	require.NoError(g.WriteDOT(&buf))
+	require.Equal(expectedDOT, buf.String())
+
+	data, err := json.Marshal(g)
+	require.NoError(err)
+	require.JSONEq(`{
+		"root": "a",
+		"modules": [
+			{"name": "a", "dependencies": ["b", "c"]},
+			{"name": "b", "dependencies": ["d"]},
+			{"name": "c", "dependencies": ["d"]},
+			{"name": "d", "dependencies": ["e"]},
+			{"name": "e", "dependencies": []},
+			{"name": "g", "dependencies": ["f"]},
+			{"name": "f", "dependencies": []},
+			{"name": "h", "dependencies": []}
+		]
+	}`, string(data))
+}
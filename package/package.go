@@ -7,7 +7,9 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 )
 
 const (
@@ -17,6 +19,7 @@ const (
 	elmStuffDir   = "elm-stuff"
 	packagesDir   = "packages"
 	exactDepsFile = "exact-dependencies.json"
+	cacheDir      = "tangram"
 )
 
 var (
@@ -53,6 +56,9 @@ type Package struct {
 	// moduleCache keeps the resolved paths for modules so they don't have to
 	// looked up again
 	moduleCache map[string]string
+	// mu guards moduleCache, so modules can be looked up from multiple
+	// goroutines at the same time
+	mu sync.RWMutex
 }
 
 // Root returns the package root.
@@ -60,10 +66,31 @@ func (p *Package) Root() string {
 	return p.root
 }
 
+// CacheDir returns the directory where the build cache of the package is
+// stored, inside elm-stuff.
+func (p *Package) CacheDir() string {
+	return filepath.Join(p.root, elmStuffDir, cacheDir)
+}
+
+// IsDependencyFile reports whether the file at the given path belongs to one
+// of the installed dependencies of the package.
+func (p *Package) IsDependencyFile(path string) bool {
+	return strings.HasPrefix(path, filepath.Join(p.root, elmStuffDir, packagesDir)+separator)
+}
+
 func (p *Package) cacheModule(module string, filePath string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.moduleCache[module] = filePath
 }
 
+func (p *Package) cachedModule(module string) (string, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	filePath, ok := p.moduleCache[module]
+	return filePath, ok
+}
+
 // tryLoadExactDependencies will try to load the "exact-dependencies.json" file
 // inside elm-stuff director. If it's not found, it will be assumed the deps
 // have not been installed and will do nothing.
@@ -104,7 +131,7 @@ func (p *Package) FindModule(path string) (string, error) {
 // FindSourceModule tries to find a module with the given path in all the
 // source directories.
 func (p *Package) FindSourceModule(path string) (string, error) {
-	if cachedPath, ok := p.moduleCache[path]; ok {
+	if cachedPath, ok := p.cachedModule(path); ok {
 		return cachedPath, nil
 	}
 
@@ -124,7 +151,7 @@ func (p *Package) FindSourceModule(path string) (string, error) {
 // FindDependencyModule will try to find a module with the given path in all
 // the dependency directories.
 func (p *Package) FindDependencyModule(path string) (string, error) {
-	if cachedPath, ok := p.moduleCache[path]; ok {
+	if cachedPath, ok := p.cachedModule(path); ok {
 		return cachedPath, nil
 	}
 
@@ -159,6 +186,46 @@ func (p *Package) FindDependencyModule(path string) (string, error) {
 	return "", ErrModuleNotFound
 }
 
+// SourceModules returns the names of all the modules in the source
+// directories of the package, sorted alphabetically.
+func (p *Package) SourceModules() ([]string, error) {
+	var modules []string
+	for _, dir := range p.SourceDirectories {
+		root := filepath.Join(p.root, dir)
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				if info.Name() == elmStuffDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if filepath.Ext(path) != ext {
+				return nil
+			}
+
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+
+			module := strings.Replace(strings.TrimSuffix(rel, ext), separator, ".", -1)
+			modules = append(modules, module)
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("pkg: can't list modules in %q: %s", dir, err)
+		}
+	}
+
+	sort.Strings(modules)
+	return modules, nil
+}
+
 func (p *Package) findModuleInDir(pathParts []string, dir string) (string, error) {
 	var path = filepath.Join(p.root, dir)
 	for i, p := range pathParts {
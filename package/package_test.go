@@ -156,6 +156,36 @@ func TestFindModule(t *testing.T) {
 	}
 }
 
+func TestIsDependencyFile(t *testing.T) {
+	require := require.New(t)
+	root, err := createStructure(validPackageEntries...)
+	require.NoError(err)
+
+	pkg, err := Load(root)
+	require.NoError(err)
+
+	path, err := pkg.FindModule("Foo.Bar.Baz.Qux")
+	require.NoError(err)
+	require.True(pkg.IsDependencyFile(path))
+
+	path, err = pkg.FindModule("Foo.Bar")
+	require.NoError(err)
+	require.False(pkg.IsDependencyFile(path))
+}
+
+func TestSourceModules(t *testing.T) {
+	require := require.New(t)
+	root, err := createStructure(validPackageEntries...)
+	require.NoError(err)
+
+	pkg, err := Load(root)
+	require.NoError(err)
+
+	modules, err := pkg.SourceModules()
+	require.NoError(err)
+	require.Equal([]string{"Bar", "Foo", "Foo.Bar", "Foo.Bar.Baz"}, modules)
+}
+
 type entry struct {
 	file    string
 	content interface{}
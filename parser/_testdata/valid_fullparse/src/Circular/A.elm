@@ -0,0 +1,6 @@
+module Circular.A exposing (..)
+
+import Circular.B
+
+a : Int
+a = Circular.B.b
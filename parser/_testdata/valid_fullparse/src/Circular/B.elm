@@ -0,0 +1,6 @@
+module Circular.B exposing (..)
+
+import Circular.C
+
+b : Int
+b = Circular.C.c
@@ -0,0 +1,6 @@
+module Circular.C exposing (..)
+
+import Circular.A
+
+c : Int
+c = 1
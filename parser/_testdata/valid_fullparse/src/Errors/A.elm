@@ -0,0 +1,10 @@
+module Errors.A exposing (..)
+
+import Errors.B
+import Errors.C
+
+a : Int
+a = b + c + undefinedA
+
+undefinedAgain : Int
+undefinedAgain = missingA
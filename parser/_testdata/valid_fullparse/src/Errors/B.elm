@@ -0,0 +1,6 @@
+module Errors.B exposing (..)
+
+import Errors.C
+
+b : Int
+b = undefinedB
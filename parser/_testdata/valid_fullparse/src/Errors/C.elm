@@ -0,0 +1,4 @@
+module Errors.C exposing (..)
+
+c : Int
+c = undefinedC
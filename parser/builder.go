@@ -0,0 +1,541 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+
+	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/iface"
+	"github.com/elm-tangram/tangram/package"
+	"github.com/elm-tangram/tangram/report"
+	"github.com/elm-tangram/tangram/source"
+)
+
+// ErrBuildFailed is returned by a Builder when the package could not be built
+// and its emitter did not return the errors, as happens in StderrDiagnostics
+// mode. The reasons have already been emitted.
+var ErrBuildFailed = errors.New("parser: the package could not be built")
+
+// Builder parses and resolves a package incrementally. It keeps the result of
+// the previous build, so when it's told which files changed since then, only
+// the modules of those files are parsed again and only the modules whose
+// imported modules changed their exposed interface are resolved again.
+// A Builder must not be used from multiple goroutines at the same time.
+type Builder struct {
+	path    string
+	mode    ParseMode
+	pkg     *pkg.Package
+	cm      *source.CodeMap
+	overlay *source.OverlayLoader
+	emitter report.Emitter
+	// maxErrors is the maximum number of errors emitted by a build, or 0 if
+	// there is no limit.
+	maxErrors int
+
+	// headers contains the files parsed in the first pass of all the builds,
+	// indexed by path.
+	headers map[string]*header
+	// last is the package of the last build, or nil if it failed.
+	last *ast.Package
+}
+
+// BuildResult is the result of a build.
+type BuildResult struct {
+	// Package contains all the parsed and resolved modules.
+	Package *ast.Package
+	// Parsed contains the modules that have been parsed in this build, in
+	// resolution order.
+	Parsed []string
+	// Resolved contains the modules that have been resolved in this build,
+	// in resolution order.
+	Resolved []string
+}
+
+// NewBuilder creates a new builder for the package of the file at the given
+// path, which will be the root of the package. All modes of parsing but
+// JustModule and SkipDefinitions can be used.
+func NewBuilder(path string, mode ParseMode) (*Builder, error) {
+	if mode.Is(JustModule) || mode.Is(SkipDefinitions) {
+		return nil, fmt.Errorf("parser: a builder can only parse full packages")
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg, err := pkg.Load(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	overlay := source.NewOverlayLoader(source.NewBufferedLoader(source.NewFsLoader(pkg)))
+	return &Builder{
+		path:    path,
+		mode:    mode,
+		pkg:     pkg,
+		cm:      source.NewCodeMap(overlay),
+		overlay: overlay,
+		emitter: modeEmitter(mode),
+		headers: make(map[string]*header),
+	}, nil
+}
+
+// Overlay returns the loader of the files of the builder, which can be used
+// to override the content of the files in the file system with unsaved
+// buffers. The paths of the files whose override changes must be passed to
+// the next call to Rebuild. Dependency files loaded from the build cache do
+// not take overrides into account.
+func (b *Builder) Overlay() *source.OverlayLoader {
+	return b.overlay
+}
+
+// CodeMap returns the code map with the source files of the modules of the
+// last build, with the content they were parsed from, taking the overlay
+// into account. Dependency files loaded from the build cache are not in it.
+func (b *Builder) CodeMap() *source.CodeMap {
+	return b.cm
+}
+
+// SetEmitter sets the emitter of the diagnostics of the next builds. By
+// default, the emitter is the one of the mode of the builder.
+func (b *Builder) SetEmitter(emitter report.Emitter) {
+	b.emitter = emitter
+}
+
+// SetMaxErrors sets the maximum number of errors emitted by the next builds.
+// A value of 0 or less means there is no limit, which is the default.
+func (b *Builder) SetMaxErrors(n int) {
+	b.maxErrors = n
+}
+
+// Build parses and resolves the whole package. Modules that were built
+// successfully in a previous build and have not changed since are not built
+// again.
+func (b *Builder) Build() (*BuildResult, error) {
+	return b.build(nil)
+}
+
+// Rebuild builds the package again after the files at the given paths have
+// changed. Only the modules of the changed files, the modules that import
+// operators whose fixity has changed and the modules that were not in the
+// previous build are parsed again. Only the parsed modules and the ones
+// importing modules whose exposed interface has changed are resolved again.
+// If the previous build failed, all the modules are parsed and resolved
+// again, although the files that did not change are not read again.
+// The results of the previous builds are not modified.
+func (b *Builder) Rebuild(changed ...string) (*BuildResult, error) {
+	paths := make(map[string]struct{}, len(changed))
+	for _, path := range changed {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		paths[path] = struct{}{}
+	}
+	return b.build(paths)
+}
+
+// Close closes all the files read by the builder.
+func (b *Builder) Close() error {
+	return b.cm.Close()
+}
+
+func (b *Builder) build(changed map[string]struct{}) (result *BuildResult, err error) {
+	fp := newSessionParser(b.pkg, b.cm, b.mode, b.emitter)
+	fp.reporter.SetMaxErrors(b.maxErrors)
+
+	for path := range changed {
+		if err := b.cm.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	fixities := make(map[string]string)
+	for path, h := range b.headers {
+		if h == nil {
+			continue
+		}
+
+		if _, ok := changed[path]; ok {
+			fixities[h.file.Name] = headerFixities(h)
+			continue
+		}
+		fp.headers[path] = h
+	}
+
+	last := b.last
+	b.last = nil
+	b.headers = fp.headers
+
+	defer func() {
+		if emitErr := fp.p.sess.Emit(); emitErr != nil {
+			result, err = nil, emitErr
+		} else if result == nil {
+			err = ErrBuildFailed
+		}
+	}()
+	defer catchBailout()
+
+	fp.firstPass(b.path, make(map[string]struct{}))
+	modules := fp.resolveGraph(b.path)
+
+	// modules that have to be parsed again in spite of not having changed,
+	// because they use operators whose fixity has changed
+	reparse := make(map[string]struct{})
+	for mod, f := range fixities {
+		h := fp.headers[fp.modCache[mod]]
+		if h != nil && headerFixities(h) != f {
+			for _, dep := range fp.g.Dependents(mod) {
+				reparse[dep] = struct{}{}
+			}
+		}
+	}
+
+	pkg := fp.parseModules(modules, func(m string) *ast.Module {
+		if last == nil {
+			return nil
+		}
+
+		if _, ok := reparse[m]; ok {
+			return nil
+		}
+
+		if _, ok := changed[fp.modCache[m]]; ok {
+			return nil
+		}
+		return last.Modules[m]
+	})
+
+	s := newBuildState(fp, last, pkg)
+	fp.resolver.prepare = s.prepare
+	if !fp.resolver.resolve(pkg) {
+		return nil, nil
+	}
+
+	if fp.cache != nil {
+		fp.storeInterfaces(pkg)
+	}
+
+	b.last = pkg
+	return s.result(), nil
+}
+
+// headerFixities returns a string representation of all the fixity
+// declarations in the given header, so they can be compared.
+func headerFixities(h *header) string {
+	var result string
+	for _, d := range h.file.Decls {
+		if fixity, ok := d.(*ast.InfixDecl); ok {
+			result += fmt.Sprintf("%s %d %s;", fixity.Op.Name, fixity.Assoc, fixity.Precedence.Value)
+		}
+	}
+	return result
+}
+
+// buildState contains the state of a build needed to decide which of the
+// modules built in the previous build have to be resolved again.
+type buildState struct {
+	fp   *fullParser
+	last *ast.Package
+	pkg  *ast.Package
+	// scopes contains the scopes of all the modules at the start of the
+	// build, which are the ones of the previous build for reused modules.
+	scopes map[string]*ast.ModuleScope
+	// oldScopes contains the scopes of all the modules of the previous
+	// build.
+	oldScopes map[string]*ast.ModuleScope
+	// ifaces contains the interfaces of the modules of the previous build
+	// that have already been computed.
+	ifaces map[string]*iface.Interface
+	// copied contains the modules of the previous build that have been
+	// copied to be relinked or resolved again, so the previous build is not
+	// modified.
+	copied map[string]bool
+}
+
+func newBuildState(fp *fullParser, last *ast.Package, pkg *ast.Package) *buildState {
+	s := &buildState{
+		fp:        fp,
+		last:      last,
+		pkg:       pkg,
+		scopes:    make(map[string]*ast.ModuleScope, len(pkg.Order)),
+		oldScopes: make(map[string]*ast.ModuleScope),
+		ifaces:    make(map[string]*iface.Interface),
+		copied:    make(map[string]bool),
+	}
+
+	for _, m := range pkg.Order {
+		s.scopes[m] = pkg.Modules[m].Scope
+	}
+
+	if last != nil {
+		for _, m := range last.Order {
+			s.oldScopes[m] = last.Modules[m].Scope
+		}
+	}
+	return s
+}
+
+// prepare makes sure the modules of the previous build in the given level of
+// the dependency graph are up to date with the modules they import, which
+// have already been resolved. If the interface of any of the imported
+// modules changed, the module is marked to be resolved again. Otherwise, its
+// references to the objects of the imported modules are replaced with the
+// new ones. Modules are copied before being changed, as they are still part
+// of the result of the previous build.
+func (s *buildState) prepare(level []string) {
+	for _, m := range level {
+		mod := s.pkg.Modules[m]
+		if mod.Scope == nil || s.isStub(mod) {
+			continue
+		}
+
+		if s.importsChanged(mod) || !s.relink(m) {
+			s.unresolve(m)
+		}
+	}
+}
+
+// isStub reports whether the given module was created from its interface
+// instead of being parsed.
+func (s *buildState) isStub(mod *ast.Module) bool {
+	h := s.fp.headers[mod.Path]
+	return h != nil && h.cached != nil
+}
+
+// changed reports whether the module with the given name was parsed or
+// resolved in this build.
+func (s *buildState) changed(m string) bool {
+	mod := s.pkg.Modules[m]
+	return s.last.Modules[m] != mod || s.oldScopes[m] != mod.Scope
+}
+
+// importsChanged reports whether the exposed interface of any of the modules
+// imported by the given module has changed since the previous build.
+func (s *buildState) importsChanged(mod *ast.Module) bool {
+	for _, imp := range mod.Imports {
+		m := imp.ModuleName()
+		if _, ok := s.pkg.Modules[m]; !ok || !s.changed(m) {
+			continue
+		}
+
+		if s.last.Modules[m] == nil {
+			return true
+		}
+
+		old, new := s.iface(m), iface.New(s.pkg.Modules[m], "")
+		old.Imports, new.Imports = nil, nil
+		if !reflect.DeepEqual(old, new) {
+			return true
+		}
+	}
+	return false
+}
+
+// iface returns the interface the module with the given name had in the
+// previous build.
+func (s *buildState) iface(m string) *iface.Interface {
+	if i, ok := s.ifaces[m]; ok {
+		return i
+	}
+
+	old := *s.last.Modules[m]
+	old.Scope = s.oldScopes[m]
+	i := iface.New(&old, "")
+	s.ifaces[m] = i
+	return i
+}
+
+// relink replaces the module with the given name with a copy whose
+// references to objects of the modules it imports that have changed are
+// replaced with the objects of the new version of those modules. It returns
+// false, leaving the module untouched, if any of the referenced objects does
+// not exist anymore.
+func (s *buildState) relink(m string) bool {
+	mod := s.pkg.Modules[m]
+	var (
+		objects = make(map[*ast.Object]*ast.Object)
+		modules = make(map[ast.Node]*ast.Module)
+	)
+
+	for _, imp := range mod.Imports {
+		m := imp.ModuleName()
+		if _, ok := s.pkg.Modules[m]; !ok || !s.changed(m) {
+			continue
+		}
+
+		modules[s.last.Modules[m]] = s.pkg.Modules[m]
+		old, new := s.oldScopes[m], s.pkg.Modules[m].Scope
+		for _, objs := range []map[string]*ast.Object{old.Objects, old.Exposed} {
+			for name, obj := range objs {
+				objects[obj] = findObject(new, name, obj.Kind)
+			}
+		}
+	}
+
+	if len(modules) == 0 {
+		return true
+	}
+
+	for _, obj := range mod.Scope.Imported {
+		if new, ok := objects[obj]; ok && new == nil {
+			return false
+		}
+	}
+
+	var ok = true
+	ast.WalkFunc(mod, func(n ast.Node) bool {
+		if id, isIdent := n.(*ast.Ident); isIdent && id.Obj != nil {
+			if new, found := objects[id.Obj]; found && new == nil {
+				ok = false
+			}
+		}
+		return ok
+	})
+	if !ok {
+		return false
+	}
+
+	c := copyModule(mod, objects, modules)
+	s.pkg.Modules[m] = c
+	s.scopes[m] = c.Scope
+	s.copied[m] = true
+	return true
+}
+
+// copyModule returns a copy of the given resolved module along with its
+// scope and the objects defined in it, so the copy can be changed without
+// changing the original. The references to objects of other modules found
+// in objects and the imported modules found in modules are replaced with
+// their new versions.
+func copyModule(mod *ast.Module, objects map[*ast.Object]*ast.Object, modules map[ast.Node]*ast.Module) *ast.Module {
+	c := ast.Copy(mod).(*ast.Module)
+
+	nodes := make(map[ast.Node]ast.Node)
+	old, new := moduleNodes(mod), moduleNodes(c)
+	for i, n := range old {
+		nodes[n] = new[i]
+	}
+
+	copies := make(map[*ast.Object]*ast.Object)
+	copyObject := func(obj *ast.Object) *ast.Object {
+		o := *obj
+		if n, ok := nodes[obj.Node]; ok {
+			o.Node = n
+		} else if m, ok := modules[obj.Node]; ok {
+			o.Node = m
+		}
+		copies[obj] = &o
+		return &o
+	}
+
+	object := func(obj *ast.Object) *ast.Object {
+		if o, ok := copies[obj]; ok {
+			return o
+		}
+		if o, ok := objects[obj]; ok {
+			return o
+		}
+		return obj
+	}
+
+	var copyScope func(dst, src *ast.NodeScope)
+	copyScope = func(dst, src *ast.NodeScope) {
+		for name, obj := range src.Objects {
+			dst.Objects[name] = copyObject(obj)
+		}
+
+		for name, ids := range src.Unresolved {
+			for _, id := range ids {
+				dst.Unresolved[name] = append(dst.Unresolved[name], nodes[id].(*ast.Ident))
+			}
+		}
+
+		for _, child := range src.Children() {
+			var parent ast.Scope = dst
+			if src == mod.Scope.NodeScope {
+				parent = c.Scope
+			}
+			copyScope(ast.NewNodeScope(nodes[child.Root], parent), child)
+		}
+	}
+
+	c.Scope = ast.NewModuleScope(c)
+	copyScope(c.Scope.NodeScope, mod.Scope.NodeScope)
+	for name, obj := range mod.Scope.Modules {
+		c.Scope.Modules[name] = copyObject(obj)
+	}
+
+	for name, obj := range mod.Scope.Exposed {
+		c.Scope.Exposed[name] = object(obj)
+	}
+
+	for name, obj := range mod.Scope.Imported {
+		c.Scope.Imported[name] = object(obj)
+	}
+
+	ast.WalkFunc(c, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Obj != nil {
+			id.Obj = object(id.Obj)
+		}
+		return true
+	})
+	return c
+}
+
+// moduleNodes returns all the nodes of the given module, in the order they
+// are walked.
+func moduleNodes(mod *ast.Module) []ast.Node {
+	var nodes []ast.Node
+	ast.WalkFunc(mod, func(n ast.Node) bool {
+		if n != nil {
+			nodes = append(nodes, n)
+		}
+		return true
+	})
+	return nodes
+}
+
+// findObject returns the object with the given name and kind defined or
+// exposed in the given scope, if any.
+func findObject(scope *ast.ModuleScope, name string, kind ast.ObjKind) *ast.Object {
+	if obj := scope.Objects[name]; obj != nil && obj.Kind == kind {
+		return obj
+	}
+	return scope.LookupExposed(name, kind)
+}
+
+// unresolve replaces the module with the given name with a copy without its
+// scope and resolved objects, so it will be resolved again.
+func (s *buildState) unresolve(m string) {
+	c := ast.Copy(s.pkg.Modules[m]).(*ast.Module)
+	c.Scope = nil
+	ast.WalkFunc(c, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			id.Obj = nil
+		}
+		return true
+	})
+	s.pkg.Modules[m] = c
+	s.copied[m] = true
+}
+
+// result returns the result of the build once all the modules have been
+// resolved.
+func (s *buildState) result() *BuildResult {
+	r := &BuildResult{Package: s.pkg}
+	for _, m := range s.pkg.Order {
+		mod := s.pkg.Modules[m]
+		if s.last == nil || (s.last.Modules[m] != mod && !s.copied[m]) {
+			r.Parsed = append(r.Parsed, m)
+		}
+
+		if s.scopes[m] != mod.Scope {
+			r.Resolved = append(r.Resolved, m)
+		}
+	}
+	return r
+}
@@ -0,0 +1,264 @@
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/report"
+	"github.com/elm-tangram/tangram/token"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilder(t *testing.T) {
+	require := require.New(t)
+	wd, err := os.Getwd()
+	require.NoError(err)
+
+	root, err := ioutil.TempDir("", "tangram-builder")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+	require.NoError(copyDir(filepath.Join(wd, "_testdata", "valid_fullparse"), root))
+
+	b, err := NewBuilder(filepath.Join(root, "src", "Main.elm"), FullParse)
+	require.NoError(err)
+	defer b.Close()
+
+	result, err := b.Build()
+	require.NoError(err)
+	require.Len(result.Package.Order, 10)
+	require.Equal(result.Package.Order, result.Parsed)
+	require.Equal(result.Package.Order, result.Resolved)
+
+	result, err = b.Rebuild()
+	require.NoError(err)
+	require.Len(result.Parsed, 0)
+	require.Len(result.Resolved, 0)
+
+	// only the body of a definition changes
+	internal := filepath.Join(root, "src", "Internal", "Dependency.elm")
+	replaceInFile(t, internal, `Just "hi"`, `Just "hello"`)
+
+	result, err = b.Rebuild(internal)
+	require.NoError(err)
+	require.Equal([]string{"Internal.Dependency"}, result.Parsed)
+	require.Equal([]string{"Internal.Dependency"}, result.Resolved)
+	require.Equal(
+		result.Package.Modules["Internal.Dependency"].Scope.LookupExposed("maybeStr", ast.Var),
+		findIdent(result.Package.Modules["Main"], "maybeStr").Obj,
+	)
+
+	// the exposed interface changes
+	replaceInFile(t, internal, `exposing (maybeStr)`, `exposing (maybeStr, other)`)
+	appendToFile(t, internal, "\n\nother : Int\nother = 1\n")
+
+	result, err = b.Rebuild(internal)
+	require.NoError(err)
+	require.Equal([]string{"Internal.Dependency"}, result.Parsed)
+	require.Equal([]string{"Internal.Dependency", "Main"}, result.Resolved)
+	require.Equal(
+		result.Package.Modules["Internal.Dependency"].Scope.LookupExposed("maybeStr", ast.Var),
+		findIdent(result.Package.Modules["Main"], "maybeStr").Obj,
+	)
+
+	// the fixity of an operator imported by Main changes
+	dependency := filepath.Join(root, "elm-stuff", "packages", "some", "dependency", "1.0.0", "src", "Dependency.elm")
+	replaceInFile(t, dependency, "infixl 2 ?", "infixr 2 ?")
+
+	result, err = b.Rebuild(dependency)
+	require.NoError(err)
+	require.Equal([]string{"Dependency", "Main"}, result.Parsed)
+	require.Equal([]string{"Dependency", "Main"}, result.Resolved)
+
+	// a build with errors makes the next one build everything again
+	replaceInFile(t, internal, "other = 1", "other = undefinedVar")
+	_, err = b.Rebuild(internal)
+	require.Error(err)
+
+	replaceInFile(t, internal, "other = undefinedVar", "other = 1")
+	result, err = b.Rebuild(internal)
+	require.NoError(err)
+	require.Equal(result.Package.Order, result.Parsed)
+	require.Equal(result.Package.Order, result.Resolved)
+}
+
+func TestBuilderKeepsPreviousResult(t *testing.T) {
+	require := require.New(t)
+	wd, err := os.Getwd()
+	require.NoError(err)
+
+	root, err := ioutil.TempDir("", "tangram-builder-previous")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+	require.NoError(copyDir(filepath.Join(wd, "_testdata", "valid_fullparse"), root))
+
+	b, err := NewBuilder(filepath.Join(root, "src", "Main.elm"), FullParse)
+	require.NoError(err)
+	defer b.Close()
+
+	first, err := b.Build()
+	require.NoError(err)
+	main := first.Package.Modules["Main"]
+	maybeStr := first.Package.Modules["Internal.Dependency"].Scope.LookupExposed("maybeStr", ast.Var)
+	require.True(maybeStr == findIdent(main, "maybeStr").Obj)
+
+	// Main is relinked
+	internal := filepath.Join(root, "src", "Internal", "Dependency.elm")
+	replaceInFile(t, internal, `Just "hi"`, `Just "hello"`)
+	second, err := b.Rebuild(internal)
+	require.NoError(err)
+	require.Equal([]string{"Internal.Dependency"}, second.Resolved)
+	require.True(maybeStr != findIdent(second.Package.Modules["Main"], "maybeStr").Obj)
+	require.True(main == first.Package.Modules["Main"])
+	require.True(maybeStr == findIdent(main, "maybeStr").Obj, "the previous result should not be relinked")
+	require.True(maybeStr == main.Scope.Imported["maybeStr"], "the previous result should not be relinked")
+
+	// Main is resolved again
+	replaceInFile(t, internal, `exposing (maybeStr)`, `exposing (maybeStr, other)`)
+	appendToFile(t, internal, "\n\nother : Int\nother = 1\n")
+	third, err := b.Rebuild(internal)
+	require.NoError(err)
+	require.Equal([]string{"Internal.Dependency", "Main"}, third.Resolved)
+	require.Equal([]string{"Internal.Dependency"}, third.Parsed)
+	require.NotNil(second.Package.Modules["Main"].Scope, "the previous result should not be unresolved")
+	require.True(
+		second.Package.Modules["Internal.Dependency"].Scope.LookupExposed("maybeStr", ast.Var) ==
+			findIdent(second.Package.Modules["Main"], "maybeStr").Obj,
+		"the previous result should not be unresolved",
+	)
+	require.True(
+		third.Package.Modules["Internal.Dependency"].Scope.LookupExposed("maybeStr", ast.Var) ==
+			findIdent(third.Package.Modules["Main"], "maybeStr").Obj,
+	)
+}
+
+func TestBuilderUseCache(t *testing.T) {
+	require := require.New(t)
+	wd, err := os.Getwd()
+	require.NoError(err)
+
+	root, err := ioutil.TempDir("", "tangram-builder-cache")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+	require.NoError(copyDir(filepath.Join(wd, "_testdata", "valid_fullparse"), root))
+
+	path := filepath.Join(root, "src", "Main.elm")
+	for i := 0; i < 2; i++ {
+		b, err := NewBuilder(path, FullParse|UseCache)
+		require.NoError(err)
+
+		result, err := b.Build()
+		require.NoError(err)
+		require.Len(result.Package.Order, 10)
+
+		dependency := result.Package.Modules["Dependency"]
+		if i == 0 {
+			require.NotEqual(token.NoPos, dependency.Module.Exposing.Pos(), "Dependency should be parsed")
+		} else {
+			require.Equal(token.NoPos, dependency.Module.Exposing.Pos(), "Dependency should be loaded from the cache")
+		}
+
+		replaceInFile(t, path, `"hello world"`, `"hi world"`)
+		result, err = b.Rebuild(path)
+		require.NoError(err)
+		require.Equal([]string{"Main"}, result.Parsed)
+		require.Equal(
+			result.Package.Modules["Internal.Dependency"].Scope.LookupExposed("maybeStr", ast.Var),
+			findIdent(result.Package.Modules["Main"], "maybeStr").Obj,
+		)
+		replaceInFile(t, path, `"hi world"`, `"hello world"`)
+		require.NoError(b.Close())
+	}
+}
+
+func replaceInFile(t *testing.T, path, old, new string) {
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), old)
+	data = []byte(strings.Replace(string(data), old, new, -1))
+	require.NoError(t, ioutil.WriteFile(path, data, 0644))
+}
+
+func appendToFile(t *testing.T, path, content string) {
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, append(data, content...), 0644))
+}
+
+func findIdent(mod *ast.Module, name string) *ast.Ident {
+	var ident *ast.Ident
+	for _, d := range mod.Decls {
+		ast.WalkFunc(d, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && id.Name == name && ident == nil {
+				ident = id
+			}
+			return ident == nil
+		})
+	}
+	return ident
+}
+
+func TestBuilderOverlay(t *testing.T) {
+	require := require.New(t)
+	wd, err := os.Getwd()
+	require.NoError(err)
+
+	b, err := NewBuilder(filepath.Join(wd, "_testdata", "valid_fullparse", "src", "Main.elm"), FullParse)
+	require.NoError(err)
+	defer b.Close()
+
+	_, err = b.Build()
+	require.NoError(err)
+
+	internal := filepath.Join(wd, "_testdata", "valid_fullparse", "src", "Internal", "Dependency.elm")
+	b.Overlay().Set(internal, "module Internal.Dependency exposing (maybeStr)\n\nmaybeStr = Nothing\n", 1)
+
+	result, err := b.Rebuild(internal)
+	require.NoError(err)
+	require.Equal([]string{"Internal.Dependency"}, result.Parsed)
+	require.NotNil(findIdent(result.Package.Modules["Internal.Dependency"], "Nothing"))
+
+	b.Overlay().Invalidate(internal)
+	result, err = b.Rebuild(internal)
+	require.NoError(err)
+	require.Equal([]string{"Internal.Dependency"}, result.Parsed)
+	require.Nil(findIdent(result.Package.Modules["Internal.Dependency"], "Nothing"))
+}
+
+// countingEmitter counts the emitted diagnostics.
+type countingEmitter struct {
+	diagnostics int
+	summary     report.Summary
+}
+
+func (e *countingEmitter) Emit(_ string, ds []*report.Diagnostic) error {
+	e.diagnostics += len(ds)
+	return nil
+}
+
+func (e *countingEmitter) Summary(s report.Summary) error {
+	e.summary = s
+	return nil
+}
+
+func TestBuilderMaxErrors(t *testing.T) {
+	require := require.New(t)
+	wd, err := os.Getwd()
+	require.NoError(err)
+
+	b, err := NewBuilder(filepath.Join(wd, "_testdata", "valid_fullparse", "src", "Errors", "A.elm"), FullParse)
+	require.NoError(err)
+	defer b.Close()
+
+	emitter := new(countingEmitter)
+	b.SetEmitter(emitter)
+	b.SetMaxErrors(2)
+	_, err = b.Build()
+	require.Equal(ErrBuildFailed, err)
+	require.Equal(2, emitter.diagnostics)
+	require.True(emitter.summary.Omitted > 0)
+}
@@ -2,8 +2,10 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/report"
 	"github.com/elm-tangram/tangram/token"
 )
 
@@ -35,11 +37,22 @@ func parseTerm(p *parser) ast.Expr {
 		panic(bailout{})
 	case token.Identifier:
 		return parseIdentTerm(p)
+	case token.GLSL:
+		return parseShader(p)
 	}
 
 	return nil
 }
 
+func parseShader(p *parser) *ast.ShaderExpr {
+	t := p.tok
+	p.expect(token.GLSL)
+	return &ast.ShaderExpr{
+		Lbracket: t.Offset,
+		Source:   strings.TrimSuffix(strings.TrimPrefix(t.Value, ast.ShaderStart), ast.ShaderEnd),
+	}
+}
+
 func parseUpperQualifiedIdentifier(p *parser) ast.Expr {
 	path := []*ast.Ident{parseUpperName(p)}
 	for p.is(token.Dot) {
@@ -492,9 +505,20 @@ func parseLiteral(p *parser) *ast.BasicLit {
 
 	t := p.tok
 	p.next()
+	decoded, err := ast.DecodeLiteral(typ, t.Value)
+	if err != nil {
+		p.report(report.NewBaseReport(
+			report.SyntaxError,
+			t.Offset,
+			err.Error(),
+			&report.Region{t.Offset, t.Offset + token.Pos(len(t.Value))},
+		))
+	}
+
 	return &ast.BasicLit{
 		Type:     typ,
 		Position: t.Offset,
 		Value:    t.Value,
+		Decoded:  decoded,
 	}
 }
@@ -342,6 +342,14 @@ func Literal(kind ast.BasicLitType, val string) ExprAssert {
 	}
 }
 
+func Shader(source string) ExprAssert {
+	return func(t *testing.T, expr ast.Expr) {
+		shader, ok := expr.(*ast.ShaderExpr)
+		require.True(t, ok, "expected expr to be ShaderExpr, is %T", expr)
+		require.Equal(t, source, shader.Source)
+	}
+}
+
 func Lambda(patterns []PatternAssert, assertExpr ExprAssert) ExprAssert {
 	return func(t *testing.T, expr ast.Expr) {
 		lambda, ok := expr.(*ast.Lambda)
@@ -6,10 +6,13 @@ import (
 	"io"
 	"io/ioutil"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/iface"
 	"github.com/elm-tangram/tangram/package"
 	"github.com/elm-tangram/tangram/report"
 	"github.com/elm-tangram/tangram/scanner"
@@ -36,6 +39,15 @@ const (
 	StderrDiagnostics
 	// SkipWarnings will skip the warning diagnostics.
 	SkipWarnings
+	// UseCache will load the interfaces of the unchanged dependency modules
+	// from the build cache of the package instead of parsing them, and will
+	// store in the cache the interfaces of the ones that had to be parsed.
+	UseCache
+	// KeepTrivia will keep the whitespace, end of lines and comments of the
+	// module attached to its tokens, which are stored in the Tokens field of
+	// the module, so it can be printed back exactly as it was written. It
+	// only has effect in ParseFrom.
+	KeepTrivia
 )
 
 // Is reports whether the given flag is present in the current parse mode.
@@ -73,22 +85,124 @@ type ParseResult struct {
 
 // Parse will parse the file at the given path and all its imported modules
 // with the given mode of parsing.
-func Parse(path string, mode ParseMode) (result *ast.Package, err error) {
+func Parse(path string, mode ParseMode) (*ast.Package, error) {
+	return ParseMaxErrors(path, mode, 0)
+}
+
+// ParseMaxErrors is like Parse, but only the given number of errors are
+// reported. If it is 0, all of them are.
+func ParseMaxErrors(path string, mode ParseMode, maxErrors int) (*ast.Package, error) {
+	fp, err := newPackageParser(path, mode, false)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.cm.Close()
+	return fp.parsePackage(path, mode, maxErrors)
+}
+
+// ParseSources is like ParseMaxErrors, but it also returns the code map with
+// the source files of the parsed modules, so the positions of their code can
+// be found after parsing. The sources keep the content that was parsed even
+// if the files change afterwards. Modules loaded from the build cache have no
+// source.
+func ParseSources(path string, mode ParseMode, maxErrors int) (*ast.Package, *source.CodeMap, error) {
+	fp, err := newPackageParser(path, mode, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pkg, err := fp.parsePackage(path, mode, maxErrors)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pkg, fp.cm, nil
+}
+
+// parsePackage parses the package of the file at the given path. Only
+// maxErrors errors are reported, unless it is 0.
+func (fp *fullParser) parsePackage(path string, mode ParseMode, maxErrors int) (result *ast.Package, err error) {
+	fp.reporter.SetMaxErrors(maxErrors)
+
+	defer catchBailout()
+	if !mode.Is(StderrDiagnostics) {
+		defer func() {
+			err = fp.p.sess.Emit()
+		}()
+	} else {
+		defer fp.p.sess.Emit()
+	}
+
+	result = fp.parse(path)
+	return
+}
+
+// ParseGraph parses the imports of the files at the given paths and all their
+// imported modules and returns the dependency graph between all of them. The
+// module of the first path is the root of the graph. Only the module
+// declarations and imports are parsed, so any other error in the code will
+// not be reported.
+func ParseGraph(paths []string, mode ParseMode) (g *pkg.Graph, err error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("parser: at least one file is required to build a graph")
+	}
+
+	fp, err := newPackageParser(paths[0], mode, false)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.cm.Close()
+
+	defer catchBailout()
+	if !mode.Is(StderrDiagnostics) {
+		defer func() {
+			if err = fp.p.sess.Emit(); err != nil {
+				g = nil
+			}
+		}()
+	} else {
+		defer fp.p.sess.Emit()
+	}
+
+	visited := make(map[string]struct{})
+	for _, path := range paths {
+		if !fp.isParsed(path) {
+			fp.firstPass(path, visited)
+		}
+	}
+
+	g = fp.g
+	return
+}
+
+// newPackageParser creates a new parser for the package the file at the
+// given path belongs to. If buffered is true, the files are read into memory
+// when they are loaded.
+func newPackageParser(path string, mode ParseMode, buffered bool) (*fullParser, error) {
 	pkg, err := pkg.Load(filepath.Dir(path))
 	if err != nil {
 		return nil, err
 	}
 
-	cm := source.NewCodeMap(source.NewFsLoader(pkg))
-	defer cm.Close()
+	var loader source.Loader = source.NewFsLoader(pkg)
+	if buffered {
+		loader = source.NewBufferedLoader(loader)
+	}
+
+	cm := source.NewCodeMap(loader)
+	return newSessionParser(pkg, cm, mode, modeEmitter(mode)), nil
+}
 
-	var emitter report.Emitter
+// modeEmitter returns the emitter of diagnostics for the given mode.
+func modeEmitter(mode ParseMode) report.Emitter {
 	if mode.Is(StderrDiagnostics) {
-		emitter = report.Stderr(!mode.Is(SkipWarnings), true)
-	} else {
-		emitter = report.Errors(!mode.Is(SkipWarnings))
+		return report.Stderr(!mode.Is(SkipWarnings), true)
 	}
+	return report.Errors(!mode.Is(SkipWarnings))
+}
 
+// newSessionParser creates a new parser for the given package with a new
+// parsing session that uses the given code map and emitter.
+func newSessionParser(pkg *pkg.Package, cm *source.CodeMap, mode ParseMode, emitter report.Emitter) *fullParser {
 	var optable *opTable
 	if mode.Is(JustModule) {
 		optable = builtinOpTable()
@@ -99,19 +213,11 @@ func Parse(path string, mode ParseMode) (result *ast.Package, err error) {
 	reporter := report.NewReporter(cm, emitter)
 	sess := NewSession(reporter, cm, optable)
 
-	p := newParser(sess)
-	defer catchBailout()
-	if !mode.Is(StderrDiagnostics) {
-		defer func() {
-			err = sess.Emit()
-		}()
-	} else {
-		defer sess.Emit()
+	fp := newFullParser(newParser(sess), pkg, optable, cm, reporter)
+	if mode.Is(UseCache) {
+		fp.cache = iface.NewCache(pkg.CacheDir())
 	}
-
-	fp := newFullParser(p, pkg, optable, cm, reporter)
-	result = fp.parse(path)
-	return
+	return fp
 }
 
 type fullParser struct {
@@ -123,6 +229,28 @@ type fullParser struct {
 	reporter *report.Reporter
 	resolver *resolver
 	modCache map[string]string
+	// imports contains the import declarations of every module, indexed by
+	// the imported module.
+	imports map[string]map[string]*ast.ImportDecl
+	// headers contains the files parsed in the first pass, indexed by path.
+	headers map[string]*header
+	// cache is the cache of module interfaces. If it's nil, no cache is used.
+	cache *iface.Cache
+}
+
+// header is the result of parsing a file in the first pass, in which only the
+// module declaration, imports and fixity declarations are parsed.
+type header struct {
+	file *ast.Module
+	// importPaths contains the paths of all the imported modules that could
+	// be found, indexed by module name.
+	importPaths map[string]string
+	// hash of the source code of the file, only set if the interface of the
+	// module needs to be stored in or has been loaded from the cache.
+	hash string
+	// cached is the interface of the module loaded from the cache, if any.
+	// If it's not nil, file is the module created from it.
+	cached *iface.Interface
 }
 
 func newFullParser(p *parser, pkg *pkg.Package, optable *opTable, cm *source.CodeMap, r *report.Reporter) *fullParser {
@@ -135,6 +263,9 @@ func newFullParser(p *parser, pkg *pkg.Package, optable *opTable, cm *source.Cod
 		r,
 		&resolver{reporter: r},
 		make(map[string]string),
+		make(map[string]map[string]*ast.ImportDecl),
+		make(map[string]*header),
+		nil,
 	}
 }
 
@@ -142,13 +273,23 @@ func (p *fullParser) parse(path string) *ast.Package {
 	// do a first parse to gather all the imports and operator fixities
 	p.firstPass(path, make(map[string]struct{}))
 
+	modules := p.resolveGraph(path)
+	r := p.parseModules(modules, nil)
+	if !p.resolver.resolve(r) {
+		return nil
+	}
+
+	p.storeInterfaces(r)
+	return r
+}
+
+// resolveGraph returns the modules in the order in which they need to be
+// resolved, reporting any error in the dependency graph.
+func (p *fullParser) resolveGraph(path string) []string {
 	modules, err := p.g.Resolve()
 	switch err := err.(type) {
 	case *pkg.CircularDependencyError:
-		p.error(
-			path,
-			fmt.Sprintf("I found a circular dependency in your code between these modules:\n- %s\n- %s", err.Modules[0], err.Modules[1]),
-		)
+		p.circularDependency(err.Modules)
 	case nil:
 	default:
 		p.error(
@@ -157,60 +298,198 @@ func (p *fullParser) parse(path string) *ast.Package {
 		)
 	}
 
-	r := &ast.Package{Order: modules, Modules: make(map[string]*ast.Module)}
+	// diagnostics are emitted in the same order the modules are resolved
+	paths := make([]string, 0, len(modules))
 	for _, m := range modules {
-		if file := p.completeParse(m); file != nil {
-			r.Modules[m] = file
+		if path, ok := p.modCache[m]; ok {
+			paths = append(paths, path)
 		}
 	}
+	p.reporter.SetOrder(paths)
 
-	if !p.resolver.resolve(r) {
-		return nil
+	return modules
+}
+
+// parseModules parses concurrently the given modules and returns the package
+// with all of them. If reuse is not nil, it is called for every module and
+// the module it returns, if any, is used instead of parsing it again.
+func (p *fullParser) parseModules(modules []string, reuse func(string) *ast.Module) *ast.Package {
+	files := make([]*ast.Module, len(modules))
+	parallel(len(modules), func(i int) {
+		if reuse != nil {
+			if files[i] = reuse(modules[i]); files[i] != nil {
+				return
+			}
+		}
+		files[i] = p.completeParse(modules[i])
+	})
+
+	r := &ast.Package{Order: modules, Modules: make(map[string]*ast.Module)}
+	for i, m := range modules {
+		if files[i] == nil {
+			// the module could not be parsed and the error has already
+			// been reported
+			panic(bailout{})
+		}
+		r.Modules[m] = files[i]
 	}
 
 	return r
 }
 
+// firstPass parses the module declaration, imports and fixity declarations of
+// the file at the given path and all the modules it imports. Files are parsed
+// concurrently, but the dependency graph and operator table are built in the
+// same order in which the imports are declared, so they are always the same
+// for the same files.
 func (p *fullParser) firstPass(path string, visited map[string]struct{}) {
-	if err := p.cm.Add(path); err != nil {
-		p.error(path, "Oops, unexpected error reading file: %s", err)
+	p.parseHeaders(path)
+	p.addHeader(p.headers[path], visited)
+}
+
+// parseHeaders parses concurrently the file at the given path and all the
+// files imported by it, either directly or indirectly, that have not been
+// parsed yet. Files that were already parsed are not parsed again, but their
+// imports are still checked.
+func (p *fullParser) parseHeaders(path string) {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		sem    = newSemaphore()
+		failed bool
+		seen   = make(map[string]struct{})
+	)
+
+	var schedule func(string)
+	var scheduleImports = func(h *header) {
+		for _, imp := range h.file.Imports {
+			if importPath, ok := h.importPaths[imp.ModuleName()]; ok && !isNative(importPath) {
+				schedule(importPath)
+			}
+		}
+	}
+
+	schedule = func(path string) {
+		mu.Lock()
+		if _, ok := seen[path]; ok {
+			mu.Unlock()
+			return
+		}
+		seen[path] = struct{}{}
+		h := p.headers[path]
+		mu.Unlock()
+
+		if h != nil {
+			// already parsed, but some of its imports may not be
+			scheduleImports(h)
+			return
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sem.acquire()
+			h := p.parseHeader(path)
+			sem.release()
+
+			mu.Lock()
+			p.headers[path] = h
+			failed = failed || h == nil
+			mu.Unlock()
+
+			if h != nil {
+				scheduleImports(h)
+			}
+		}()
+	}
+
+	schedule(path)
+	wg.Wait()
+
+	if failed {
 		panic(bailout{})
 	}
-	source := p.cm.Source(path)
-	scanner := source.Scanner()
+}
+
+// parseHeader parses the module declaration, imports and fixity declarations
+// of the file at the given path and finds the paths of all the imported
+// modules. If the file cannot be parsed, nil is returned and the errors are
+// reported.
+func (p *fullParser) parseHeader(path string) (h *header) {
+	defer catchBailout()
+
+	h = &header{importPaths: make(map[string]string)}
+	if p.cache != nil && p.pkg.IsDependencyFile(path) {
+		h.hash, h.cached = p.loadInterface(path)
+	}
+
+	if h.cached != nil {
+		h.file = h.cached.AST(path)
+	} else {
+		if err := p.cm.Add(path); err != nil {
+			p.error(path, "Oops, unexpected error reading file: %s", err)
+			return nil
+		}
+		source := p.cm.Source(path)
+
+		hp := newParser(p.p.sess)
+		hp.init(source.Path, source.Scanner(), SkipDefinitions)
+		h.file = parseFile(hp)
+	}
 
-	p.p.init(source.Path, scanner, SkipDefinitions)
-	file := parseFile(p.p)
+	for _, imp := range h.file.Imports {
+		importMod := imp.ModuleName()
+		importPath, err := p.pkg.FindModule(importMod)
+		if err != nil {
+			p.error(
+				path,
+				fmt.Sprintf("I could not find module %q in any of the package source directories or any of its dependencies. Maybe you're missing a dependency?", importMod),
+			)
+			continue
+		}
+		h.importPaths[importMod] = importPath
+	}
 
+	return h
+}
+
+// addHeader adds the module of the given header and all the modules imported
+// by it that have not been visited yet to the dependency graph and the
+// operator table.
+func (p *fullParser) addHeader(h *header, visited map[string]struct{}) {
+	file := h.file
 	mod := file.Module.ModuleName()
 	// TODO: check module name corresponds to the path
 	visited[mod] = struct{}{}
+	// the header may have been added in a previous build
+	file.NativeImports = nil
+	p.modCache[mod] = file.Path
+	p.imports[mod] = make(map[string]*ast.ImportDecl)
 	if p.g == nil {
 		p.g = pkg.NewGraph(mod)
 	}
-
-	if p.p.mode.Is(JustModule) {
-		return
-	}
+	p.g.AddModule(mod)
 
 	for _, imp := range file.Imports {
 		importMod := imp.ModuleName()
+		p.imports[mod][importMod] = imp
 
-		importPath, ok := p.modCache[importMod]
+		importPath, ok := h.importPaths[importMod]
 		if !ok {
-			var err error
-			importPath, err = p.pkg.FindModule(importMod)
-			if err != nil {
-				p.error(
-					path,
-					fmt.Sprintf("I could not find module %q in any of the package source directories or any of its dependencies. Maybe you're missing a dependency?", importMod),
-				)
-				continue
-			}
-			p.modCache[importMod] = importPath
+			continue
 		}
-
-		if imp.Exposing != nil {
+		p.modCache[importMod] = importPath
+
+		if _, ok := imp.Exposing.(*ast.OpenList); ok && p.headers[importPath] != nil {
+			// all the operators with a fixity in the imported module are
+			// exposed
+			for _, d := range p.headers[importPath].file.Decls {
+				if fixity, ok := d.(*ast.InfixDecl); ok {
+					p.optable.addToModule(mod, importMod, fixity.Op.Name)
+				}
+			}
+		} else if imp.Exposing != nil {
 			ast.WalkFunc(imp.Exposing, func(n ast.Node) bool {
 				if v, ok := n.(*ast.ExposedVar); ok && v.IsOp() {
 					p.optable.addToModule(mod, importMod, v.Name)
@@ -225,7 +504,7 @@ func (p *fullParser) firstPass(path string, visited map[string]struct{}) {
 			p.g.Add(importMod, mod)
 
 			if _, ok := visited[importMod]; !ok {
-				p.firstPass(importPath, visited)
+				p.addHeader(p.headers[importPath], visited)
 			}
 		}
 	}
@@ -234,24 +513,94 @@ func (p *fullParser) firstPass(path string, visited map[string]struct{}) {
 		if fixity, ok := d.(*ast.InfixDecl); ok {
 			n, _ := strconv.Atoi(fixity.Precedence.Value)
 			p.optable.add(fixity.Op.Name, mod, fixity.Assoc, uint(n))
+			p.optable.addToModule(mod, mod, fixity.Op.Name)
 		}
 	}
 }
 
+// isParsed reports whether the file at the given path has already been parsed
+// in the first pass.
+func (p *fullParser) isParsed(path string) bool {
+	return p.headers[path] != nil
+}
+
 func isNative(path string) bool {
 	return strings.HasSuffix(path, ".go")
 }
 
-func (p *fullParser) completeParse(module string) *ast.Module {
-	path, err := p.pkg.FindModule(module)
-	if err != nil {
+// completeParse parses the whole file of the given module. It can be called
+// from multiple goroutines at the same time, as long as they parse different
+// modules. If the file cannot be parsed, nil is returned and the errors are
+// reported.
+func (p *fullParser) completeParse(module string) (file *ast.Module) {
+	defer catchBailout()
+
+	path, ok := p.modCache[module]
+	if !ok {
 		// TODO: fix this, but should be unreachable
-		panic(err)
+		panic(fmt.Errorf("parser: module %q was not found in the first pass", module))
+	}
+
+	if h := p.headers[path]; h.cached != nil {
+		return h.file
 	}
 
 	source := p.cm.Source(path)
-	p.p.init(path, source.Scanner(), FullParse)
-	return parseFile(p.p)
+	cp := newParser(p.p.sess)
+	cp.init(path, source.Scanner(), FullParse)
+	return parseFile(cp)
+}
+
+// loadInterface returns the hash of the file at the given path and its
+// interface, if it's in the cache. Errors loading the interface are
+// ignored, as the module can always be parsed instead.
+func (p *fullParser) loadInterface(path string) (string, *iface.Interface) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", nil
+	}
+
+	hash := iface.Hash(src)
+	i, err := p.cache.Load(hash)
+	if err != nil {
+		return hash, nil
+	}
+	return hash, i
+}
+
+// storeInterfaces stores in the cache the interfaces of all the resolved
+// modules that need to be cached and were not loaded from it. Errors storing
+// the interfaces are reported as warnings.
+func (p *fullParser) storeInterfaces(pkg *ast.Package) {
+	for _, m := range pkg.Order {
+		mod := pkg.Modules[m]
+		h := p.headers[mod.Path]
+		if h == nil || h.hash == "" || h.cached != nil {
+			continue
+		}
+
+		if err := p.cache.Store(iface.New(mod, h.hash)); err != nil {
+			p.reporter.Report(mod.Path, report.NewBaseReport(
+				report.Warning,
+				token.NoPos,
+				fmt.Sprintf("I could not store the interface of module %q in the build cache: %s", m, err),
+				nil,
+			))
+		}
+	}
+}
+
+// circularDependency reports a circular dependency between the given
+// modules, pointing at every import that is part of the cycle.
+func (p *fullParser) circularDependency(modules []string) {
+	paths := make([]string, len(modules)-1)
+	imports := make([]*ast.ImportDecl, len(modules)-1)
+	for i := range imports {
+		paths[i] = p.modCache[modules[i]]
+		imports[i] = p.imports[modules[i]][modules[i+1]]
+	}
+
+	p.reporter.Report(paths[0], report.NewCircularImportError(modules, paths, imports))
 }
 
 func (p *fullParser) error(path, msg string, args ...interface{}) {
@@ -285,7 +634,12 @@ func ParseFrom(name string, src io.Reader, mode ParseMode) (f *ast.Module, err e
 	)
 
 	p := newParser(sess)
-	s := scanner.New(name, bytes.NewBuffer(content))
+	scanMode := scanner.SkipTrivia
+	if mode.Is(KeepTrivia) {
+		scanMode = scanner.KeepTrivia
+	}
+
+	s := scanner.NewWithMode(name, bytes.NewBuffer(content), scanMode)
 	s.Run()
 	p.init(name, s, mode)
 	defer catchBailout()
@@ -297,6 +651,36 @@ func ParseFrom(name string, src io.Reader, mode ParseMode) (f *ast.Module, err e
 
 }
 
+// semaphore limits the number of goroutines doing some work at the same time.
+type semaphore chan struct{}
+
+// newSemaphore creates a semaphore that allows as many goroutines as
+// GOMAXPROCS.
+func newSemaphore() semaphore {
+	return make(semaphore, runtime.GOMAXPROCS(0))
+}
+
+func (s semaphore) acquire() { s <- struct{}{} }
+func (s semaphore) release() { <-s }
+
+// parallel calls fn for every index from 0 to n-1 concurrently, with at most
+// GOMAXPROCS calls running at the same time, and waits until all the calls
+// have finished.
+func parallel(n int, fn func(int)) {
+	var wg sync.WaitGroup
+	sem := newSemaphore()
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		sem.acquire()
+		go func(i int) {
+			defer wg.Done()
+			defer sem.release()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
 // catchBailout catches "bailout", which means parser has exited on purpose
 // due to errors during the parsing. If it's not a bailout the error comes from
 // somewhere else and is panicked again.
@@ -2,11 +2,14 @@ package parser
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/source"
+	"github.com/elm-tangram/tangram/token"
 
 	"github.com/stretchr/testify/require"
 )
@@ -257,3 +260,194 @@ func TestParseFull(t *testing.T) {
 		expected(t, f)
 	}
 }
+
+func TestParseCircularDependency(t *testing.T) {
+	require := require.New(t)
+	wd, err := os.Getwd()
+	require.NoError(err)
+	dir := filepath.Join(wd, "_testdata", "valid_fullparse", "src", "Circular")
+	_, err = Parse(filepath.Join(dir, "A.elm"), FullParse)
+	require.Error(err)
+
+	msg := err.Error()
+	require.Contains(msg, "problems found at file: "+filepath.Join(dir, "A.elm"))
+	require.Contains(msg, "Circular.A -> Circular.B -> Circular.C -> Circular.A")
+	require.Contains(msg, "at "+filepath.Join(dir, "A.elm")+":3:1")
+	require.Contains(msg, `Module "Circular.B" imports "Circular.C" here:`)
+	require.Contains(msg, "at "+filepath.Join(dir, "B.elm")+":3:1")
+	require.Contains(msg, `Module "Circular.C" imports "Circular.A" here:`)
+	require.Contains(msg, "at "+filepath.Join(dir, "C.elm")+":3:1")
+}
+
+func TestParseGraph(t *testing.T) {
+	require := require.New(t)
+	wd, err := os.Getwd()
+	require.NoError(err)
+	dir := filepath.Join(wd, "_testdata", "valid_fullparse", "src")
+
+	g, err := ParseGraph([]string{
+		filepath.Join(dir, "Main.elm"),
+		filepath.Join(dir, "Internal", "Dependency.elm"),
+		filepath.Join(dir, "Circular", "A.elm"),
+	}, SkipWarnings)
+	require.NoError(err)
+
+	require.Equal("Main", g.Root())
+	require.Contains(g.Dependencies("Main"), "Dependency")
+	require.Equal([]string{"Main"}, g.Dependents("Internal.Dependency"))
+	require.Equal(
+		[]string{"Circular.A", "Circular.B", "Circular.C"},
+		g.Unreachable(),
+	)
+}
+
+func TestParseDeterministicErrors(t *testing.T) {
+	require := require.New(t)
+	wd, err := os.Getwd()
+	require.NoError(err)
+	dir := filepath.Join(wd, "_testdata", "valid_fullparse", "src", "Errors")
+
+	_, err = Parse(filepath.Join(dir, "A.elm"), FullParse)
+	require.Error(err)
+	expected := err.Error()
+
+	for i := 0; i < 10; i++ {
+		_, err = Parse(filepath.Join(dir, "A.elm"), FullParse)
+		require.Error(err)
+		require.Equal(expected, err.Error())
+	}
+}
+
+func TestParseUseCache(t *testing.T) {
+	require := require.New(t)
+	wd, err := os.Getwd()
+	require.NoError(err)
+
+	root, err := ioutil.TempDir("", "tangram-cache")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+	require.NoError(copyDir(filepath.Join(wd, "_testdata", "valid_fullparse"), root))
+
+	path := filepath.Join(root, "src", "Main.elm")
+	first, err := Parse(path, FullParse|UseCache)
+	require.NoError(err)
+
+	// all modules from dependencies have their interface in the cache
+	files, err := ioutil.ReadDir(filepath.Join(root, "elm-stuff", "tangram"))
+	require.NoError(err)
+	require.Len(files, 8)
+
+	second, err := Parse(path, FullParse|UseCache)
+	require.NoError(err)
+	require.Equal(first.Order, second.Order)
+
+	for _, m := range second.Order {
+		mod := second.Modules[m]
+		if m == "Main" || m == "Internal.Dependency" {
+			require.NotEqual(token.NoPos, mod.Module.Exposing.Pos(), "%s should be parsed", m)
+		} else {
+			require.Equal(token.NoPos, mod.Module.Exposing.Pos(), "%s should be loaded from the cache", m)
+		}
+	}
+
+	main := second.Modules["Main"].Decls[0].(*ast.Definition)
+	op := main.Body.(*ast.BinaryOp)
+	require.NotNil(op.Op.Obj)
+	require.Equal(
+		second.Modules["Dependency"].Scope.LookupExposed("?:", ast.Var),
+		op.Op.Obj,
+	)
+}
+
+func TestParseSources(t *testing.T) {
+	require := require.New(t)
+	wd, err := os.Getwd()
+	require.NoError(err)
+
+	root, err := ioutil.TempDir("", "tangram-sources")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+	require.NoError(copyDir(filepath.Join(wd, "_testdata", "valid_fullparse"), root))
+
+	path := filepath.Join(root, "src", "Main.elm")
+	pkg, cm, err := ParseSources(path, FullParse, 0)
+	require.NoError(err)
+	require.NoError(ioutil.WriteFile(path, []byte("module Main exposing (..)\n"), 0644))
+
+	for _, m := range pkg.Order {
+		require.NotNil(cm.Source(pkg.Modules[m].Path), "%s should have a source", m)
+	}
+
+	main := pkg.Modules["Main"]
+	lp, err := cm.Source(main.Path).LinePos(main.Decls[0].(*ast.Definition).Body.Pos())
+	require.NoError(err)
+	require.Equal(source.LinePos{Line: 9, Col: 5}, lp)
+}
+
+func TestParseModuleOperators(t *testing.T) {
+	require := require.New(t)
+	wd, err := os.Getwd()
+	require.NoError(err)
+
+	root, err := ioutil.TempDir("", "tangram-operators")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+	require.NoError(copyDir(filepath.Join(wd, "_testdata", "valid_fullparse"), root))
+
+	path := filepath.Join(root, "src", "Ops.elm")
+	require.NoError(ioutil.WriteFile(path, []byte(`module Ops exposing (..)
+
+import Dependency exposing (..)
+
+infixl 1 <+>
+infixr 7 <*>
+
+(<+>) : a -> a -> a
+(<+>) a b = a
+
+(<*>) : a -> a -> a
+(<*>) a b = a
+
+loose = Nothing ? 1 <+> 2
+
+tight = Nothing ? 1 <*> 2
+`), 0644))
+
+	result, err := Parse(path, FullParse)
+	require.NoError(err)
+
+	// the operators of a module imported exposing everything and the
+	// operators of the module itself have their fixity
+	mod := result.Modules["Ops"]
+	var ops []string
+	for _, d := range mod.Decls {
+		if def, ok := d.(*ast.Definition); ok && len(def.Args) == 0 {
+			ops = append(ops, def.Body.(*ast.BinaryOp).Op.Name)
+		}
+	}
+	require.Equal([]string{"<+>", "?"}, ops)
+}
+
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, 0644)
+	})
+}
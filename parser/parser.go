@@ -147,15 +147,42 @@ func parseFile(p *parser) *ast.Module {
 		decls = append(decls, parseDecl(p))
 	}
 
+	var tokens []*token.Token
+	if p.mode.Is(KeepTrivia) {
+		tokens = attachTrivia(p.scanner.Tokens())
+	}
+
 	return &ast.Module{
 		Path:    p.fileName,
 		Name:    mod.ModuleName(),
 		Module:  mod,
 		Imports: imports,
 		Decls:   decls,
+		Tokens:  tokens,
 	}
 }
 
+// attachTrivia sets the trivia tokens found before every other token as its
+// leading trivia and returns all the tokens that are not trivia.
+func attachTrivia(tokens []*token.Token) []*token.Token {
+	var (
+		result  []*token.Token
+		leading []*token.Token
+	)
+
+	for _, t := range tokens {
+		if t.Type.IsTrivia() {
+			leading = append(leading, t)
+			continue
+		}
+
+		t.Leading = leading
+		leading = nil
+		result = append(result, t)
+	}
+	return result
+}
+
 func (p *parser) skipUntilNextFixity() {
 	p.silent = true
 	for {
@@ -244,9 +271,8 @@ func (p *parser) next() {
 	}
 
 	p.tok = p.scanner.Next()
-	if p.is(token.Comment) {
-		// ignore comments for now
-		p.next()
+	for p.tok.Type.IsTrivia() {
+		p.tok = p.scanner.Next()
 	}
 
 	if p.tok.Line != p.currentLine {
@@ -9,6 +9,7 @@ import (
 	"github.com/elm-tangram/tangram/report"
 	"github.com/elm-tangram/tangram/scanner"
 	"github.com/elm-tangram/tangram/source"
+	"github.com/elm-tangram/tangram/token"
 	"github.com/stretchr/testify/require"
 )
 
@@ -1022,9 +1023,19 @@ func TestParseExpr(t *testing.T) {
 	}{
 		{`5`, Literal(ast.Int, "5")},
 		{`"hello world"`, Literal(ast.String, `"hello world"`)},
+		{"\"\"\"hello\n  \\\"\"\" world\"\"\"", Literal(ast.String, "\"\"\"hello\n  \\\"\"\" world\"\"\"")},
 		{`True`, Literal(ast.Bool, `True`)},
+		{"[glsl|\nvoid main () { gl_FragColor = vec4(1, 0, 0, 1); }\n|]", Shader("\nvoid main () { gl_FragColor = vec4(1, 0, 0, 1); }\n")},
+		{"entity [glsl| void main () {} |] [glsl||]", FuncApp(
+			Identifier("entity"),
+			Shader(" void main () {} "),
+			Shader(""),
+		)},
 		{`False`, Literal(ast.Bool, `False`)},
 		{`3.1416`, Literal(ast.Float, `3.1416`)},
+		{`0xFF`, Literal(ast.Int, `0xFF`)},
+		{`6.02e23`, Literal(ast.Float, `6.02e23`)},
+		{`'\u{1F600}'`, Literal(ast.Char, `'\u{1F600}'`)},
 		{`'a'`, Literal(ast.Char, `'a'`)},
 		{`()`, TupleLiteral()},
 		{`[]`, ListLiteral()},
@@ -1276,6 +1287,43 @@ func TestParseExpr(t *testing.T) {
 	}
 }
 
+func TestParseLiteral(t *testing.T) {
+	cases := []struct {
+		input   string
+		decoded interface{}
+		err     string
+	}{
+		{`0x1F`, int64(31), ""},
+		{`1e-3`, 0.001, ""},
+		{`"a\u{41}"`, "aA", ""},
+		{`'\n'`, '\n', ""},
+		{`False`, false, ""},
+		{`99999999999999999999`, nil, "the integer 99999999999999999999 is too big"},
+		{`1e999`, nil, "the float 1e999 is out of the range"},
+		{`'\u{110000}'`, nil, "is not a valid code point"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			defer assertEOF(t, c.input, false)
+			p := stringParser(t, c.input)
+			lit := parseLiteral(p)
+			require.Equal(t, c.decoded, lit.Decoded)
+
+			reports := p.sess.Reports("test")
+			if c.err == "" {
+				require.Len(t, reports, 0)
+				return
+			}
+
+			require.Len(t, reports, 1)
+			require.Equal(t, report.SyntaxError, reports[0].Type())
+			require.Contains(t, reports[0].Message(), c.err)
+			require.Equal(t, &report.Region{0, token.Pos(len(c.input))}, reports[0].Region())
+		})
+	}
+}
+
 func TestParseExpr_NonAssocOp(t *testing.T) {
 	t.Run("followed by other non-assoc op", func(t *testing.T) {
 		input := `a == b == c`
@@ -60,8 +60,9 @@ func parsePattern(p *parser, greedy bool) (pat ast.Pattern) {
 	case token.Int, token.Char, token.String, token.Float:
 		pat = &ast.LiteralPattern{parseLiteral(p)}
 	case token.True, token.False:
+		tok := p.tok
 		p.expectOneOf(token.True, token.False)
-		pat = &ast.CtorPattern{Ctor: ast.NewIdent(p.tok.Value, p.tok.Offset)}
+		pat = &ast.CtorPattern{Ctor: ast.NewIdent(tok.Value, tok.Offset)}
 	default:
 		p.errorExpectedOneOf(p.tok, token.Identifier, token.LeftParen, token.LeftBrace, token.LeftBracket)
 	}
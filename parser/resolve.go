@@ -5,6 +5,7 @@ import (
 
 	"github.com/elm-tangram/tangram/ast"
 	"github.com/elm-tangram/tangram/report"
+	"github.com/elm-tangram/tangram/token"
 )
 
 type resolver struct {
@@ -12,18 +13,67 @@ type resolver struct {
 	reporter *report.Reporter
 
 	path string
+	// prepare, if not nil, is called with the modules of every level of the
+	// dependency graph before resolving them, once all the modules in the
+	// previous levels have been resolved.
+	prepare func(level []string)
 }
 
+// resolve resolves all the modules in the package. All the modules in the
+// same depth level of the dependency graph are resolved concurrently once all
+// the modules in the previous levels have been resolved.
 func (r *resolver) resolve(pkg *ast.Package) bool {
 	r.pkg = pkg
 	var resolved = true
-	for _, m := range pkg.Order {
-		r.path = pkg.Modules[m].Path
-		resolved = r.resolveModule(pkg.Modules[m]) && resolved
+	for _, level := range moduleLevels(pkg) {
+		if r.prepare != nil {
+			r.prepare(level)
+		}
+
+		ok := make([]bool, len(level))
+		parallel(len(level), func(i int) {
+			mod := pkg.Modules[level[i]]
+			if mod.Scope != nil {
+				// the module was created from its interface or was
+				// resolved in a previous build
+				ok[i] = true
+				return
+			}
+
+			mr := &resolver{pkg: pkg, reporter: r.reporter, path: mod.Path}
+			ok[i] = mr.resolveModule(mod)
+		})
+
+		for _, ok := range ok {
+			resolved = ok && resolved
+		}
 	}
 	return resolved
 }
 
+// moduleLevels groups the modules of the package by their depth level in the
+// dependency graph. The modules of a level only import modules in previous
+// levels. Modules in the same level are in resolution order.
+func moduleLevels(pkg *ast.Package) [][]string {
+	var levels [][]string
+	depth := make(map[string]int, len(pkg.Order))
+	for _, m := range pkg.Order {
+		var level int
+		for _, imp := range pkg.Modules[m].Imports {
+			if d, ok := depth[imp.ModuleName()]; ok && d+1 > level {
+				level = d + 1
+			}
+		}
+
+		depth[m] = level
+		if level == len(levels) {
+			levels = append(levels, nil)
+		}
+		levels[level] = append(levels[level], m)
+	}
+	return levels
+}
+
 func (r *resolver) resolveModule(mod *ast.Module) bool {
 	mod.Scope = ast.NewModuleScope(mod)
 
@@ -145,7 +195,7 @@ func (r *resolver) resolveDecl(scope ast.Scope, decl ast.Decl) {
 		if decl.Annotation != nil {
 			r.resolveType(scope, decl.Annotation.Type, false)
 		}
-		scope.Add(ast.NewObject(decl.Name.Name, ast.Var, decl.Name))
+		r.declare(scope, decl, decl.Name, ast.NewObject(decl.Name.Name, ast.Var, decl.Name))
 
 		defScope := ast.NewNodeScope(decl, scope)
 		for _, arg := range decl.Args {
@@ -153,7 +203,7 @@ func (r *resolver) resolveDecl(scope ast.Scope, decl ast.Decl) {
 		}
 		r.resolveExpr(defScope, decl.Body)
 	case *ast.AliasDecl:
-		scope.Add(ast.NewObject(decl.Name.Name, ast.Typ, decl))
+		r.declare(scope, decl, decl.Name, ast.NewObject(decl.Name.Name, ast.Typ, decl))
 		declScope := ast.NewNodeScope(decl, scope)
 		set := make(map[string]struct{})
 		for _, arg := range decl.Args {
@@ -166,7 +216,7 @@ func (r *resolver) resolveDecl(scope ast.Scope, decl ast.Decl) {
 		}
 		r.resolveType(declScope, decl.Type, true)
 	case *ast.UnionDecl:
-		scope.Add(ast.NewObject(decl.Name.Name, ast.Typ, decl))
+		r.declare(scope, decl, decl.Name, ast.NewObject(decl.Name.Name, ast.Typ, decl))
 		declScope := ast.NewNodeScope(decl, scope)
 		set := make(map[string]struct{})
 		for _, arg := range decl.Args {
@@ -190,6 +240,31 @@ func (r *resolver) resolveDecl(scope ast.Scope, decl ast.Decl) {
 	}
 }
 
+// declare adds the object declared by decl to the scope. If there was already
+// an object of the same kind with the same name in the scope, an error
+// pointing at both declarations is reported.
+func (r *resolver) declare(scope ast.Scope, decl ast.Decl, name *ast.Ident, obj *ast.Object) {
+	if scope.Add(obj) {
+		return
+	}
+
+	var prev *ast.Object
+	switch scope := scope.(type) {
+	case *ast.ModuleScope:
+		prev = scope.LookupSelf(obj.Name, obj.Kind)
+	case *ast.NodeScope:
+		prev = scope.Objects[obj.Name]
+	}
+
+	if prev != nil && prev.Kind == obj.Kind {
+		var prevNode ast.Node
+		if prev.Node != nil && prev.Node.Pos() != token.NoPos {
+			prevNode = prev.Node
+		}
+		r.report(report.NewAlreadyDeclaredError(decl, name, prevNode))
+	}
+}
+
 // TODO(erizocosmico): please, split this into smaller functions
 func (r *resolver) resolveModuleDecl(scope *ast.ModuleScope, mod *ast.ModuleDecl) {
 	switch list := mod.Exposing.(type) {
@@ -347,6 +422,9 @@ func (r *resolver) resolveExpr(scope ast.Scope, expr ast.Expr) {
 		r.resolveExpr(lambdaScope, expr.Expr)
 	case *ast.ParensExpr:
 		r.resolveExpr(scope, expr.Expr)
+	case *ast.ShaderExpr:
+		// shaders are not checked, they all have the same opaque type
+		expr.Obj = shaderType
 	case *ast.AccessorExpr, *ast.TupleCtor, *ast.BadExpr:
 		// no need to do anything
 	}
@@ -512,6 +590,10 @@ var basicTypes = map[string]*ast.Object{
 	"List":   ast.NewObject("List", ast.BuiltinTyp, nil),
 }
 
+// shaderType is the object of the shader blocks, which is not in basicTypes
+// because Shader cannot be used as a name.
+var shaderType = ast.NewObject("Shader", ast.BuiltinTyp, nil)
+
 func (r *resolver) checkUnresolvedChildren(scopes []*ast.NodeScope) bool {
 	var resolved = true
 	for _, scope := range scopes {
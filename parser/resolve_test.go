@@ -355,6 +355,63 @@ func TestResolveDecl(t *testing.T) {
 		require.True(r.reporter.IsOK())
 	})
 
+	t.Run("Definition referenced before declared", func(t *testing.T) {
+		r := newTestResolver(t)
+		require := require.New(t)
+		scope := newScope()
+		first := &ast.Definition{
+			Name: ast.NewIdent("first", token.NoPos),
+			Args: []ast.Pattern{
+				&ast.VarPattern{ast.NewIdent("second", token.NoPos)},
+			},
+			Body: ast.NewIdent("second", token.NoPos),
+		}
+		third := &ast.Definition{
+			Name: ast.NewIdent("third", token.NoPos),
+			Body: ast.NewIdent("second", token.NoPos),
+		}
+		second := &ast.Definition{
+			Name: ast.NewIdent("second", token.NoPos),
+			Body: ast.NewIdent("c", token.NoPos),
+		}
+		r.resolveDecl(scope, first)
+		r.resolveDecl(scope, third)
+		r.resolveDecl(scope, second)
+
+		// the argument shadows the definition
+		require.Equal(first.Args[0], first.Body.(*ast.Ident).Obj.Node)
+		require.Equal(second.Name, third.Body.(*ast.Ident).Obj.Node)
+		for _, child := range scope.Children() {
+			require.Len(child.Unresolved, 0)
+		}
+		require.True(r.reporter.IsOK())
+	})
+
+	t.Run("Definition already declared", func(t *testing.T) {
+		r := newTestResolver(t)
+		require := require.New(t)
+		scope := newScope()
+		first := &ast.Definition{
+			Name: ast.NewIdent("foo", token.Pos(1)),
+			Body: ast.NewIdent("c", token.Pos(7)),
+		}
+		second := &ast.Definition{
+			Name: ast.NewIdent("foo", token.Pos(10)),
+			Body: ast.NewIdent("d", token.Pos(16)),
+		}
+		r.resolveDecl(scope, first)
+		r.resolveDecl(scope, second)
+
+		require.False(r.reporter.IsOK())
+		assertReports(t, r.reporter, new(report.AlreadyDeclaredError))
+
+		rep := r.reporter.Reports("test")[0]
+		require.Equal(token.Pos(10), rep.Pos())
+		require.Len(rep.Labels(), 1)
+		require.Equal(token.Pos(1), rep.Labels()[0].Pos)
+		require.Equal(scope.Objects["foo"].Node, first.Name)
+	})
+
 	t.Run("AliasDecl", func(t *testing.T) {
 		require := require.New(t)
 		scope := newScope()
@@ -940,6 +997,26 @@ func TestResolveExpr(t *testing.T) {
 		require.Len(scope.Unresolved, 0)
 		require.True(r.reporter.IsOK())
 	})
+
+	t.Run("ShaderExpr", func(t *testing.T) {
+		require := require.New(t)
+		scope := newScope()
+		node := &ast.ShaderExpr{Source: "void main () {}"}
+
+		r.resolveExpr(scope, node)
+
+		require.Len(scope.Objects, 0)
+		require.Len(scope.Unresolved, 0)
+		require.NotNil(node.Obj)
+		require.Equal("Shader", node.Obj.Name)
+		require.Equal(ast.BuiltinTyp, node.Obj.Kind)
+		require.True(r.reporter.IsOK())
+
+		// Shader is not a name that can be used
+		unresolved := map[string][]*ast.Ident{"Shader": {ast.NewIdent("Shader", token.NoPos)}}
+		r.resolveBasicTypes(unresolved)
+		require.Len(unresolved, 1)
+	})
 }
 
 func TestResolveImport(t *testing.T) {
@@ -1414,5 +1491,5 @@ func newTestResolver(t *testing.T) *resolver {
 	cm := source.NewCodeMap(loader)
 	require.NoError(t, cm.Add(path), "adding %s", path)
 	reporter := report.NewReporter(cm, report.Stderr(true, true))
-	return &resolver{nil, reporter, path}
+	return &resolver{reporter: reporter, path: path}
 }
@@ -0,0 +1,128 @@
+// Package printer prints modules parsed keeping the trivia back to source
+// code, either exactly as they were written or with some of their nodes
+// replaced by new code, leaving every other byte of the module untouched.
+package printer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/token"
+)
+
+// ErrNoTokens is returned when the module being printed was not parsed
+// keeping the trivia, so there are no tokens to print.
+var ErrNoTokens = errors.New("printer: the module has no tokens, it must be parsed with the KeepTrivia mode")
+
+// Edit is a replacement of the source code of a node.
+type Edit struct {
+	// Node whose source code is replaced.
+	Node ast.Node
+	// Text is the new source code of the node.
+	Text string
+}
+
+// Fprint writes the source code of the module to w exactly as it was
+// written.
+func Fprint(w io.Writer, mod *ast.Module) error {
+	return FprintEdits(w, mod)
+}
+
+// FprintEdits writes the source code of the module to w replacing the
+// source code of the nodes in the given edits. All the tokens of a node,
+// and the trivia between them, are replaced by the text of its edit. The
+// trivia before the first token of the node, such as its comments, is kept.
+// Edits of nodes that overlap are not allowed.
+func FprintEdits(w io.Writer, mod *ast.Module, edits ...Edit) error {
+	if mod.Tokens == nil {
+		return ErrNoTokens
+	}
+
+	edits, err := sortEdits(edits)
+	if err != nil {
+		return err
+	}
+
+	p := &printer{w: bufio.NewWriter(w)}
+	for _, t := range mod.Tokens {
+		for len(edits) > 0 && t.Offset >= edits[0].Node.End() {
+			if err := p.flush(&edits[0]); err != nil {
+				return err
+			}
+			edits = edits[1:]
+		}
+
+		if len(edits) > 0 && t.Offset >= edits[0].Node.Pos() {
+			if !p.inEdit {
+				p.printTrivia(t)
+				p.inEdit = true
+			}
+			continue
+		}
+
+		p.printTrivia(t)
+		p.print(t.Value)
+	}
+
+	for i := range edits {
+		if err := p.flush(&edits[i]); err != nil {
+			return err
+		}
+	}
+
+	if p.err != nil {
+		return p.err
+	}
+	return p.w.Flush()
+}
+
+type printer struct {
+	w      *bufio.Writer
+	err    error
+	inEdit bool
+}
+
+func (p *printer) print(s string) {
+	if p.err == nil {
+		_, p.err = p.w.WriteString(s)
+	}
+}
+
+func (p *printer) printTrivia(t *token.Token) {
+	for _, trivia := range t.Leading {
+		p.print(trivia.Value)
+	}
+}
+
+// flush prints the text of the given edit, which must have replaced at
+// least one token.
+func (p *printer) flush(e *Edit) error {
+	if !p.inEdit {
+		return fmt.Errorf("printer: there are no tokens between the positions %d and %d of the node to replace", e.Node.Pos(), e.Node.End())
+	}
+
+	p.print(e.Text)
+	p.inEdit = false
+	return nil
+}
+
+// sortEdits returns the edits sorted by the position of their nodes, or an
+// error if any of them overlap.
+func sortEdits(edits []Edit) ([]Edit, error) {
+	sorted := make([]Edit, len(edits))
+	copy(sorted, edits)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Node.Pos() < sorted[j].Node.Pos()
+	})
+
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Node.Pos() < sorted[i-1].Node.End() {
+			return nil, fmt.Errorf("printer: the edits of the nodes at the positions %d and %d overlap", sorted[i-1].Node.Pos(), sorted[i].Node.Pos())
+		}
+	}
+	return sorted, nil
+}
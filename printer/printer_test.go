@@ -0,0 +1,91 @@
+package printer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/parser"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testModule = "module Main exposing (..)\r\n\r\n{-| Docs of the module.\n-}\nimport List exposing (map)\n\n\n-- the answer\nanswer : Int\nanswer =\n    -- a comment inside\n    42   \n\n\nsquares : List Int -> List Int\nsquares =\n\tmap (\\x -> x * x)  -- trailing comment"
+
+func parse(t *testing.T, src string) *ast.Module {
+	mod, err := parser.ParseFrom("Main.elm", strings.NewReader(src), parser.FullParse|parser.KeepTrivia)
+	require.NoError(t, err)
+	return mod
+}
+
+func TestFprint(t *testing.T) {
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	require.NoError(Fprint(&buf, parse(t, testModule)))
+	require.Equal(testModule, buf.String())
+}
+
+func TestFprintCore(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "parser", "_testdata", "valid_fullparse", "elm-stuff", "packages", "elm-lang", "core", "5.1.1", "src", "*.elm"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			content, err := ioutil.ReadFile(file)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			require.NoError(t, Fprint(&buf, parse(t, string(content))))
+			require.Equal(t, string(content), buf.String())
+		})
+	}
+}
+
+func TestFprintEdits(t *testing.T) {
+	require := require.New(t)
+	mod := parse(t, testModule)
+
+	var buf bytes.Buffer
+	require.NoError(FprintEdits(&buf, mod, Edit{
+		Node: mod.Decls[0],
+		Text: "answer : Float\nanswer = 42.0",
+	}))
+
+	expected := strings.Replace(
+		testModule,
+		"answer : Int\nanswer =\n    -- a comment inside\n    42",
+		"answer : Float\nanswer = 42.0",
+		1,
+	)
+	require.Equal(expected, buf.String())
+
+	buf.Reset()
+	require.NoError(FprintEdits(&buf, mod,
+		Edit{Node: mod.Decls[1], Text: "squares = identity"},
+		Edit{Node: mod.Imports[len(mod.Imports)-1], Text: "import Basics exposing (identity)"},
+	))
+
+	expected = strings.Replace(testModule, "import List exposing (map)", "import Basics exposing (identity)", 1)
+	expected = strings.Replace(expected, "squares : List Int -> List Int\nsquares =\n\tmap (\\x -> x * x)", "squares = identity", 1)
+	require.Equal(expected, buf.String())
+}
+
+func TestFprintEditsErrors(t *testing.T) {
+	require := require.New(t)
+
+	mod, err := parser.ParseFrom("Main.elm", strings.NewReader(testModule), parser.FullParse)
+	require.NoError(err)
+	require.Equal(ErrNoTokens, Fprint(ioutil.Discard, mod))
+
+	mod = parse(t, testModule)
+	err = FprintEdits(ioutil.Discard, mod,
+		Edit{Node: mod.Decls[0], Text: "a = 1"},
+		Edit{Node: mod.Decls[0].(*ast.Definition).Body, Text: "2"},
+	)
+	require.Error(err)
+}
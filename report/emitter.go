@@ -13,6 +13,20 @@ import (
 type Emitter interface {
 	// Emit emits the given reports for the given file.
 	Emit(string, []*Diagnostic) error
+	// Summary emits the summary of all the reports after they have been
+	// emitted.
+	Summary(Summary) error
+}
+
+// Summary contains the number of reports of each kind that were reported.
+type Summary struct {
+	// Errors is the total number of errors, emitted or not.
+	Errors int
+	// Warnings is the total number of warnings, emitted or not.
+	Warnings int
+	// Omitted is the number of errors that were not emitted because the
+	// maximum number of errors was reached.
+	Omitted int
 }
 
 // Errors is an emitter that emits Go errors with the reports.
@@ -34,6 +48,10 @@ func (e *errorEmitter) Emit(file string, diagnostics []*Diagnostic) error {
 	return fmt.Errorf("problems found at file: %s\n\n%s", file, buf.String())
 }
 
+func (e *errorEmitter) Summary(Summary) error {
+	return nil
+}
+
 type writerEmitter struct {
 	w        io.Writer
 	warnings bool
@@ -69,6 +87,36 @@ func (e *writerEmitter) Emit(file string, diagnostics []*Diagnostic) error {
 	return nil
 }
 
+func (e *writerEmitter) Summary(s Summary) error {
+	warnings := s.Warnings
+	if !e.warnings {
+		warnings = 0
+	}
+
+	if s.Errors == 0 && warnings == 0 {
+		return nil
+	}
+
+	if err := e.print("Found %s and %s", plural(s.Errors, "error"), plural(warnings, "warning")); err != nil {
+		return err
+	}
+
+	if s.Omitted > 0 {
+		if err := e.print(" (%s omitted)", plural(s.Omitted, "error")); err != nil {
+			return err
+		}
+	}
+
+	return e.print(".\n")
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
 func (e *writerEmitter) emitReport(file string, d *Diagnostic) error {
 	if err := e.printType(d.Type); err != nil {
 		return err
@@ -84,7 +132,31 @@ func (e *writerEmitter) emitReport(file string, d *Diagnostic) error {
 		}
 	}
 
-	return e.print("\nat %s:%d:%d\n\n", file, d.Pos.Line, d.Pos.Col)
+	if err := e.print("\nat %s:%d:%d\n", file, d.Pos.Line, d.Pos.Col); err != nil {
+		return err
+	}
+
+	for _, l := range d.Labels {
+		if err := e.emitLabel(l); err != nil {
+			return err
+		}
+	}
+
+	return e.print("\n")
+}
+
+func (e *writerEmitter) emitLabel(l *DiagnosticLabel) error {
+	if err := e.print("\n%s", l.Message); err != nil {
+		return err
+	}
+
+	if l.Region != nil {
+		if err := e.printRegion(Info, l.Pos, l.Region); err != nil {
+			return err
+		}
+	}
+
+	return e.print("\nat %s:%d:%d\n", l.Path, l.Pos.Line, l.Pos.Col)
 }
 
 func (e *writerEmitter) print(msg string, args ...interface{}) error {
@@ -0,0 +1,107 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/elm-tangram/tangram/source"
+	"github.com/elm-tangram/tangram/token"
+
+	"github.com/stretchr/testify/require"
+)
+
+const mainFixture = `module Main exposing (..)
+
+import Foo
+
+foo = 1
+`
+
+const fooFixture = `module Foo exposing (..)
+
+import Main
+`
+
+const expectedMultiSpan = `I found problems at file: Main.elm
+
+name error: something is wrong
+
+3 | import Foo
+-----------^
+
+at Main.elm:3:8
+
+this is related:
+
+5 | foo = 1
+----^
+
+at Main.elm:5:1
+
+this is related too:
+
+3 | import Main
+-----------^
+
+at Foo.elm:3:8
+
+Found 1 error and 0 warnings.
+`
+
+func TestEmitMultiSpan(t *testing.T) {
+	require := require.New(t)
+
+	loader := source.NewMemLoader()
+	loader.Add("Main.elm", mainFixture)
+	loader.Add("Foo.elm", fooFixture)
+	cm := source.NewCodeMap(loader)
+	require.NoError(cm.Add("Main.elm"))
+	require.NoError(cm.Add("Foo.elm"))
+
+	var buf bytes.Buffer
+	reporter := NewReporter(cm, &writerEmitter{&buf, true, false})
+
+	rep := NewBaseReport(NameError, token.Pos(34), "something is wrong", &Region{27, 37})
+	rep.AddLabel(&Label{"", token.Pos(39), &Region{39, 46}, "this is related:"})
+	rep.AddLabel(&Label{"Foo.elm", token.Pos(33), &Region{26, 37}, "this is related too:"})
+	reporter.Report("Main.elm", rep)
+
+	require.NoError(reporter.Emit())
+	require.Equal(expectedMultiSpan, buf.String())
+}
+
+const multibyteFixture = `module Main exposing (..)
+
+foo = "héllo 😀" ++ bar
+`
+
+const expectedMultibyte = `I found problems at file: Main.elm
+
+name error: something is wrong
+
+3 |                    bar
+-----------------------^
+
+at Main.elm:3:20
+
+Found 1 error and 0 warnings.
+`
+
+func TestEmitMultibyte(t *testing.T) {
+	require := require.New(t)
+
+	loader := source.NewMemLoader()
+	loader.Add("Main.elm", multibyteFixture)
+	cm := source.NewCodeMap(loader)
+	require.NoError(cm.Add("Main.elm"))
+
+	var buf bytes.Buffer
+	reporter := NewReporter(cm, &writerEmitter{&buf, true, false})
+
+	pos := token.Pos(strings.Index(multibyteFixture, "bar"))
+	reporter.Report("Main.elm", NewBaseReport(NameError, pos, "something is wrong", &Region{pos, pos + 3}))
+
+	require.NoError(reporter.Emit())
+	require.Equal(expectedMultibyte, buf.String())
+}
@@ -147,11 +147,18 @@ type AlreadyDeclaredError struct {
 	Name string
 }
 
-func NewAlreadyDeclaredError(decl ast.Decl, name *ast.Ident) *AlreadyDeclaredError {
-	return &AlreadyDeclaredError{
+// NewAlreadyDeclaredError creates a new AlreadyDeclaredError for the name
+// declared in decl, which was previously declared at the given node.
+func NewAlreadyDeclaredError(decl ast.Decl, name *ast.Ident, prev ast.Node) *AlreadyDeclaredError {
+	e := &AlreadyDeclaredError{
 		NewBaseReport(NameError, name.Pos(), "", RegionFromNode(decl)),
 		name.Name,
 	}
+
+	if prev != nil {
+		e.AddLabel(NewLabel("", prev, fmt.Sprintf("%q was first declared here:", name.Name)))
+	}
+	return e
 }
 
 func (e *AlreadyDeclaredError) Message() string {
@@ -206,6 +213,42 @@ func (e *UnresolvedNameError) Message() string {
 	return fmt.Sprintf("I could not find any definition for %q.", e.Name)
 }
 
+type CircularImportError struct {
+	BaseReport
+	Modules []string
+}
+
+// NewCircularImportError creates a new CircularImportError for the given
+// cycle of modules, in which every module imports the next one and the last
+// one is the same as the first. For every module in the cycle but the last,
+// paths contains the file of the module and imports the declaration importing
+// the next module. The first import is the one the report points at and the
+// rest are added as labels.
+func NewCircularImportError(modules, paths []string, imports []*ast.ImportDecl) *CircularImportError {
+	e := &CircularImportError{
+		NewBaseReport(NameError, imports[0].Pos(), "", RegionFromNode(imports[0])),
+		modules,
+	}
+
+	for i := 1; i < len(imports); i++ {
+		e.AddLabel(NewLabel(
+			paths[i],
+			imports[i],
+			fmt.Sprintf("Module %q imports %q here:", modules[i], modules[i+1]),
+		))
+	}
+	return e
+}
+
+func (e *CircularImportError) Message() string {
+	return fmt.Sprintf(
+		"I found a circular dependency in your code between these modules:\n\n    %s\n\nOne of these imports needs to be removed to break the cycle. Module %q imports %q here:",
+		strings.Join(e.Modules, " -> "),
+		e.Modules[0],
+		e.Modules[1],
+	)
+}
+
 // Parse errors
 
 func NewExpectedTypeError(pos token.Pos, region *Region) Report {
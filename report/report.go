@@ -37,6 +37,12 @@ func (t ReportType) String() string {
 	}
 }
 
+// IsError returns whether the report type is an error, that is, anything
+// but an info or a warning.
+func (t ReportType) IsError() bool {
+	return t != Info && t != Warning
+}
+
 func (t ReportType) Color() func(string, ...interface{}) string {
 	switch t {
 	case Info:
@@ -53,6 +59,8 @@ type Report interface {
 	Message() string
 	Pos() token.Pos
 	Region() *Region
+	// Labels returns the secondary regions of code related to the report.
+	Labels() []*Label
 }
 
 type BaseReport struct {
@@ -60,16 +68,23 @@ type BaseReport struct {
 	pos    token.Pos
 	msg    string
 	region *Region
+	labels []*Label
 }
 
 func NewBaseReport(typ ReportType, pos token.Pos, msg string, region *Region) BaseReport {
-	return BaseReport{typ, pos, msg, region}
+	return BaseReport{typ, pos, msg, region, nil}
 }
 
 func (r BaseReport) Type() ReportType { return r.typ }
 func (r BaseReport) Message() string  { return r.msg }
 func (r BaseReport) Pos() token.Pos   { return r.pos }
 func (r BaseReport) Region() *Region  { return r.region }
+func (r BaseReport) Labels() []*Label { return r.labels }
+
+// AddLabel attaches a new secondary region to the report.
+func (r *BaseReport) AddLabel(label *Label) {
+	r.labels = append(r.labels, label)
+}
 
 func AsError(report Report) error {
 	return errors.New(report.Message())
@@ -80,6 +95,18 @@ type Diagnostic struct {
 	Message string
 	Pos     source.LinePos
 	Region  *source.Snippet
+	// Labels are the secondary regions of the diagnostic, which may be in
+	// files other than the one the diagnostic belongs to.
+	Labels []*DiagnosticLabel
+}
+
+// DiagnosticLabel is a secondary region of a diagnostic, with the affected
+// snippet of code already extracted.
+type DiagnosticLabel struct {
+	Path    string
+	Message string
+	Pos     source.LinePos
+	Region  *source.Snippet
 }
 
 type Region struct {
@@ -87,6 +114,25 @@ type Region struct {
 	End   token.Pos
 }
 
+// Label is a secondary region of code related to a report, such as the
+// original declaration of a name that has been declared twice.
+type Label struct {
+	// Path of the file the region belongs to. If it's empty, the region is
+	// assumed to be in the same file as the report.
+	Path string
+	// Pos is the exact position the label points to.
+	Pos token.Pos
+	// Region of code to show, if any.
+	Region *Region
+	// Message describing the relation of the region with the report.
+	Message string
+}
+
+// NewLabel creates a new label pointing at the given node.
+func NewLabel(path string, node ast.Node, msg string) *Label {
+	return &Label{path, node.Pos(), RegionFromNode(node), msg}
+}
+
 func RegionFromNode(node ast.Node) *Region {
 	return &Region{node.Pos(), node.End()}
 }
@@ -1,37 +1,107 @@
 package report
 
 import (
+	"fmt"
+	"sort"
+	"sync"
+
 	"github.com/elm-tangram/tangram/source"
 	"github.com/elm-tangram/tangram/token"
 )
 
 // Reporter is in charge of reporting the diagnostics occurred during any of
-// the compilation steps to the user.
+// the compilation steps to the user. It is safe to use a Reporter from
+// multiple goroutines.
 type Reporter struct {
+	mu      sync.Mutex
 	cm      *source.CodeMap
 	emitter Emitter
 	reports map[string][]Report
+	// files are all the files with reports.
+	files []string
+	// order is the position of every file in the emission order, if it has
+	// been given.
+	order map[string]int
+	// maxErrors is the maximum number of errors to emit. If it is 0, there
+	// is no limit.
+	maxErrors int
 }
 
 // NewReporter creates a new reporter.
 func NewReporter(cm *source.CodeMap, emitter Emitter) *Reporter {
-	return &Reporter{cm, emitter, make(map[string][]Report)}
+	return &Reporter{
+		cm:      cm,
+		emitter: emitter,
+		reports: make(map[string][]Report),
+	}
 }
 
 // IsOK returns true if there are no diagnostics yet.
 func (r *Reporter) IsOK() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return len(r.reports) == 0
 }
 
 func (r *Reporter) Reports(path string) []Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return r.reports[path]
 }
 
-// Emit writes all the reports using the reporter's emitter.
+// SetOrder sets the order in which the files will be emitted, which is
+// usually the order in which modules are resolved. Files not in the given list
+// will be emitted after all the others, sorted by path.
+func (r *Reporter) SetOrder(files []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.order = make(map[string]int, len(files))
+	for i, f := range files {
+		r.order[f] = i
+	}
+}
+
+// SetMaxErrors sets the maximum number of errors that will be emitted. After
+// that, no more diagnostics will be emitted. A value of 0 or less means there
+// is no limit.
+func (r *Reporter) SetMaxErrors(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.maxErrors = n
+}
+
+// Emit writes all the reports using the reporter's emitter. Files are emitted
+// in the order given with SetOrder and the reports of every file are emitted
+// ordered by their position in the file, so the output is always the same
+// for the same set of reports.
 func (r *Reporter) Emit() error {
-	for file, reports := range r.reports {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var summary Summary
+	var emittedErrors int
+	for _, file := range r.sortedFiles() {
+		reports := r.sortedReports(file)
 		var ds = make([]*Diagnostic, 0, len(reports))
 		for _, report := range reports {
+			isError := report.Type().IsError()
+			if isError {
+				summary.Errors++
+			} else if report.Type() == Warning {
+				summary.Warnings++
+			}
+
+			if r.maxErrors > 0 && emittedErrors >= r.maxErrors {
+				if isError {
+					summary.Omitted++
+				}
+				continue
+			}
+
+			if isError {
+				emittedErrors++
+			}
+
 			d, err := r.makeDiagnostic(file, report)
 			if err != nil {
 				return err
@@ -40,47 +110,114 @@ func (r *Reporter) Emit() error {
 			ds = append(ds, d)
 		}
 
+		if len(ds) == 0 {
+			continue
+		}
+
 		if err := r.emitter.Emit(file, ds); err != nil {
 			return err
 		}
 	}
-	return nil
+
+	return r.emitter.Summary(summary)
+}
+
+// sortedFiles returns the files with reports in the order they need to be
+// emitted.
+func (r *Reporter) sortedFiles() []string {
+	files := make([]string, len(r.files))
+	copy(files, r.files)
+	sort.SliceStable(files, func(i, j int) bool {
+		oi, iok := r.order[files[i]]
+		oj, jok := r.order[files[j]]
+		if iok && jok {
+			return oi < oj
+		} else if !iok && !jok {
+			return files[i] < files[j]
+		}
+		return iok
+	})
+	return files
+}
+
+// sortedReports returns the reports of the given file ordered by their
+// position. Reports at the same position keep the order in which they were
+// reported.
+func (r *Reporter) sortedReports(file string) []Report {
+	reports := make([]Report, len(r.reports[file]))
+	copy(reports, r.reports[file])
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].Pos() < reports[j].Pos()
+	})
+	return reports
 }
 
 // Report adds a new report occurred at some path.
 func (r *Reporter) Report(path string, report Report) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.reports[path]; !ok {
+		r.files = append(r.files, path)
+	}
 	r.reports[path] = append(r.reports[path], report)
 }
 
 // makeDiagnostic transforms a report into a diagnostic, with the affected
 // snippet of code, if there is any.
 func (r *Reporter) makeDiagnostic(path string, report Report) (*Diagnostic, error) {
-	if report.Pos() == token.NoPos {
-		return &Diagnostic{
-			Type:    report.Type(),
-			Message: report.Message(),
-		}, nil
+	d := &Diagnostic{
+		Type:    report.Type(),
+		Message: report.Message(),
+	}
+
+	if report.Pos() != token.NoPos {
+		var err error
+		d.Pos, d.Region, err = r.locate(path, report.Pos(), report.Region())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, l := range report.Labels() {
+		label := &DiagnosticLabel{Path: l.Path, Message: l.Message}
+		if label.Path == "" {
+			label.Path = path
+		}
+
+		if l.Pos != token.NoPos {
+			var err error
+			label.Pos, label.Region, err = r.locate(label.Path, l.Pos, l.Region)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		d.Labels = append(d.Labels, label)
 	}
 
+	return d, nil
+}
+
+// locate returns the line position of pos and the snippet of code for the
+// given region, if any, in the file at the given path.
+func (r *Reporter) locate(path string, pos token.Pos, region *Region) (source.LinePos, *source.Snippet, error) {
 	src := r.cm.Source(path)
-	pos, err := src.LinePos(report.Pos())
+	if src == nil {
+		return source.LinePos{}, nil, fmt.Errorf("report: file %q is not in the code map", path)
+	}
+
+	lp, err := src.LinePos(pos)
 	if err != nil {
-		return nil, err
+		return lp, nil, err
 	}
-	region := report.Region()
 
 	var snippet *source.Snippet
 	if region != nil {
 		snippet, err = src.Region(region.Start, region.End)
 		if err != nil {
-			return nil, err
+			return lp, nil, err
 		}
 	}
 
-	return &Diagnostic{
-		Type:    report.Type(),
-		Message: report.Message(),
-		Pos:     pos,
-		Region:  snippet,
-	}, nil
+	return lp, snippet, nil
 }
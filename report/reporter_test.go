@@ -0,0 +1,81 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elm-tangram/tangram/source"
+	"github.com/elm-tangram/tangram/token"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestReporter(t *testing.T, buf *bytes.Buffer) *Reporter {
+	loader := source.NewMemLoader()
+	loader.Add("Main.elm", mainFixture)
+	loader.Add("Foo.elm", fooFixture)
+	cm := source.NewCodeMap(loader)
+	require.NoError(t, cm.Add("Main.elm"))
+	require.NoError(t, cm.Add("Foo.elm"))
+
+	return NewReporter(cm, &writerEmitter{buf, true, false})
+}
+
+const expectedSorted = `I found problems at file: Foo.elm
+
+syntax error: foo
+at Foo.elm:0:0
+
+I found problems at file: Main.elm
+
+name error: first
+at Main.elm:3:8
+
+warning: second
+at Main.elm:5:1
+
+name error: third
+at Main.elm:5:1
+
+Found 3 errors and 1 warning.
+`
+
+func TestReporterEmitSorted(t *testing.T) {
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	reporter := newTestReporter(t, &buf)
+	reporter.SetOrder([]string{"Foo.elm", "Main.elm"})
+
+	reporter.Report("Main.elm", NewBaseReport(Warning, token.Pos(39), "second", nil))
+	reporter.Report("Main.elm", NewBaseReport(NameError, token.Pos(39), "third", nil))
+	reporter.Report("Main.elm", NewBaseReport(NameError, token.Pos(34), "first", nil))
+	reporter.Report("Foo.elm", NewBaseReport(SyntaxError, token.Pos(0), "foo", nil))
+
+	require.NoError(reporter.Emit())
+	require.Equal(expectedSorted, buf.String())
+}
+
+const expectedMaxErrors = `I found problems at file: Foo.elm
+
+syntax error: foo
+at Foo.elm:0:0
+
+Found 3 errors and 1 warning (2 errors omitted).
+`
+
+func TestReporterEmitMaxErrors(t *testing.T) {
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	reporter := newTestReporter(t, &buf)
+	reporter.SetMaxErrors(1)
+
+	reporter.Report("Main.elm", NewBaseReport(NameError, token.Pos(34), "first", nil))
+	reporter.Report("Main.elm", NewBaseReport(Warning, token.Pos(39), "second", nil))
+	reporter.Report("Main.elm", NewBaseReport(NameError, token.Pos(39), "third", nil))
+	reporter.Report("Foo.elm", NewBaseReport(SyntaxError, token.Pos(0), "foo", nil))
+
+	require.NoError(reporter.Emit())
+	require.Equal(expectedMaxErrors, buf.String())
+}
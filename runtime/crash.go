@@ -0,0 +1,57 @@
+package runtime
+
+import "fmt"
+
+// Error is the error of an Elm program that stops it, such as a call to
+// Debug.crash, a value not matched by any branch of a case or too many
+// nested recursive calls. It is the value Crash panics with.
+type Error struct {
+	Message string
+	// Module is the Elm module with the code that crashed, if known, and
+	// Line and Column are its position in the source file, starting at 1.
+	Module       string
+	Line, Column int
+}
+
+func (e *Error) Error() string {
+	if e.Module == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (module %s, line %d, column %d)", e.Message, e.Module, e.Line, e.Column)
+}
+
+// Crash stops the program with an Error with the given message. It never
+// returns, but it has a result so it can be used as any other expression.
+func Crash(msg string) Value {
+	panic(&Error{Message: msg})
+}
+
+// CrashAt is like Crash, but the Error has the position of the Elm code that
+// crashed.
+func CrashAt(module string, line, column int, msg string) Value {
+	panic(&Error{Message: msg, Module: module, Line: line, Column: column})
+}
+
+// MaxDepth is the maximum number of nested calls to recursive definitions
+// that are not tail calls, after which the program crashes instead of
+// overflowing the stack.
+var MaxDepth = 100000
+
+// depth is the number of nested calls to recursive definitions. Programs
+// are evaluated in a single goroutine, so it is not synchronised.
+var depth int
+
+// Enter is called at the start of a recursive definition with its name and
+// crashes if there are too many nested calls. Leave must be deferred right
+// after it.
+func Enter(name string) {
+	if depth >= MaxDepth {
+		Crash(fmt.Sprintf("too much recursion: more than %d nested calls when calling %s", MaxDepth, name))
+	}
+	depth++
+}
+
+// Leave is called when a recursive definition returns or crashes.
+func Leave() {
+	depth--
+}
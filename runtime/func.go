@@ -0,0 +1,97 @@
+package runtime
+
+// Func is a curried Elm function, which takes Arity arguments at once. It
+// can be applied to fewer arguments, which returns a function that takes the
+// rest of them, or to more, in which case the result is applied to the
+// remaining arguments. Use Apply to call it.
+type Func struct {
+	Arity int
+	Call  func(args []Value) Value
+}
+
+// Fn returns a function of the given arity.
+func Fn(arity int, call func(args []Value) Value) *Func {
+	return &Func{arity, call}
+}
+
+// F1 returns a function of one argument.
+func F1(fn func(a Value) Value) *Func {
+	return Fn(1, func(args []Value) Value { return fn(args[0]) })
+}
+
+// F2 returns a function of two arguments.
+func F2(fn func(a, b Value) Value) *Func {
+	return Fn(2, func(args []Value) Value { return fn(args[0], args[1]) })
+}
+
+// F3 returns a function of three arguments.
+func F3(fn func(a, b, c Value) Value) *Func {
+	return Fn(3, func(args []Value) Value { return fn(args[0], args[1], args[2]) })
+}
+
+// F4 returns a function of four arguments.
+func F4(fn func(a, b, c, d Value) Value) *Func {
+	return Fn(4, func(args []Value) Value {
+		return fn(args[0], args[1], args[2], args[3])
+	})
+}
+
+// F5 returns a function of five arguments.
+func F5(fn func(a, b, c, d, e Value) Value) *Func {
+	return Fn(5, func(args []Value) Value {
+		return fn(args[0], args[1], args[2], args[3], args[4])
+	})
+}
+
+// F6 returns a function of six arguments.
+func F6(fn func(a, b, c, d, e, f Value) Value) *Func {
+	return Fn(6, func(args []Value) Value {
+		return fn(args[0], args[1], args[2], args[3], args[4], args[5])
+	})
+}
+
+// F7 returns a function of seven arguments.
+func F7(fn func(a, b, c, d, e, f, g Value) Value) *Func {
+	return Fn(7, func(args []Value) Value {
+		return fn(args[0], args[1], args[2], args[3], args[4], args[5], args[6])
+	})
+}
+
+// F8 returns a function of eight arguments.
+func F8(fn func(a, b, c, d, e, f, g, h Value) Value) *Func {
+	return Fn(8, func(args []Value) Value {
+		return fn(args[0], args[1], args[2], args[3], args[4], args[5], args[6], args[7])
+	})
+}
+
+// F9 returns a function of nine arguments.
+func F9(fn func(a, b, c, d, e, f, g, h, i Value) Value) *Func {
+	return Fn(9, func(args []Value) Value {
+		return fn(args[0], args[1], args[2], args[3], args[4], args[5], args[6], args[7], args[8])
+	})
+}
+
+// Apply applies the function to the arguments.
+func Apply(f Value, args ...Value) Value {
+	for {
+		fn, ok := f.(*Func)
+		if !ok {
+			return Crash("a value that is not a function was applied to some arguments")
+		}
+
+		switch {
+		case len(args) == fn.Arity:
+			return fn.Call(args)
+		case len(args) < fn.Arity:
+			applied := args
+			return Fn(fn.Arity-len(args), func(rest []Value) Value {
+				all := make([]Value, 0, fn.Arity)
+				all = append(all, applied...)
+				return fn.Call(append(all, rest...))
+			})
+		default:
+			f = fn.Call(args[:fn.Arity])
+			args = args[fn.Arity:]
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package runtime
+
+import "fmt"
+
+// Lazy is a top-level value that is not a function, which is computed the
+// first time it is used instead of when the package is initialised, so the
+// native values it uses are already registered. Its zero value is a value
+// not computed yet.
+type Lazy struct {
+	state lazyState
+	value Value
+}
+
+type lazyState int
+
+const (
+	lazyPending lazyState = iota
+	lazyComputing
+	lazyDone
+)
+
+// Get returns the value, computing it with the given function the first
+// time. It crashes if computing the value with the given name needs the
+// value itself. Programs are evaluated in a single goroutine, so it is not
+// synchronised.
+func (l *Lazy) Get(name string, compute func() Value) Value {
+	switch l.state {
+	case lazyDone:
+		return l.value
+	case lazyComputing:
+		return Crash(fmt.Sprintf("the value of %s depends on itself", name))
+	}
+
+	l.state = lazyComputing
+	defer func() {
+		// a crash leaves the value to be computed again
+		if l.state == lazyComputing {
+			l.state = lazyPending
+		}
+	}()
+
+	l.value = compute()
+	l.state = lazyDone
+	return l.value
+}
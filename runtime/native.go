@@ -0,0 +1,24 @@
+package runtime
+
+import "fmt"
+
+// natives are the values of the native modules, indexed by the module and
+// the name of the value.
+var natives = make(map[string]Value)
+
+// Register registers the value with the given name of a native module, such
+// as Native.List, so Elm code can use it. It is meant to be called when the
+// package implementing the module is initialised.
+func Register(module, name string, v Value) {
+	natives[module+"."+name] = v
+}
+
+// Native returns the value with the given name of a native module, and
+// crashes if it was not registered.
+func Native(module, name string) Value {
+	v, ok := natives[module+"."+name]
+	if !ok {
+		return Crash(fmt.Sprintf("%s.%s is not implemented", module, name))
+	}
+	return v
+}
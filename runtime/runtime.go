@@ -0,0 +1,55 @@
+// Package runtime is the support code of the Go programs generated from Elm
+// code. It defines the representation of functions, lists and tuples, the
+// errors that stop a program, the guard of the depth of recursive calls, the
+// registry of native values and the top-level values computed when they are
+// first used.
+//
+// Every Elm value is a Value. Ints are int64, Floats are float64, Chars are
+// runes, Strings are strings and Bools are bools. The values of union types
+// and records are the structs generated for them.
+package runtime
+
+// Value is an Elm value.
+type Value interface{}
+
+// Tuple is an Elm tuple. The tuple with no elements is the unit value.
+type Tuple []Value
+
+// List is an Elm list, which is either Nil or a Cons.
+type List interface {
+	isList()
+}
+
+// Nil is the empty list.
+type Nil struct{}
+
+// Cons is a list made of its first element and the rest of the list.
+type Cons struct {
+	Head Value
+	Tail Value
+}
+
+func (Nil) isList()  {}
+func (Cons) isList() {}
+
+// NewList returns a list with the given elements.
+func NewList(elems ...Value) Value {
+	var list Value = Nil{}
+	for i := len(elems) - 1; i >= 0; i-- {
+		list = Cons{elems[i], list}
+	}
+	return list
+}
+
+// Elems returns the elements of a list.
+func Elems(list Value) []Value {
+	var elems []Value
+	for {
+		c, ok := list.(Cons)
+		if !ok {
+			return elems
+		}
+		elems = append(elems, c.Head)
+		list = c.Tail
+	}
+}
@@ -0,0 +1,123 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func add3(a, b, c Value) Value {
+	return a.(int64) + b.(int64) + c.(int64)
+}
+
+func TestApply(t *testing.T) {
+	require := require.New(t)
+
+	f := F3(add3)
+	require.Equal(int64(6), Apply(f, int64(1), int64(2), int64(3)))
+
+	partial := Apply(f, int64(1))
+	require.Equal(2, partial.(*Func).Arity)
+	require.Equal(int64(6), Apply(partial, int64(2), int64(3)))
+	require.Equal(int64(6), Apply(Apply(partial, int64(2)), int64(3)))
+
+	// the arguments of a partial application are not shared
+	p1 := Apply(f, int64(1), int64(1))
+	p2 := Apply(f, int64(1), int64(2))
+	require.Equal(int64(3), Apply(p1, int64(1)))
+	require.Equal(int64(4), Apply(p2, int64(1)))
+
+	curried := F1(func(a Value) Value {
+		return F1(func(b Value) Value { return a.(int64) * b.(int64) })
+	})
+	require.Equal(int64(6), Apply(curried, int64(2), int64(3)))
+
+	require.Panics(func() { Apply(int64(1), int64(2)) })
+}
+
+func TestList(t *testing.T) {
+	require := require.New(t)
+
+	list := NewList(int64(1), int64(2))
+	require.Equal(Cons{int64(1), Cons{int64(2), Nil{}}}, list)
+	require.Equal([]Value{int64(1), int64(2)}, Elems(list))
+	require.Nil(Elems(NewList()))
+}
+
+// requireCrash checks that the function crashes with the given message.
+func requireCrash(t *testing.T, msg string, fn func()) {
+	defer func() {
+		err, ok := recover().(*Error)
+		require.True(t, ok, "expected a crash")
+		require.Equal(t, msg, err.Error())
+	}()
+	fn()
+}
+
+func TestCrash(t *testing.T) {
+	requireCrash(t, "oops", func() { Crash("oops") })
+	requireCrash(t, "oops (module Main, line 3, column 5)", func() { CrashAt("Main", 3, 5, "oops") })
+}
+
+func TestEnter(t *testing.T) {
+	require := require.New(t)
+
+	defer func(max int) { MaxDepth = max }(MaxDepth)
+	MaxDepth = 10
+
+	var count func(n int) int
+	count = func(n int) int {
+		Enter("count")
+		defer Leave()
+		if n == 0 {
+			return 0
+		}
+		return 1 + count(n-1)
+	}
+
+	require.Equal(9, count(9))
+	require.Equal(0, depth)
+
+	requireCrash(t, "too much recursion: more than 10 nested calls when calling count", func() {
+		count(20)
+	})
+	require.Equal(0, depth)
+}
+
+func TestNative(t *testing.T) {
+	require := require.New(t)
+
+	Register("Native.Test", "answer", int64(42))
+	require.Equal(int64(42), Native("Native.Test", "answer"))
+	requireCrash(t, "Native.Test.missing is not implemented", func() {
+		Native("Native.Test", "missing")
+	})
+}
+
+func TestLazy(t *testing.T) {
+	require := require.New(t)
+
+	var calls int
+	var l Lazy
+	compute := func() Value {
+		calls++
+		return int64(42)
+	}
+	require.Equal(int64(42), l.Get("Test.answer", compute))
+	require.Equal(int64(42), l.Get("Test.answer", compute))
+	require.Equal(1, calls)
+
+	var loop Lazy
+	var self func() Value
+	self = func() Value { return loop.Get("Test.loop", self) }
+	requireCrash(t, "the value of Test.loop depends on itself", func() {
+		self()
+	})
+
+	// a value that crashed is computed again
+	var failing Lazy
+	requireCrash(t, "failed", func() {
+		failing.Get("Test.failing", func() Value { return Crash("failed") })
+	})
+	require.Equal(int64(1), failing.Get("Test.failing", func() Value { return int64(1) }))
+}
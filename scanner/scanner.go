@@ -39,11 +39,25 @@ const (
 	hexDigits = "0123456789abcdefABCDEF"
 )
 
+// Mode controls which tokens are emitted by the scanner.
+type Mode uint
+
+const (
+	// SkipTrivia discards whitespace and end of lines. Comments are emitted
+	// as tokens anyway.
+	SkipTrivia Mode = iota
+	// KeepTrivia emits whitespace and end of lines as tokens as well, so
+	// the values of all the tokens emitted put together are exactly the
+	// input.
+	KeepTrivia
+)
+
 // Scanner is in charge of extracting tokens from a source.
 type Scanner struct {
 	source string
 	reader *bufio.Reader
 	state  stateFunc
+	mode   Mode
 
 	pos     int
 	start   int
@@ -56,12 +70,18 @@ type Scanner struct {
 	tokens []*token.Token
 }
 
-// New creates a new scanner for the input.
+// New creates a new scanner for the input that skips the trivia.
 func New(source string, input io.Reader) *Scanner {
+	return NewWithMode(source, input, SkipTrivia)
+}
+
+// NewWithMode creates a new scanner for the input with the given mode.
+func NewWithMode(source string, input io.Reader, mode Mode) *Scanner {
 	return &Scanner{
 		source: source,
 		reader: bufio.NewReader(input),
 		state:  lexExpr,
+		mode:   mode,
 		line:   1,
 	}
 }
@@ -112,14 +132,20 @@ func (l *Scanner) peekWord() string {
 
 // emit sends the token to the consumer.
 func (l *Scanner) emit(t token.Type) {
+	l.emitAt(t, l.line, l.linePos-len(l.word)+1)
+}
+
+// emitAt sends the token to the consumer with the given line and column of
+// its start, for tokens that span multiple lines.
+func (l *Scanner) emitAt(t token.Type, line, linePos int) {
 	word := l.peekWord()
 	l.word = nil
 	l.tokens = append(l.tokens, token.New(
 		t,
 		l.source,
 		l.start,
-		l.linePos-len(word)+1,
-		l.line,
+		linePos,
+		line,
 		word,
 	))
 	l.start = l.pos
@@ -131,6 +157,22 @@ func (l *Scanner) ignore() {
 	l.word = nil
 }
 
+// trivia emits the pending input as a trivia token of type t starting at the
+// given line and column if the scanner keeps the trivia, or skips over it
+// otherwise.
+func (l *Scanner) trivia(t token.Type, line, linePos int) {
+	if l.mode == KeepTrivia {
+		l.emitAt(t, line, linePos)
+	} else {
+		l.ignore()
+	}
+}
+
+// startPos returns the line and column of the pending input.
+func (l *Scanner) startPos() (line, linePos int) {
+	return l.line, l.linePos - len(l.word) + 1
+}
+
 // accept consumes a rune if it's from the valid set and reports if it was accepted or not.
 func (l *Scanner) accept(valid string) (bool, error) {
 	r, err := l.next()
@@ -163,7 +205,10 @@ func (l *Scanner) acceptRun(valid string) error {
 // peekN checks the next `n` runes are the given one.
 func (l *Scanner) peekN(valid rune, n int) (bool, error) {
 	v, err := l.reader.Peek(n)
-	if err != nil {
+	if err == io.EOF {
+		// there are less than n runes left
+		return false, nil
+	} else if err != nil {
 		return false, err
 	}
 
@@ -176,6 +221,18 @@ func (l *Scanner) peekN(valid rune, n int) (bool, error) {
 	return true, nil
 }
 
+// peekString checks the next runes are the given string.
+func (l *Scanner) peekString(s string) (bool, error) {
+	v, err := l.reader.Peek(len(s))
+	if err == io.EOF {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return string(v) == s, nil
+}
+
 // advance consumes the next n runes.
 func (l *Scanner) advance(n int) error {
 	for i := 0; i < n; i++ {
@@ -230,13 +287,25 @@ func (l *Scanner) errorf(format string, args ...interface{}) stateFunc {
 }
 
 // scanNumber scans a number and returns if the termination is valid
-// can detect integers, floats and integer ranges
+// can detect integers, hexadecimal integers, floats with or without
+// exponent and integer ranges
 func (l *Scanner) scanNumber() (bool, token.Type, error) {
 	var t = token.Int
 	if err := l.acceptRun(numDigits); err != nil {
 		return false, t, err
 	}
 
+	if l.peekWord() == "0" {
+		ok, err := l.accept("x")
+		if err != nil {
+			return false, t, err
+		}
+
+		if ok {
+			return l.scanHexNumber()
+		}
+	}
+
 	ok, err := l.accept(".")
 	if err != nil {
 		return false, t, err
@@ -264,6 +333,48 @@ func (l *Scanner) scanNumber() (bool, token.Type, error) {
 		}
 	}
 
+	ok, err = l.accept("eE")
+	if err != nil {
+		return false, t, err
+	}
+
+	if ok {
+		t = token.Float
+		if _, err := l.accept("+-"); err != nil {
+			return false, t, err
+		}
+
+		ok, err := l.accept(numDigits)
+		if err != nil || !ok {
+			return false, t, err
+		}
+
+		if err := l.acceptRun(numDigits); err != nil {
+			return false, t, err
+		}
+	}
+
+	return l.numberEnd(t)
+}
+
+// scanHexNumber scans the digits of an hexadecimal integer. The "0x" prefix
+// has already been scanned.
+func (l *Scanner) scanHexNumber() (bool, token.Type, error) {
+	ok, err := l.accept(hexDigits)
+	if err != nil || !ok {
+		return false, token.Int, err
+	}
+
+	if err := l.acceptRun(hexDigits); err != nil {
+		return false, token.Int, err
+	}
+
+	return l.numberEnd(token.Int)
+}
+
+// numberEnd reports whether the number scanned is correctly terminated,
+// that is, it is not followed by a rune allowed in identifiers.
+func (l *Scanner) numberEnd(t token.Type) (bool, token.Type, error) {
 	r, err := l.peek()
 	if err != nil {
 		return false, t, err
@@ -276,6 +387,11 @@ func (l *Scanner) scanNumber() (bool, token.Type, error) {
 	return true, t, nil
 }
 
+// Tokens returns all the tokens emitted by the scanner.
+func (l *Scanner) Tokens() []*token.Token {
+	return l.tokens
+}
+
 // Next returns the next Token available in the scanner.
 func (l *Scanner) Next() *token.Token {
 	if len(l.tokens) <= l.idx {
@@ -378,6 +494,15 @@ func lexExpr(l *Scanner) (stateFunc, error) {
 	case r == rightParen:
 		return lexRightParen, nil
 	case r == leftBracket:
+		ok, err := l.peekString(glslStart)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			return lexGLSL, nil
+		}
+
 		return lexLeftBracket, nil
 	case r == rightBracket:
 		return lexRightBracket, nil
@@ -524,10 +649,16 @@ func lexChar(l *Scanner) (stateFunc, error) {
 	if r == eof {
 		return l.errorf("not closed character: %q", l.peekWord()), nil
 	} else if r == backslash {
-		_, err := l.next()
+		r, err := l.next()
 		if err != nil {
 			return nil, err
 		}
+
+		if r == 'u' {
+			if err := l.scanUnicodeEscape(); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	ok, err := l.accept("'")
@@ -543,11 +674,33 @@ func lexChar(l *Scanner) (stateFunc, error) {
 	return lexExpr, nil
 }
 
+// scanUnicodeEscape scans the code point of an unicode escape sequence like
+// \u{1F600}. The "\u" has already been scanned. Malformed escape sequences
+// are left for the closing quote check to report.
+func (l *Scanner) scanUnicodeEscape() error {
+	ok, err := l.accept("{")
+	if err != nil || !ok {
+		return err
+	}
+
+	if err := l.acceptRun(hexDigits); err != nil {
+		return err
+	}
+
+	_, err = l.accept("}")
+	return err
+}
+
 // lexEOL scans all end of lines.
 func lexEOL(l *Scanner) (stateFunc, error) {
+	line, linePos := l.startPos()
 	l.newLine()
 	for {
 		r, err := l.next()
+		if err == io.EOF && l.mode == KeepTrivia {
+			l.emitAt(token.Newline, line, linePos)
+		}
+
 		if err != nil {
 			return nil, err
 		}
@@ -560,14 +713,19 @@ func lexEOL(l *Scanner) (stateFunc, error) {
 		}
 	}
 
-	l.ignore()
+	l.trivia(token.Newline, line, linePos)
 	return lexExpr, nil
 }
 
 // lexSpaces scanns a run of space chars.
 func lexSpaces(l *Scanner) (stateFunc, error) {
+	line, linePos := l.startPos()
 	for {
 		r, err := l.next()
+		if err == io.EOF && l.mode == KeepTrivia {
+			l.emitAt(token.Whitespace, line, linePos)
+		}
+
 		if err != nil {
 			return nil, err
 		}
@@ -578,7 +736,7 @@ func lexSpaces(l *Scanner) (stateFunc, error) {
 	}
 
 	l.backup()
-	l.ignore()
+	l.trivia(token.Whitespace, line, linePos)
 	return lexExpr, nil
 }
 
@@ -602,8 +760,13 @@ func lexNumber(l *Scanner) (stateFunc, error) {
 
 // lexComment scans a comment. The '--' delimiter has already been scanned.
 func lexComment(l *Scanner) (stateFunc, error) {
+	line, linePos := l.startPos()
 	for {
 		r, err := l.next()
+		if err == io.EOF && l.mode == KeepTrivia {
+			l.emitAt(token.Comment, line, linePos)
+		}
+
 		if err != nil {
 			return nil, err
 		}
@@ -616,7 +779,10 @@ func lexComment(l *Scanner) (stateFunc, error) {
 	}
 }
 
+// lexMultiLineComment scans a multi-line comment. The '{' delimiter has
+// already been scanned.
 func lexMultiLineComment(l *Scanner) (stateFunc, error) {
+	line, linePos := l.startPos()
 	for {
 		r, err := l.next()
 		if err != nil {
@@ -630,7 +796,7 @@ func lexMultiLineComment(l *Scanner) (stateFunc, error) {
 			}
 
 			if nr == rightBrace {
-				l.emit(token.Comment)
+				l.emitAt(token.Comment, line, linePos)
 				return lexExpr, nil
 			}
 		} else if isEOL(r) {
@@ -674,45 +840,115 @@ func lexString(l *Scanner) (stateFunc, error) {
 	}
 
 	if ok {
-		if err := l.advance(2); err != nil {
+		return lexMultiLineString(l)
+	}
+
+	for {
+		stop, err := lexInsideString(l)
+		if err != nil {
+			return l.errorf("quoted string not closed properly: %q", l.peekWord()), nil
+		}
+
+		if stop {
+			break
+		}
+	}
+
+	l.emit(token.String)
+	return lexExpr, nil
+}
+
+// lexMultiLineString scans a string delimited by triple quotes, which can
+// span multiple lines. The first quote has already been scanned. The token
+// is emitted with the line and column of its start.
+func lexMultiLineString(l *Scanner) (stateFunc, error) {
+	line, linePos := l.line, l.linePos-len(l.word)+1
+	if err := l.advance(2); err != nil {
+		return nil, err
+	}
+
+	for {
+		r, err := l.next()
+		if err == io.EOF {
+			return l.errorf("multi-line string not closed properly"), nil
+		} else if err != nil {
 			return nil, err
 		}
 
-		for {
-			r, err := l.next()
+		switch {
+		case r == backslash:
+			// the escaped rune can never end the string
+			rn, err := l.next()
+			if err == io.EOF {
+				return l.errorf("multi-line string not closed properly"), nil
+			} else if err != nil {
+				return nil, err
+			}
+
+			if isEOL(rn) {
+				l.newLine()
+			}
+		case isEOL(r):
+			l.newLine()
+		case r == quote:
+			ok, err := l.peekN(quote, 2)
 			if err != nil {
 				return nil, err
 			}
 
-			if r == quote {
-				ok, err := l.peekN(quote, 2)
-				if err != nil {
+			if ok {
+				if err := l.advance(2); err != nil {
 					return nil, err
 				}
 
-				if ok {
-					if err := l.advance(2); err != nil {
-						return nil, err
-					}
-					break
-				}
+				l.emitAt(token.String, line, linePos)
+				return lexExpr, nil
 			}
 		}
-	} else {
-		for {
-			stop, err := lexInsideString(l)
+	}
+}
+
+const (
+	glslStart = "glsl|"
+	glslEnd   = "|]"
+)
+
+// lexGLSL scans a GLSL shader block. The opening bracket has already been
+// scanned. The token is emitted with the line and column of its start and
+// its value is the whole block, including the delimiters.
+func lexGLSL(l *Scanner) (stateFunc, error) {
+	line, linePos := l.line, l.linePos-len(l.word)+1
+	if err := l.advance(len(glslStart)); err != nil {
+		return nil, err
+	}
+
+	for {
+		r, err := l.next()
+		if err == io.EOF {
+			return l.errorf("glsl shader block not closed properly"), nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case isEOL(r):
+			l.newLine()
+		case r == pipe:
+			ok, err := l.peekString(glslEnd[1:])
 			if err != nil {
-				return l.errorf("quoted string not closed properly: %q", l.peekWord()), nil
+				return nil, err
 			}
 
-			if stop {
-				break
+			if ok {
+				if err := l.advance(len(glslEnd) - 1); err != nil {
+					return nil, err
+				}
+
+				l.emitAt(token.GLSL, line, linePos)
+				return lexExpr, nil
 			}
 		}
 	}
-
-	l.emit(token.String)
-	return lexExpr, nil
 }
 
 // lexIdentifier scans an identifier. First character is already scanned.
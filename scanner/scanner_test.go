@@ -1,6 +1,7 @@
 package scanner
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 
@@ -34,6 +35,43 @@ func TestLexNumber(t *testing.T) {
 		require.Equal(token.Float, tokens[0].Type)
 		require.Equal("24.56", tokens[0].Value)
 	})
+
+	cases := []struct {
+		input string
+		typ   token.Type
+		value string
+	}{
+		{"0xFF ", token.Int, "0xFF"},
+		{"0x1a2B ", token.Int, "0x1a2B"},
+		{"1e-3 ", token.Float, "1e-3"},
+		{"6.02e23 ", token.Float, "6.02e23"},
+		{"2E+10 ", token.Float, "2E+10"},
+		{"0 ", token.Int, "0"},
+		{"0x ", token.Error, ""},
+		{"0xFG ", token.Error, ""},
+		{"1e ", token.Error, ""},
+		{"1e-x ", token.Error, ""},
+	}
+
+	for _, c := range cases {
+		testLexState(t, c.input, lexNumber, func(l *Scanner, tokens []*token.Token) {
+			require.Equal(1, len(tokens), c.input)
+			require.Equal(c.typ, tokens[0].Type, c.input)
+			if c.typ != token.Error {
+				require.Equal(c.value, tokens[0].Value, c.input)
+			}
+		})
+	}
+}
+
+func TestLexUnicodeEscape(t *testing.T) {
+	testLex(t, `foo = '\u{1F600}' "a\u{41}b"`, []expectedToken{
+		{"foo", token.Identifier},
+		{"=", token.Assign},
+		{`'\u{1F600}'`, token.Char},
+		{`"a\u{41}b"`, token.String},
+		{"", token.EOF},
+	})
 }
 
 func TestLexString(t *testing.T) {
@@ -60,6 +98,101 @@ func TestLexString(t *testing.T) {
 	})
 }
 
+const testMultiLineString = "query = \"\"\"\n  SELECT * FROM \"users\"\n  WHERE name = \\\"\"\" AND age > 3\n\"\"\"\nempty = \"\""
+
+func TestLexMultiLineString(t *testing.T) {
+	require := require.New(t)
+
+	l := New("test", strings.NewReader(testMultiLineString))
+	l.Run()
+
+	cases := []struct {
+		value        string
+		typ          token.Type
+		line, column int
+	}{
+		{"query", token.Identifier, 1, 1},
+		{"=", token.Assign, 1, 7},
+		{"\"\"\"\n  SELECT * FROM \"users\"\n  WHERE name = \\\"\"\" AND age > 3\n\"\"\"", token.String, 1, 9},
+		{"empty", token.Identifier, 5, 1},
+		{"=", token.Assign, 5, 7},
+		{`""`, token.String, 5, 9},
+	}
+
+	require.Len(l.tokens, len(cases)+1)
+	require.Equal(token.EOF, l.tokens[len(cases)].Type)
+	for i, c := range cases {
+		tok := l.tokens[i]
+		require.Equal(c.typ, tok.Type, "type of token %d", i)
+		require.Equal(c.value, tok.Value, "value of token %d", i)
+		require.Equal(c.line, tok.Line, "line of token %d", i)
+		require.Equal(c.column, tok.Column, "column of token %d", i)
+		require.Equal(strings.Index(testMultiLineString[tok.Offset:], c.value), 0, "offset of token %d", i)
+	}
+}
+
+const testUnclosedMultiLineString = `
+foo = """unclosed
+  \"""
+`
+
+func TestLexUnclosedMultiLineString(t *testing.T) {
+	testLex(t, testUnclosedMultiLineString, []expectedToken{
+		{"foo", token.Identifier},
+		{"=", token.Assign},
+		{"", token.Error},
+	})
+}
+
+const testGLSL = `
+shader = [glsl|
+  uniform vec3 color;
+  void main () { gl_FragColor = vec4(color, 1.0) | 0; }
+|] [a]
+`
+
+func TestLexGLSL(t *testing.T) {
+	require := require.New(t)
+
+	l := New("test", strings.NewReader(testGLSL))
+	l.Run()
+
+	shader := "[glsl|\n  uniform vec3 color;\n  void main () { gl_FragColor = vec4(color, 1.0) | 0; }\n|]"
+	expected := []expectedToken{
+		{"shader", token.Identifier},
+		{"=", token.Assign},
+		{shader, token.GLSL},
+		{"[", token.LeftBracket},
+		{"a", token.Identifier},
+		{"]", token.RightBracket},
+		{"\n", token.EOF},
+	}
+
+	require.Len(l.tokens, len(expected))
+	for i, e := range expected {
+		require.Equal(e.typ, l.tokens[i].Type, "type of token %d", i)
+		require.Equal(e.value, l.tokens[i].Value, "value of token %d", i)
+	}
+
+	require.Equal(2, l.tokens[2].Line)
+	require.Equal(10, l.tokens[2].Column)
+	require.Equal(strings.Index(testGLSL, "[glsl|"), int(l.tokens[2].Offset))
+	require.Equal(5, l.tokens[3].Line)
+	require.Equal(4, l.tokens[3].Column)
+}
+
+const testUnclosedGLSL = `
+shader = [glsl| void main () {}
+`
+
+func TestLexUnclosedGLSL(t *testing.T) {
+	testLex(t, testUnclosedGLSL, []expectedToken{
+		{"shader", token.Identifier},
+		{"=", token.Assign},
+		{"", token.Error},
+	})
+}
+
 const testRecord = `
 type alias Foo = 
 	{ myInt : Int 
@@ -230,6 +363,51 @@ func TestMultiLineComment(t *testing.T) {
 	})
 }
 
+const testTrivia = "module Main\r\n\n{- a\n  comment -}  foo =\t1 -- one"
+
+func TestLexTrivia(t *testing.T) {
+	require := require.New(t)
+
+	l := NewWithMode("test", strings.NewReader(testTrivia), KeepTrivia)
+	l.Run()
+
+	cases := []struct {
+		value        string
+		typ          token.Type
+		line, column int
+	}{
+		{"module", token.Module, 1, 1},
+		{" ", token.Whitespace, 1, 7},
+		{"Main", token.Identifier, 1, 8},
+		{"\r\n\n", token.Newline, 1, 12},
+		{"{- a\n  comment -}", token.Comment, 4, 1},
+		{"  ", token.Whitespace, 5, 13},
+		{"foo", token.Identifier, 5, 15},
+		{" ", token.Whitespace, 5, 18},
+		{"=", token.Assign, 5, 19},
+		{"\t", token.Whitespace, 5, 20},
+		{"1", token.Int, 5, 21},
+		{" ", token.Whitespace, 5, 22},
+		{"-- one", token.Comment, 5, 23},
+		{"", token.EOF, 0, 0},
+	}
+
+	tokens := l.Tokens()
+	require.Equal(len(cases), len(tokens))
+
+	var buf bytes.Buffer
+	for i, c := range cases {
+		require.Equal(c.typ, tokens[i].Type, c.value)
+		require.Equal(c.value, tokens[i].Value)
+		if c.typ != token.EOF {
+			require.Equal(c.line, tokens[i].Line, c.value)
+			require.Equal(c.column, tokens[i].Column, c.value)
+		}
+		buf.WriteString(tokens[i].Value)
+	}
+	require.Equal(testTrivia, buf.String())
+}
+
 const testInfixOp = "theMax = 3 `max` 5"
 
 func TestInfixOp(t *testing.T) {
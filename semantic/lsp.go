@@ -0,0 +1,107 @@
+package semantic
+
+import (
+	"strings"
+
+	"github.com/elm-tangram/tangram/source"
+)
+
+// LSPTokenTypes is the legend of the token types used by EncodeLSP, which
+// are the standard token types of the Language Server Protocol.
+var LSPTokenTypes = []string{
+	"keyword",
+	"comment",
+	"string",
+	"number",
+	"operator",
+	"namespace",
+	"type",
+	"typeParameter",
+	"enumMember",
+	"variable",
+	"property",
+}
+
+// LSPTokenModifiers is the legend of the token modifiers used by EncodeLSP,
+// in the order of the bits of Modifier.
+var LSPTokenModifiers = []string{
+	"declaration",
+	"defaultLibrary",
+}
+
+// lspTypes are the indexes in LSPTokenTypes of the token type of each kind.
+var lspTypes = map[Kind]uint32{
+	Keyword:      0,
+	Comment:      1,
+	String:       2,
+	Number:       3,
+	Operator:     4,
+	Module:       5,
+	NativeModule: 5,
+	Type:         6,
+	TypeVariable: 7,
+	Constructor:  8,
+	Variable:     9,
+	Field:        10,
+}
+
+// EncodeLSP returns the data of the response to a semantic tokens request of
+// the Language Server Protocol for the given tokens of the source. Each token
+// is encoded as five integers: its line and start character, relative to the
+// previous token, its length, its type and its modifiers. Characters are
+// counted in UTF-16 code units. Tokens with no LSP token type, such as
+// punctuation, are left out and tokens that span multiple lines, such as
+// block comments, are split into one token per line.
+func EncodeLSP(src *source.Source, tokens []*Token) ([]uint32, error) {
+	var (
+		data              []uint32
+		prevLine, prevCol int
+	)
+
+	for _, t := range tokens {
+		typ, ok := lspTypes[t.Kind]
+		if !ok {
+			continue
+		}
+
+		pos, err := src.Position(t.Offset, source.UTF16Column)
+		if err != nil {
+			return nil, err
+		}
+
+		line, col := pos.Line-1, pos.Col-1
+		for _, part := range strings.Split(t.Value, "\n") {
+			part = strings.TrimSuffix(part, "\r")
+			if length := utf16Len(part); length > 0 {
+				deltaCol := col
+				if line == prevLine {
+					deltaCol -= prevCol
+				}
+
+				data = append(data,
+					uint32(line-prevLine),
+					uint32(deltaCol),
+					uint32(length),
+					typ,
+					uint32(t.Modifiers),
+				)
+				prevLine, prevCol = line, col
+			}
+			line, col = line+1, 0
+		}
+	}
+	return data, nil
+}
+
+// utf16Len returns the length of the string in UTF-16 code units.
+func utf16Len(s string) int {
+	var n int
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
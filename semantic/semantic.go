@@ -0,0 +1,372 @@
+// Package semantic classifies the tokens of a module by what they mean, using
+// the objects the identifiers were resolved to, so editors and syntax
+// highlighters can color the code with more precision than the tokens alone
+// allow.
+package semantic
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/token"
+)
+
+// Kind is the semantic kind of a token.
+type Kind byte
+
+const (
+	// Unknown is the kind of tokens with no meaning, such as errors, the end
+	// of the input and trivia.
+	Unknown Kind = iota
+	// Keyword is a reserved word of the language.
+	Keyword
+	// Comment is a line or block comment.
+	Comment
+	// String is a string, char or GLSL shader literal.
+	String
+	// Number is an integer or float literal.
+	Number
+	// Operator is an operator, an infix function or a range.
+	Operator
+	// Punctuation is a delimiter or separator, such as parenthesis, commas
+	// or arrows.
+	Punctuation
+	// Module is the name of a module or one of its parts.
+	Module
+	// NativeModule is the name of a native module.
+	NativeModule
+	// Type is the name of a type or a type alias.
+	Type
+	// TypeVariable is a type variable.
+	TypeVariable
+	// Constructor is a constructor of a union type.
+	Constructor
+	// Variable is a value, function or argument.
+	Variable
+	// Field is the name of a record field.
+	Field
+)
+
+var kindStrings = [...]string{
+	"unknown",
+	"keyword",
+	"comment",
+	"string",
+	"number",
+	"operator",
+	"punctuation",
+	"module",
+	"native module",
+	"type",
+	"type variable",
+	"constructor",
+	"variable",
+	"field",
+}
+
+func (k Kind) String() string {
+	if int(k) >= len(kindStrings) {
+		return kindStrings[0]
+	}
+	return kindStrings[k]
+}
+
+// Modifier is a set of additional properties of a token.
+type Modifier byte
+
+const (
+	// Declaration marks the identifiers that declare a name.
+	Declaration Modifier = 1 << iota
+	// Builtin marks the identifiers of builtin types.
+	Builtin
+)
+
+var modifierStrings = []string{"declaration", "builtin"}
+
+// Has reports whether all the modifiers in o are set in m.
+func (m Modifier) Has(o Modifier) bool {
+	return m&o == o
+}
+
+// Strings returns the names of the modifiers that are set.
+func (m Modifier) Strings() []string {
+	var result []string
+	for i, s := range modifierStrings {
+		if m.Has(1 << uint(i)) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// Token is a token along with its semantic classification.
+type Token struct {
+	*token.Token
+	Kind      Kind
+	Modifiers Modifier
+	// Obj is the object the identifier of the token was resolved to, if
+	// any.
+	Obj *ast.Object
+}
+
+// Classify returns the semantic classification of the given tokens, which
+// must be the tokens of the given module. Identifiers are classified using
+// the objects they were resolved to and, if they were not resolved, the
+// place where they appear in the module. The module can be nil, in which
+// case only the tokens are used to classify them.
+func Classify(mod *ast.Module, tokens []*token.Token) []*Token {
+	c := &classifier{idents: make(map[token.Pos]*ident)}
+	if mod != nil {
+		ast.WalkFunc(mod, c.visit)
+	}
+
+	result := make([]*Token, len(tokens))
+	for i, t := range tokens {
+		result[i] = c.classify(t)
+	}
+	return result
+}
+
+// ident is the classification of an identifier.
+type ident struct {
+	*ast.Ident
+	kind      Kind
+	modifiers Modifier
+}
+
+type classifier struct {
+	idents map[token.Pos]*ident
+}
+
+// set classifies the given identifier, unless it was already classified by
+// one of its ancestors.
+func (c *classifier) set(id *ast.Ident, kind Kind, modifiers Modifier) {
+	if id == nil {
+		return
+	}
+
+	if _, ok := c.idents[id.NamePos]; !ok {
+		c.idents[id.NamePos] = &ident{id, kind, modifiers}
+	}
+}
+
+// setPath classifies the identifiers of a possibly qualified name. The
+// qualifiers are modules and the last identifier has the given kind. If
+// there is a lower case identifier in the path, the path is an access to
+// the fields of a variable instead.
+func (c *classifier) setPath(expr ast.Expr, last Kind) {
+	path := flatten(expr)
+	for i, id := range path {
+		switch {
+		case !isUpper(id.Name):
+			c.set(id, Variable, 0)
+			for _, f := range path[i+1:] {
+				c.set(f, Field, 0)
+			}
+			return
+		case i == len(path)-1:
+			c.set(id, last, 0)
+		default:
+			c.set(id, Module, 0)
+		}
+	}
+}
+
+// setExposed classifies the identifiers of an exposed list. Exposed
+// variables without object that start with an upper case letter are the
+// given kind.
+func (c *classifier) setExposed(list ast.ExposedList, upper Kind) {
+	closed, ok := list.(*ast.ClosedList)
+	if !ok {
+		return
+	}
+
+	for _, e := range closed.Exposed {
+		switch e := e.(type) {
+		case *ast.ExposedVar:
+			c.set(e.Ident, defaultKind(e.Ident, upper), 0)
+		case *ast.ExposedUnion:
+			c.set(e.Type, Type, 0)
+			c.setExposed(e.Ctors, Constructor)
+		}
+	}
+}
+
+// flatten returns the identifiers of a possibly qualified name in the order
+// they appear in the source code.
+func flatten(expr ast.Expr) []*ast.Ident {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return []*ast.Ident{expr}
+	case *ast.SelectorExpr:
+		return append([]*ast.Ident{expr.Selector}, flatten(expr.Expr)...)
+	}
+	return nil
+}
+
+func (c *classifier) visit(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.ModuleDecl:
+		c.setPath(node.Name, Module)
+		c.setExposed(node.Exposing, Type)
+	case *ast.ImportDecl:
+		c.setPath(node.Module, Module)
+		c.set(node.Alias, Module, Declaration)
+		c.setExposed(node.Exposing, Type)
+	case *ast.InfixDecl:
+		c.set(node.Op, Operator, 0)
+	case *ast.AliasDecl:
+		c.set(node.Name, Type, Declaration)
+		for _, a := range node.Args {
+			c.set(a, TypeVariable, Declaration)
+		}
+	case *ast.UnionDecl:
+		c.set(node.Name, Type, Declaration)
+		for _, a := range node.Args {
+			c.set(a, TypeVariable, Declaration)
+		}
+	case *ast.Constructor:
+		c.set(node.Name, Constructor, Declaration)
+	case *ast.Definition:
+		c.set(node.Name, defaultKind(node.Name, Variable), Declaration)
+	case *ast.TypeAnnotation:
+		c.set(node.Name, defaultKind(node.Name, Variable), 0)
+	case *ast.NamedType:
+		c.setPath(node.Name, Type)
+	case *ast.VarType:
+		c.set(node.Ident, TypeVariable, 0)
+	case *ast.RecordField:
+		c.set(node.Name, Field, Declaration)
+	case *ast.VarPattern:
+		c.set(node.Name, Variable, Declaration)
+	case *ast.AliasPattern:
+		c.set(node.Name, Variable, Declaration)
+	case *ast.CtorPattern:
+		c.setPath(node.Ctor, Constructor)
+	case *ast.SelectorExpr:
+		c.setPath(node, Constructor)
+	case *ast.FieldAssign:
+		c.set(node.Field, Field, 0)
+	case *ast.RecordUpdate:
+		c.set(node.Record, Variable, 0)
+	case *ast.AccessorExpr:
+		c.set(node.Field, Field, 0)
+	case *ast.Ident:
+		c.set(node, defaultKind(node, Constructor), 0)
+	}
+	return true
+}
+
+// defaultKind returns the kind of an identifier that appears in a place
+// where it can be anything. Identifiers that start with an upper case letter
+// are the given kind.
+func defaultKind(id *ast.Ident, upper Kind) Kind {
+	switch {
+	case id.IsOp():
+		return Operator
+	case isUpper(id.Name):
+		return upper
+	default:
+		return Variable
+	}
+}
+
+func isUpper(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r)
+}
+
+// objKinds are the kinds of the identifiers resolved to objects of each
+// kind.
+var objKinds = map[ast.ObjKind]Kind{
+	ast.Mod:        Module,
+	ast.NativeMod:  NativeModule,
+	ast.Typ:        Type,
+	ast.BuiltinTyp: Type,
+	ast.Ctor:       Constructor,
+	ast.Var:        Variable,
+	ast.VarTyp:     TypeVariable,
+}
+
+var tokenKinds = map[token.Type]Kind{
+	token.Comment:      Comment,
+	token.String:       String,
+	token.Char:         String,
+	token.GLSL:         String,
+	token.Int:          Number,
+	token.Float:        Number,
+	token.Op:           Operator,
+	token.InfixOp:      Operator,
+	token.Range:        Operator,
+	token.True:         Constructor,
+	token.False:        Constructor,
+	token.Identifier:   Variable,
+	token.LeftParen:    Punctuation,
+	token.RightParen:   Punctuation,
+	token.LeftBracket:  Punctuation,
+	token.RightBracket: Punctuation,
+	token.LeftBrace:    Punctuation,
+	token.RightBrace:   Punctuation,
+	token.Pipe:         Punctuation,
+	token.Colon:        Punctuation,
+	token.Assign:       Punctuation,
+	token.Comma:        Punctuation,
+	token.Arrow:        Punctuation,
+	token.Dot:          Punctuation,
+	token.Backslash:    Punctuation,
+	token.TypeDef:      Keyword,
+	token.As:           Keyword,
+	token.Alias:        Keyword,
+	token.If:           Keyword,
+	token.Then:         Keyword,
+	token.Else:         Keyword,
+	token.Of:           Keyword,
+	token.Case:         Keyword,
+	token.Infix:        Keyword,
+	token.Infixl:       Keyword,
+	token.Infixr:       Keyword,
+	token.Let:          Keyword,
+	token.In:           Keyword,
+	token.Module:       Keyword,
+	token.Exposing:     Keyword,
+	token.Import:       Keyword,
+}
+
+func (c *classifier) classify(t *token.Token) *Token {
+	result := &Token{Token: t, Kind: tokenKinds[t.Type]}
+	if t.Value == "_" {
+		// the wildcard of patterns is scanned as an operator
+		result.Kind = Punctuation
+		return result
+	}
+
+	switch t.Type {
+	case token.Identifier, token.Op, token.InfixOp, token.True, token.False:
+	default:
+		return result
+	}
+
+	id, ok := c.idents[t.Offset]
+	if !ok {
+		if t.Type == token.Identifier {
+			result.Kind = defaultKind(ast.NewIdent(t.Value, t.Offset), Constructor)
+		}
+		return result
+	}
+
+	result.Kind = id.kind
+	result.Modifiers = id.modifiers
+	result.Obj = id.Obj
+	if id.Obj == nil || result.Kind == Operator || result.Kind == Field {
+		return result
+	}
+
+	if kind, ok := objKinds[id.Obj.Kind]; ok {
+		result.Kind = kind
+		if id.Obj.Kind == ast.BuiltinTyp {
+			result.Modifiers |= Builtin
+		}
+	}
+	return result
+}
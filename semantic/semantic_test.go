@@ -0,0 +1,232 @@
+package semantic
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/elm-tangram/tangram/ast"
+	"github.com/elm-tangram/tangram/parser"
+	"github.com/elm-tangram/tangram/scanner"
+	"github.com/elm-tangram/tangram/source"
+	"github.com/elm-tangram/tangram/token"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSource = `module Foo exposing (Shape(..), area)
+
+import Dict as D exposing (Dict)
+
+-- a comment
+type Shape a
+    = Circle Float
+    | Other a
+
+type alias Point = { x : Int }
+
+area : Shape a -> Dict.Dict String Int -> Float
+area shape dict =
+    case shape of
+        Circle r ->
+            r * 3.14
+
+        Other _ ->
+            List.length [ { p | x = 1 }.x ]
+`
+
+// scan returns the tokens of the source without the EOF token.
+func scan(t *testing.T, src string) []*token.Token {
+	s := scanner.New("Foo.elm", strings.NewReader(src))
+	s.Run()
+	tokens := s.Tokens()
+	require.Equal(t, token.EOF, tokens[len(tokens)-1].Type)
+	return tokens[:len(tokens)-1]
+}
+
+type classification struct {
+	value     string
+	kind      Kind
+	modifiers Modifier
+}
+
+func classifications(tokens []*Token) []classification {
+	var result []classification
+	for _, t := range tokens {
+		result = append(result, classification{t.Value, t.Kind, t.Modifiers})
+	}
+	return result
+}
+
+func TestClassify(t *testing.T) {
+	require := require.New(t)
+
+	mod, err := parser.ParseFrom("Foo.elm", strings.NewReader(testSource), parser.FullParse|parser.SkipWarnings)
+	require.NoError(err)
+
+	tokens := classifications(Classify(mod, scan(t, testSource)))
+	expected := []classification{
+		{"module", Keyword, 0},
+		{"Foo", Module, 0},
+		{"exposing", Keyword, 0},
+		{"(", Punctuation, 0},
+		{"Shape", Type, 0},
+		{"(", Punctuation, 0},
+		{"..", Operator, 0},
+		{")", Punctuation, 0},
+		{",", Punctuation, 0},
+		{"area", Variable, 0},
+		{")", Punctuation, 0},
+		{"import", Keyword, 0},
+		{"Dict", Module, 0},
+		{"as", Keyword, 0},
+		{"D", Module, Declaration},
+		{"exposing", Keyword, 0},
+		{"(", Punctuation, 0},
+		{"Dict", Type, 0},
+		{")", Punctuation, 0},
+		{"-- a comment", Comment, 0},
+		{"type", Keyword, 0},
+		{"Shape", Type, Declaration},
+		{"a", TypeVariable, Declaration},
+		{"=", Punctuation, 0},
+		{"Circle", Constructor, Declaration},
+		{"Float", Type, 0},
+		{"|", Punctuation, 0},
+		{"Other", Constructor, Declaration},
+		{"a", TypeVariable, 0},
+		{"type", Keyword, 0},
+		{"alias", Keyword, 0},
+		{"Point", Type, Declaration},
+		{"=", Punctuation, 0},
+		{"{", Punctuation, 0},
+		{"x", Field, Declaration},
+		{":", Punctuation, 0},
+		{"Int", Type, 0},
+		{"}", Punctuation, 0},
+		{"area", Variable, 0},
+		{":", Punctuation, 0},
+		{"Shape", Type, 0},
+		{"a", TypeVariable, 0},
+		{"->", Punctuation, 0},
+		{"Dict", Module, 0},
+		{".", Punctuation, 0},
+		{"Dict", Type, 0},
+		{"String", Type, 0},
+		{"Int", Type, 0},
+		{"->", Punctuation, 0},
+		{"Float", Type, 0},
+		{"area", Variable, Declaration},
+		{"shape", Variable, Declaration},
+		{"dict", Variable, Declaration},
+		{"=", Punctuation, 0},
+		{"case", Keyword, 0},
+		{"shape", Variable, 0},
+		{"of", Keyword, 0},
+		{"Circle", Constructor, 0},
+		{"r", Variable, Declaration},
+		{"->", Punctuation, 0},
+		{"r", Variable, 0},
+		{"*", Operator, 0},
+		{"3.14", Number, 0},
+		{"Other", Constructor, 0},
+		{"_", Punctuation, 0},
+		{"->", Punctuation, 0},
+		{"List", Module, 0},
+		{".", Punctuation, 0},
+		{"length", Variable, 0},
+		{"[", Punctuation, 0},
+		{"{", Punctuation, 0},
+		{"p", Variable, 0},
+		{"|", Punctuation, 0},
+		{"x", Field, 0},
+		{"=", Punctuation, 0},
+		{"1", Number, 0},
+		{"}", Punctuation, 0},
+		{".", Punctuation, 0},
+		{"x", Field, 0},
+		{"]", Punctuation, 0},
+	}
+	require.Equal(expected, tokens)
+}
+
+func TestClassifyResolved(t *testing.T) {
+	require := require.New(t)
+
+	path, err := filepath.Abs(filepath.Join("..", "parser", "_testdata", "valid_fullparse", "src", "Main.elm"))
+	require.NoError(err)
+
+	pkg, err := parser.Parse(path, parser.FullParse|parser.SkipWarnings)
+	require.NoError(err)
+
+	mod := pkg.Modules["Main"]
+	data, err := ioutil.ReadFile(path)
+	require.NoError(err)
+
+	s := scanner.New(path, bytes.NewReader(data))
+	s.Run()
+
+	byValue := make(map[string]*Token)
+	for _, tok := range Classify(mod, s.Tokens()) {
+		byValue[tok.Value] = tok
+	}
+
+	// String in the type annotation of main
+	str := byValue["String"]
+	require.Equal(Type, str.Kind)
+	require.Equal(Builtin, str.Modifiers)
+	require.Equal(ast.BuiltinTyp, str.Obj.Kind)
+
+	// maybeStr ? "hello" ?: "hello world"
+	maybeStr := byValue["maybeStr"]
+	require.Equal(Variable, maybeStr.Kind)
+	require.Equal(ast.Var, maybeStr.Obj.Kind)
+
+	op := byValue["?:"]
+	require.Equal(Operator, op.Kind)
+	require.NotNil(op.Obj)
+}
+
+func TestClassifyWithoutModule(t *testing.T) {
+	tokens := classifications(Classify(nil, scan(t, "x = Just 'a' ++ \"b\" -- c\n")))
+	require.Equal(t, []classification{
+		{"x", Variable, 0},
+		{"=", Punctuation, 0},
+		{"Just", Constructor, 0},
+		{"'a'", String, 0},
+		{"++", Operator, 0},
+		{`"b"`, String, 0},
+		{"-- c", Comment, 0},
+	}, tokens)
+}
+
+func TestModifierStrings(t *testing.T) {
+	require.Equal(t, []string(nil), Modifier(0).Strings())
+	require.Equal(t, []string{"declaration", "builtin"}, (Declaration | Builtin).Strings())
+}
+
+func TestEncodeLSP(t *testing.T) {
+	require := require.New(t)
+
+	input := "{- 😀\n-} x =\r\n  \"😀\""
+	src, err := source.NewSource("Foo.elm", strings.NewReader(input))
+	require.NoError(err)
+
+	s := scanner.NewWithMode("Foo.elm", strings.NewReader(input), scanner.KeepTrivia)
+	s.Run()
+
+	data, err := EncodeLSP(src, Classify(nil, s.Tokens()))
+	require.NoError(err)
+	require.Equal([]uint32{
+		// {- 😀
+		0, 0, 5, 1, 0,
+		// -}
+		1, 0, 2, 1, 0,
+		// x
+		0, 3, 1, 9, 0,
+		// "😀"
+		1, 2, 4, 2, 0,
+	}, data)
+}
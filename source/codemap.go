@@ -3,32 +3,64 @@ package source
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"unicode/utf8"
 
 	"github.com/elm-tangram/tangram/scanner"
 	"github.com/elm-tangram/tangram/token"
 )
 
-// CodeMap contains a set of source code files.
+// CodeMap contains a set of source code files. It is safe to use a CodeMap
+// from multiple goroutines.
 type CodeMap struct {
+	mu     sync.RWMutex
 	loader Loader
 	files  map[string]*Source
 }
 
 // NewCodeMap returns a new code map.
 func NewCodeMap(loader Loader) *CodeMap {
-	return &CodeMap{loader, make(map[string]*Source)}
+	return &CodeMap{loader: loader, files: make(map[string]*Source)}
 }
 
 // Add includes a new file in the codemap. The path given must be a relative
 // path in the project.
 func (cm *CodeMap) Add(path string) error {
+	if cm.Source(path) != nil {
+		return nil
+	}
+
+	src, err := cm.loader.Load(path)
+	if err != nil {
+		return err
+	}
+
+	source, err := NewSource(path, src)
+	if err != nil {
+		return err
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
 	if _, ok := cm.files[path]; ok {
+		// another goroutine added the file in the meantime
+		if c, ok := src.(io.Closer); ok {
+			return c.Close()
+		}
 		return nil
 	}
 
+	cm.files[path] = source
+	return nil
+}
+
+// Replace loads again the file at the given path and replaces the one in the
+// codemap, if any, with it. It must be used when the content of a file that
+// may be in the codemap has changed.
+func (cm *CodeMap) Replace(path string) error {
 	src, err := cm.loader.Load(path)
 	if err != nil {
 		return err
@@ -39,12 +71,40 @@ func (cm *CodeMap) Add(path string) error {
 		return err
 	}
 
+	cm.mu.Lock()
+	old, ok := cm.files[path]
 	cm.files[path] = source
+	cm.mu.Unlock()
+
+	if ok {
+		if c, ok := old.Src.(io.Closer); ok {
+			return c.Close()
+		}
+	}
+	return nil
+}
+
+// Remove removes the file at the given path from the codemap, closing it if
+// it implements io.Closer, so it will be loaded again the next time it's
+// added.
+func (cm *CodeMap) Remove(path string) error {
+	cm.mu.Lock()
+	source, ok := cm.files[path]
+	delete(cm.files, path)
+	cm.mu.Unlock()
+
+	if ok {
+		if c, ok := source.Src.(io.Closer); ok {
+			return c.Close()
+		}
+	}
 	return nil
 }
 
 // Close closes all the source files that implement io.Closer.
 func (cm *CodeMap) Close() error {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
 	for _, f := range cm.files {
 		if f, ok := f.Src.(io.Closer); ok {
 			if err := f.Close(); err != nil {
@@ -57,10 +117,13 @@ func (cm *CodeMap) Close() error {
 
 // Source returns the source for the given path.
 func (cm *CodeMap) Source(path string) *Source {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
 	return cm.files[path]
 }
 
-// Source represents a single source file of code.
+// Source represents a single source file of code. It is safe to use a
+// Source from multiple goroutines.
 type Source struct {
 	// Path is the absolute path of the file.
 	Path string
@@ -69,6 +132,8 @@ type Source struct {
 	Src       io.ReadSeeker
 	lineIndex []lineInfo
 	scanner   *scanner.Scanner
+	// mu guards the reads of Src, which change its offset.
+	mu sync.Mutex
 }
 
 type lineInfo struct {
@@ -86,23 +151,25 @@ func (li lineInfo) inLine(pos token.Pos) bool {
 }
 
 func NewSource(path string, src io.ReadSeeker) (*Source, error) {
-	s := &Source{path, src, nil, nil}
+	s := &Source{Path: path, Src: src}
 	if err := s.makeLineIndex(); err != nil {
 		return nil, err
 	}
 	return s, nil
 }
 
+// makeLineIndex indexes the byte offsets at which every line of the source
+// starts and ends. Lines end with "\n", "\r" or "\r\n".
 func (s *Source) makeLineIndex() (err error) {
 	var (
 		reader = bufio.NewReader(s.Src)
-		r      rune
+		b      byte
 		start  token.Pos
 		pos    token.Pos
 	)
 
 	for {
-		r, _, err = reader.ReadRune()
+		b, err = reader.ReadByte()
 		if err == io.EOF {
 			err = nil
 			if start != pos {
@@ -116,7 +183,14 @@ func (s *Source) makeLineIndex() (err error) {
 		}
 
 		pos++
-		if r == '\n' || r == '\r' {
+		if b == '\r' {
+			// "\r\n" is a single end of line
+			if next, err := reader.Peek(1); err == nil && next[0] == '\n' {
+				continue
+			}
+		}
+
+		if b == '\n' || b == '\r' {
 			s.lineIndex = append(s.lineIndex, lineInfo{start, pos})
 			start = pos
 		}
@@ -151,24 +225,133 @@ func (s *Source) findLineStart(pos token.Pos) (token.Pos, int) {
 	return s.lineIndex[start].start, start + 1
 }
 
-// LinePos returns the column and line of an offset in the source.
+// LinePos returns the column and line of an offset in the source. The column
+// is the one displayed in a terminal, that is, the number of runes since the
+// start of the line with every tab counted as 4 columns, plus one.
 func (s *Source) LinePos(pos token.Pos) (lp LinePos, err error) {
-	start, lineNo := s.findLineStart(pos)
-	if _, err = s.Src.Seek(int64(start), io.SeekStart); err != nil {
+	prefix, lineNo, err := s.linePrefix(pos)
+	if err != nil {
 		return
 	}
 
-	var buf bytes.Buffer
-	if _, err = io.Copy(&buf, io.LimitReader(s.Src, int64(pos-start))); err != nil {
+	line := strings.Replace(string(prefix), "\t", tab, -1)
+	lp.Col = utf8.RuneCountInString(line) + 1
+	lp.Line = lineNo
+	return
+}
+
+// ColumnMode is the unit in which columns are measured.
+type ColumnMode int
+
+const (
+	// ByteColumn measures columns in bytes.
+	ByteColumn ColumnMode = iota
+	// RuneColumn measures columns in runes, which is what terminals
+	// display, excluding wide characters.
+	RuneColumn
+	// UTF16Column measures columns in UTF-16 code units, which is what the
+	// Language Server Protocol uses.
+	UTF16Column
+)
+
+// Position returns the line and column of an offset in the source, with the
+// column measured in the given mode. Both the line and the column start at
+// 1 and tabs are counted as any other character.
+func (s *Source) Position(pos token.Pos, mode ColumnMode) (lp LinePos, err error) {
+	prefix, lineNo, err := s.linePrefix(pos)
+	if err != nil {
 		return
 	}
 
-	line := strings.Replace(buf.String(), "\t", tab, -1)
-	lp.Col = utf8.RuneCountInString(line) + 1
+	lp.Col = columnWidth(prefix, mode) + 1
 	lp.Line = lineNo
 	return
 }
 
+// Offset returns the offset in the source of the given line and column, with
+// the column measured in the given mode. Both the line and the column start
+// at 1. A column past the end of the line is the end of the line, and a
+// column in the middle of a rune is the start of the rune.
+func (s *Source) Offset(line, col int, mode ColumnMode) (token.Pos, error) {
+	if line < 1 || line > len(s.lineIndex) {
+		return token.NoPos, fmt.Errorf("source: line %d is out of range in %s", line, s.Path)
+	}
+
+	li := s.lineIndex[line-1]
+	content, err := s.read(li.start, li.end)
+	if err != nil {
+		return token.NoPos, err
+	}
+	content = bytes.TrimRight(content, "\r\n")
+
+	var offset, width int
+	for offset < len(content) {
+		r, size := utf8.DecodeRune(content[offset:])
+		w := runeWidth(r, size, mode)
+		if width+w > col-1 {
+			break
+		}
+		width += w
+		offset += size
+	}
+
+	return li.start + token.Pos(offset), nil
+}
+
+// linePrefix returns the content of the line of the given offset up to that
+// offset, and the number of the line.
+func (s *Source) linePrefix(pos token.Pos) ([]byte, int, error) {
+	start, lineNo := s.findLineStart(pos)
+	prefix, err := s.read(start, pos)
+	return prefix, lineNo, err
+}
+
+// read returns the content of the source between the given offsets.
+func (s *Source) read(start, end token.Pos) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.Src.Seek(int64(start), io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, io.LimitReader(s.Src, int64(end-start))); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// columnWidth returns the width of the given content measured in the given
+// mode.
+func columnWidth(content []byte, mode ColumnMode) int {
+	if mode == ByteColumn {
+		return len(content)
+	}
+
+	var width int
+	for len(content) > 0 {
+		r, size := utf8.DecodeRune(content)
+		width += runeWidth(r, size, mode)
+		content = content[size:]
+	}
+	return width
+}
+
+// runeWidth returns the width of the given rune, which is encoded in UTF-8
+// with the given size, measured in the given mode.
+func runeWidth(r rune, size int, mode ColumnMode) int {
+	switch mode {
+	case ByteColumn:
+		return size
+	case UTF16Column:
+		if r >= 0x10000 && r <= utf8.MaxRune {
+			return 2
+		}
+	}
+	return 1
+}
+
 type Snippet struct {
 	Start int
 	Lines []string
@@ -180,6 +363,9 @@ const tab = "    "
 // Region returns a region of the source code beginning at the start position
 // and ending at the end of the given region.
 func (s *Source) Region(start, end token.Pos) (*Snippet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	lineStart, lineNo := s.findLineStart(start)
 	if _, err := s.Src.Seek(int64(lineStart), io.SeekStart); err != nil {
 		return nil, err
@@ -224,8 +410,13 @@ func (s *Source) Region(start, end token.Pos) (*Snippet, error) {
 	return &snippet, nil
 }
 
-// Scanner returns a scanner for this source with all the tokens parsed.
+// Scanner returns a scanner for this source with all the tokens parsed. The
+// scanner is shared, so it must not be used by more than one goroutine at the
+// same time.
 func (s *Source) Scanner() *scanner.Scanner {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if s.scanner == nil {
 		s.scanner = scanner.New(s.Path, s.Src)
 		s.scanner.Run()
@@ -118,3 +118,110 @@ func TestSource(t *testing.T) {
 		}
 	})
 }
+
+func TestCodeMapReplace(t *testing.T) {
+	require := require.New(t)
+
+	loader := NewOverlayLoader(NewMemLoader())
+	loader.Set("Main.elm", "module Main", 1)
+	cm := NewCodeMap(loader)
+	require.NoError(cm.Add("Main.elm"))
+
+	// adding a file again does not load it again
+	loader.Set("Main.elm", "module Main exposing (..)", 2)
+	require.NoError(cm.Add("Main.elm"))
+	snippet, err := cm.Source("Main.elm").Region(0, 11)
+	require.NoError(err)
+	require.Equal([]string{"module Main"}, snippet.Lines)
+
+	require.NoError(cm.Replace("Main.elm"))
+	snippet, err = cm.Source("Main.elm").Region(0, 25)
+	require.NoError(err)
+	require.Equal([]string{"module Main exposing (..)"}, snippet.Lines)
+
+	require.NoError(cm.Remove("Main.elm"))
+	require.Nil(cm.Source("Main.elm"))
+
+	loader.Invalidate("Main.elm")
+	require.Error(cm.Replace("Main.elm"))
+	require.Error(cm.Add("Main.elm"))
+}
+
+const multibyteFixture = "greeting = \"héllo 😀 wörld\"\n\tn = 1\n"
+
+func TestSourceMultibyte(t *testing.T) {
+	require := require.New(t)
+	s, err := NewSource("foo", strings.NewReader(multibyteFixture))
+	require.NoError(err)
+
+	firstLine := strings.Index(multibyteFixture, "\n") + 1
+	require.Len(s.lineIndex, 2)
+	require.Equal(lineInfo{0, token.Pos(firstLine)}, s.lineIndex[0])
+	require.Equal(lineInfo{token.Pos(firstLine), token.Pos(len(multibyteFixture))}, s.lineIndex[1])
+
+	world := token.Pos(strings.Index(multibyteFixture, "wörld"))
+	n := token.Pos(strings.Index(multibyteFixture, "n ="))
+
+	cases := []struct {
+		pos  token.Pos
+		mode ColumnMode
+		col  int
+		line int
+	}{
+		{world, ByteColumn, 25, 1},
+		{world, RuneColumn, 21, 1},
+		{world, UTF16Column, 22, 1},
+		{n, ByteColumn, 2, 2},
+		{n, RuneColumn, 2, 2},
+		{n, UTF16Column, 2, 2},
+	}
+
+	for _, c := range cases {
+		p, err := s.Position(c.pos, c.mode)
+		require.NoError(err)
+		require.Equal(c.line, p.Line, "line of offset %d in mode %d", c.pos, c.mode)
+		require.Equal(c.col, p.Col, "col of offset %d in mode %d", c.pos, c.mode)
+
+		offset, err := s.Offset(c.line, c.col, c.mode)
+		require.NoError(err)
+		require.Equal(c.pos, offset, "offset of %d:%d in mode %d", c.line, c.col, c.mode)
+	}
+
+	// the terminal column expands tabs
+	p, err := s.LinePos(n)
+	require.NoError(err)
+	require.Equal(LinePos{Col: 5, Line: 2}, p)
+
+	p, err = s.LinePos(world)
+	require.NoError(err)
+	require.Equal(LinePos{Col: 21, Line: 1}, p)
+
+	// the second half of the emoji in UTF-16 and columns past the end
+	emoji := token.Pos(strings.Index(multibyteFixture, "😀"))
+	offset, err := s.Offset(1, 20, UTF16Column)
+	require.NoError(err)
+	require.Equal(emoji, offset)
+
+	offset, err = s.Offset(2, 100, RuneColumn)
+	require.NoError(err)
+	require.Equal(token.Pos(len(multibyteFixture)-1), offset)
+
+	_, err = s.Offset(3, 1, RuneColumn)
+	require.Error(err)
+
+	snippet, err := s.Region(world, world+6)
+	require.NoError(err)
+	require.Equal([]string{strings.Repeat(" ", 20) + "wörld"}, snippet.Lines)
+}
+
+func TestSourceLineEndings(t *testing.T) {
+	require := require.New(t)
+	s, err := NewSource("foo", strings.NewReader("a\r\nb\rc\n\nd"))
+	require.NoError(err)
+
+	require.Equal([]lineInfo{{0, 3}, {3, 5}, {5, 7}, {7, 8}, {8, 9}}, s.lineIndex)
+
+	p, err := s.LinePos(token.Pos(3))
+	require.NoError(err)
+	require.Equal(LinePos{Col: 1, Line: 2}, p)
+}
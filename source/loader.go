@@ -3,8 +3,11 @@ package source
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
 	
 	"github.com/elm-tangram/tangram/package"
 )
@@ -74,3 +77,126 @@ func (l *MemLoader) Load(path string) (io.ReadSeeker, error) {
 
 	return nil, os.ErrNotExist
 }
+
+// OverlayLoader is a loader that loads the files from another loader unless
+// they have been overridden with an in-memory content, such as the unsaved
+// buffers of an editor. Overrides are keyed by the absolute path of the file
+// and have a version, so clients can know which content of a file is being
+// used. It is safe to use an OverlayLoader from multiple goroutines.
+type OverlayLoader struct {
+	base     Loader
+	mu       sync.RWMutex
+	overlays map[string]overlay
+}
+
+type overlay struct {
+	content string
+	version int
+}
+
+// NewOverlayLoader creates a new overlay loader on top of the given loader.
+func NewOverlayLoader(base Loader) *OverlayLoader {
+	return &OverlayLoader{base: base, overlays: make(map[string]overlay)}
+}
+
+// Set overrides the content of the file at the given absolute path with the
+// given content and version. The file does not need to exist in the
+// underlying loader. Code maps that already contain the file need to replace
+// it for the new content to take effect.
+func (l *OverlayLoader) Set(path, content string, version int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.overlays[path] = overlay{content, version}
+}
+
+// Invalidate removes the override of the file at the given absolute path,
+// so it is loaded again from the underlying loader. It reports whether the
+// file was overridden.
+func (l *OverlayLoader) Invalidate(path string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.overlays[path]
+	delete(l.overlays, path)
+	return ok
+}
+
+// Version returns the version of the override of the file at the given
+// absolute path and whether it is overridden.
+func (l *OverlayLoader) Version(path string) (int, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	o, ok := l.overlays[path]
+	return o.version, ok
+}
+
+// Overridden returns the absolute paths of all the overridden files, sorted
+// alphabetically.
+func (l *OverlayLoader) Overridden() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	paths := make([]string, 0, len(l.overlays))
+	for path := range l.overlays {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// AbsPath returns the absolute path of the given path using the underlying
+// loader.
+func (l *OverlayLoader) AbsPath(path string) string {
+	return l.base.AbsPath(path)
+}
+
+// Load retrieves the content of the override of the given path, if any, or
+// loads it from the underlying loader otherwise.
+func (l *OverlayLoader) Load(path string) (io.ReadSeeker, error) {
+	l.mu.RLock()
+	o, ok := l.overlays[path]
+	l.mu.RUnlock()
+
+	if ok {
+		return bytes.NewReader([]byte(o.content)), nil
+	}
+	return l.base.Load(path)
+}
+
+// BufferedLoader is a loader that reads the whole content of the files of
+// another loader when they are loaded, so the sources keep the content they
+// had when they were loaded even if the files change afterwards, and they do
+// not need to be closed.
+type BufferedLoader struct {
+	base Loader
+}
+
+// NewBufferedLoader creates a new buffered loader on top of the given loader.
+func NewBufferedLoader(base Loader) *BufferedLoader {
+	return &BufferedLoader{base}
+}
+
+// AbsPath returns the absolute path of the given path using the underlying
+// loader.
+func (l *BufferedLoader) AbsPath(path string) string {
+	return l.base.AbsPath(path)
+}
+
+// Load reads the whole content of the given path from the underlying loader,
+// closing it afterwards if it implements io.Closer.
+func (l *BufferedLoader) Load(path string) (io.ReadSeeker, error) {
+	src, err := l.base.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadAll(src)
+	if c, ok := src.(io.Closer); ok {
+		if closeErr := c.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(content), nil
+}
@@ -0,0 +1,77 @@
+package source
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func loadString(t *testing.T, l Loader, path string) string {
+	src, err := l.Load(path)
+	require.NoError(t, err)
+	content, err := ioutil.ReadAll(src)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestOverlayLoader(t *testing.T) {
+	require := require.New(t)
+
+	base := NewMemLoader()
+	base.Add("/src/Main.elm", "module Main")
+	l := NewOverlayLoader(base)
+
+	require.Equal("module Main", loadString(t, l, "/src/Main.elm"))
+	_, ok := l.Version("/src/Main.elm")
+	require.False(ok)
+
+	l.Set("/src/Main.elm", "module Main exposing (..)", 1)
+	l.Set("/src/Foo.elm", "module Foo", 3)
+	require.Equal("module Main exposing (..)", loadString(t, l, "/src/Main.elm"))
+	require.Equal("module Foo", loadString(t, l, "/src/Foo.elm"))
+	require.Equal([]string{"/src/Foo.elm", "/src/Main.elm"}, l.Overridden())
+
+	l.Set("/src/Main.elm", "module Main exposing (main)", 2)
+	version, ok := l.Version("/src/Main.elm")
+	require.True(ok)
+	require.Equal(2, version)
+	require.Equal("module Main exposing (main)", loadString(t, l, "/src/Main.elm"))
+
+	require.True(l.Invalidate("/src/Main.elm"))
+	require.False(l.Invalidate("/src/Main.elm"))
+	require.Equal("module Main", loadString(t, l, "/src/Main.elm"))
+
+	require.True(l.Invalidate("/src/Foo.elm"))
+	_, err := l.Load("/src/Foo.elm")
+	require.Equal(os.ErrNotExist, err)
+}
+
+func TestBufferedLoader(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "tangram-loader")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "Main.elm")
+	require.NoError(ioutil.WriteFile(path, []byte("module Main"), 0644))
+
+	l := NewBufferedLoader(NewFsLoader(nil))
+	src, err := l.Load(path)
+	require.NoError(err)
+	_, ok := src.(io.Closer)
+	require.False(ok)
+
+	require.NoError(ioutil.WriteFile(path, []byte("module Main exposing (..)"), 0644))
+	content, err := ioutil.ReadAll(src)
+	require.NoError(err)
+	require.Equal("module Main", string(content))
+	require.Equal("module Main exposing (..)", loadString(t, l, path))
+
+	_, err = l.Load(filepath.Join(dir, "Foo.elm"))
+	require.True(os.IsNotExist(err))
+}
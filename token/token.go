@@ -5,17 +5,29 @@ type Token struct {
 	Type  Type
 	Value string
 	*Position
+	// Leading contains the trivia tokens (whitespace, end of lines and
+	// comments) found right before this token. It is only set when the
+	// source is parsed keeping the trivia.
+	Leading []*Token
 }
 
-// Pos is the offset of something within a file of source code.
+// Pos is the offset of something within a file of source code, in bytes.
+// It is never a count of runes or characters, so it can be used to index the
+// source code directly.
 type Pos int
 
 // NoPos is the zero value of Pos, which is actually an invalid position.
 // When the position of something is NoPos is because it is an error.
 const NoPos Pos = 0
 
+// IsValid reports whether the position is not NoPos.
+func (p Pos) IsValid() bool {
+	return p != NoPos
+}
+
 // Position represents the position of the token in a file and contains its
-// offset (in bytes), the source of the token, the line and the column.
+// offset (in bytes), the source of the token, the line and the column (in
+// runes).
 type Position struct {
 	Source string
 	Offset Pos
@@ -85,6 +97,8 @@ const (
 	Range
 	// Char is a quoted character literal
 	Char
+	// GLSL is a GLSL shader block "[glsl| ... |]"
+	GLSL
 	// Dot is the dot character "."
 	Dot
 
@@ -126,4 +140,16 @@ const (
 	Import
 	// Backslash is the "\" character
 	Backslash
+
+	// Whitespace is a run of spaces and tabs
+	Whitespace
+	// Newline is a run of end of lines
+	Newline
 )
+
+// IsTrivia reports whether the type is whitespace, an end of line or a
+// comment, which can appear anywhere in the source code without changing its
+// meaning.
+func (t Type) IsTrivia() bool {
+	return t == Whitespace || t == Newline || t == Comment
+}
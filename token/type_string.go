@@ -48,6 +48,8 @@ func (t Type) String() string {
 		return ".."
 	case Char:
 		return "character"
+	case GLSL:
+		return "glsl shader"
 	case True:
 		return "True"
 	case False:
@@ -84,6 +86,10 @@ func (t Type) String() string {
 		return "exposing"
 	case Import:
 		return "import"
+	case Whitespace:
+		return "whitespace"
+	case Newline:
+		return "end of line"
 	default:
 		return "invalid token"
 	}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/elm-tangram/tangram/scanner"
+	"github.com/elm-tangram/tangram/semantic"
+	"github.com/elm-tangram/tangram/source"
+	"github.com/elm-tangram/tangram/token"
+)
+
+var tokensCmd = &command{
+	name:  "tokens",
+	usage: "[flags] file.elm",
+	short: "Print the tokens of the given file and their semantic classification",
+	run:   runTokens,
+}
+
+func runTokens(cmd *command, args []string) error {
+	flags := newFlagSet(cmd)
+	noResolve := flags.Bool("no-resolve", false, "only parse the file, without parsing its imports and resolving the identifiers")
+	trivia := flags.Bool("trivia", false, "print the whitespace and end of line tokens too")
+	format := flags.String("format", "text", "output format, either text or json")
+	maxErrors := maxErrorsFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 || (*format != "text" && *format != "json") {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	path := flags.Arg(0)
+	mod, _, cm, err := parseFile(path, *noResolve, true, *maxErrors)
+	if err != nil {
+		return err
+	}
+	src := cm.Source(mod.Path)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	s := scanner.NewWithMode(path, bytes.NewReader(data), scanner.KeepTrivia)
+	s.Run()
+
+	var tokens []*token.Token
+	for _, t := range s.Tokens() {
+		if *trivia || !t.Type.IsTrivia() || t.Type == token.Comment {
+			tokens = append(tokens, t)
+		}
+	}
+
+	classified := semantic.Classify(mod, tokens)
+	if *format == "json" {
+		return printTokensJSON(os.Stdout, src, classified)
+	}
+	return printTokens(os.Stdout, src, classified)
+}
+
+func printTokens(w io.Writer, src *source.Source, tokens []*semantic.Token) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, t := range tokens {
+		pos, err := src.LinePos(t.Offset)
+		if err != nil {
+			return err
+		}
+
+		kind := t.Kind.String()
+		if mods := t.Modifiers.Strings(); len(mods) > 0 {
+			kind += " (" + strings.Join(mods, ", ") + ")"
+		}
+
+		fmt.Fprintf(tw, "%d:%d\t%s\t%s\t%q\n", pos.Line, pos.Col, t.Type, kind, t.Value)
+	}
+	return tw.Flush()
+}
+
+// jsonToken is the JSON representation of a classified token.
+type jsonToken struct {
+	Type      string   `json:"type"`
+	Value     string   `json:"value"`
+	Offset    int      `json:"offset"`
+	Line      int      `json:"line"`
+	Column    int      `json:"column"`
+	Kind      string   `json:"kind"`
+	Modifiers []string `json:"modifiers,omitempty"`
+}
+
+func printTokensJSON(w io.Writer, src *source.Source, tokens []*semantic.Token) error {
+	result := make([]jsonToken, len(tokens))
+	for i, t := range tokens {
+		pos, err := src.LinePos(t.Offset)
+		if err != nil {
+			return err
+		}
+
+		result[i] = jsonToken{
+			Type:      t.Type.String(),
+			Value:     t.Value,
+			Offset:    int(t.Offset),
+			Line:      pos.Line,
+			Column:    pos.Col,
+			Kind:      t.Kind.String(),
+			Modifiers: t.Modifiers.Strings(),
+		}
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
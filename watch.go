@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/elm-tangram/tangram/package"
+	"github.com/elm-tangram/tangram/parser"
+	"github.com/elm-tangram/tangram/report"
+	"github.com/elm-tangram/tangram/watch"
+)
+
+var watchCmd = &command{
+	name:  "watch",
+	usage: "[flags] [module]",
+	short: "Check the given entry module and check it again every time a source file changes",
+	run:   runWatch,
+}
+
+func runWatch(cmd *command, args []string) error {
+	flags := newFlagSet(cmd)
+	dir := flags.String("dir", ".", "directory of the package")
+	interval := flags.Duration("interval", watch.DefaultInterval, "time between two checks of the source files")
+	debounce := flags.Duration("debounce", watch.DefaultDebounce, "time to wait for more changes before checking again")
+	noWarnings := flags.Bool("no-warnings", false, "do not print warnings")
+	noColor := flags.Bool("no-color", false, "do not use colors in the diagnostics")
+	maxErrors := maxErrorsFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	entry := "Main"
+	if flags.NArg() > 0 {
+		entry = flags.Arg(0)
+	}
+
+	p, err := pkg.Load(*dir)
+	if err != nil {
+		return err
+	}
+
+	path, err := p.FindModule(entry)
+	if err != nil {
+		return fmt.Errorf("elmc: can't find module %q: %s", entry, err)
+	}
+
+	b, err := parser.NewBuilder(path, parser.FullParse|parser.UseCache)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	b.SetEmitter(report.Stderr(!*noWarnings, !*noColor))
+	b.SetMaxErrors(*maxErrors)
+
+	dirs := make([]string, len(p.SourceDirectories))
+	for i, d := range p.SourceDirectories {
+		dirs[i] = filepath.Join(p.Root(), d)
+	}
+
+	w, err := watch.New(dirs, isSourceFile)
+	if err != nil {
+		return err
+	}
+	w.Interval = *interval
+	w.Debounce = *debounce
+	w.Skip(func(dir string) bool {
+		return filepath.Base(dir) == "elm-stuff"
+	})
+
+	printBuild(b.Build())
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	return w.Watch(stop, func(changed []string) {
+		fmt.Fprintf(os.Stderr, "\n%d file(s) changed, checking again...\n", len(changed))
+		printBuild(b.Rebuild(changed...))
+	})
+}
+
+// isSourceFile reports whether the file at the given path is an Elm module or
+// the Go file of a native module.
+func isSourceFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".elm":
+		return true
+	case ".go":
+		return strings.Contains(path, string(filepath.Separator)+"Native"+string(filepath.Separator))
+	}
+	return false
+}
+
+func printBuild(result *parser.BuildResult, err error) {
+	switch {
+	case err == parser.ErrBuildFailed:
+		fmt.Fprintln(os.Stderr, "Check failed, waiting for changes...")
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+	default:
+		fmt.Fprintf(
+			os.Stderr,
+			"Check succeeded: %d module(s) parsed and %d resolved, waiting for changes...\n",
+			len(result.Parsed),
+			len(result.Resolved),
+		)
+	}
+}
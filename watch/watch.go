@@ -0,0 +1,166 @@
+// Package watch implements a watcher that detects changes in the files of a
+// set of directories by polling them, so it works the same way in every
+// platform and file system.
+package watch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultInterval is the default time between two scans of the watched
+	// directories.
+	DefaultInterval = 300 * time.Millisecond
+	// DefaultDebounce is the default time a watcher waits without any new
+	// change before notifying the changes found.
+	DefaultDebounce = 100 * time.Millisecond
+)
+
+// Watcher detects the files that have been added, modified or removed in a
+// set of directories and their subdirectories. A Watcher must not be used
+// from multiple goroutines at the same time.
+type Watcher struct {
+	// Interval is the time between two scans of the directories.
+	Interval time.Duration
+	// Debounce is the time to wait without any new change before notifying
+	// the changes, so a burst of changes is notified just once.
+	Debounce time.Duration
+
+	dirs  []string
+	match func(path string) bool
+	skip  func(dir string) bool
+	files map[string]fileState
+}
+
+// fileState is the state of a file the last time it was scanned.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// New creates a new watcher for the given directories that only watches the
+// files for which match returns true. The current state of the files is
+// taken as the starting point, so changes made before calling New are not
+// notified.
+func New(dirs []string, match func(path string) bool) (*Watcher, error) {
+	w := &Watcher{
+		Interval: DefaultInterval,
+		Debounce: DefaultDebounce,
+		dirs:     dirs,
+		match:    match,
+		files:    make(map[string]fileState),
+	}
+
+	if _, err := w.Scan(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Skip makes the watcher ignore the directories for which skip returns true
+// and all their contents.
+func (w *Watcher) Skip(skip func(dir string) bool) {
+	w.skip = skip
+}
+
+// Scan scans the directories and returns the paths of the files that have
+// been added, modified or removed since the last scan, sorted alphabetically.
+// Directories that do not exist are ignored.
+func (w *Watcher) Scan() ([]string, error) {
+	files := make(map[string]fileState, len(w.files))
+	for _, dir := range w.dirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+
+			if info.IsDir() {
+				if path != dir && w.skip != nil && w.skip(path) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if w.match(path) {
+				files[path] = fileState{info.ModTime(), info.Size()}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("watch: can't scan %q: %s", dir, err)
+		}
+	}
+
+	var changed []string
+	for path, st := range files {
+		if old, ok := w.files[path]; !ok || old != st {
+			changed = append(changed, path)
+		}
+	}
+
+	for path := range w.files {
+		if _, ok := files[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+
+	w.files = files
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// Watch scans the directories every Interval until stop is closed or an
+// error happens. Every time there are changes, it waits until no new changes
+// are found for Debounce and then calls fn with the paths of all the files
+// changed, sorted alphabetically. The returned error is nil if Watch stopped
+// because stop was closed.
+func (w *Watcher) Watch(stop <-chan struct{}, fn func(changed []string)) error {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	var (
+		pending  = make(map[string]struct{})
+		deadline time.Time
+	)
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case now := <-ticker.C:
+			changed, err := w.Scan()
+			if err != nil {
+				return err
+			}
+
+			if len(changed) > 0 {
+				for _, path := range changed {
+					pending[path] = struct{}{}
+				}
+				deadline = now.Add(w.Debounce)
+				continue
+			}
+
+			if len(pending) > 0 && !now.Before(deadline) {
+				fn(sortedPaths(pending))
+				pending = make(map[string]struct{})
+			}
+		}
+	}
+}
+
+func sortedPaths(paths map[string]struct{}) []string {
+	result := make([]string, 0, len(paths))
+	for path := range paths {
+		result = append(result, path)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,112 @@
+package watch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func isElm(path string) bool {
+	return strings.HasSuffix(path, ".elm")
+}
+
+func writeFile(t *testing.T, path, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func TestScan(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "tangram-watch")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	main := filepath.Join(dir, "Main.elm")
+	foo := filepath.Join(dir, "Foo", "Bar.elm")
+	stuff := filepath.Join(dir, "elm-stuff", "Dep.elm")
+	writeFile(t, main, "module Main")
+
+	w, err := New([]string{dir, filepath.Join(dir, "missing")}, isElm)
+	require.NoError(err)
+	w.Skip(func(dir string) bool {
+		return filepath.Base(dir) == "elm-stuff"
+	})
+
+	changed, err := w.Scan()
+	require.NoError(err)
+	require.Len(changed, 0)
+
+	writeFile(t, foo, "module Foo.Bar")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "not elm")
+	writeFile(t, stuff, "module Dep")
+
+	changed, err = w.Scan()
+	require.NoError(err)
+	require.Equal([]string{foo}, changed)
+
+	writeFile(t, main, "module Main exposing (..)")
+	require.NoError(os.Remove(foo))
+
+	changed, err = w.Scan()
+	require.NoError(err)
+	require.Equal([]string{foo, main}, changed)
+
+	changed, err = w.Scan()
+	require.NoError(err)
+	require.Len(changed, 0)
+}
+
+func TestWatchDebounce(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "tangram-watch")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	w, err := New([]string{dir}, isElm)
+	require.NoError(err)
+	w.Interval = 5 * time.Millisecond
+	w.Debounce = 50 * time.Millisecond
+
+	notified := make(chan []string, 10)
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- w.Watch(stop, func(changed []string) {
+			notified <- changed
+		})
+	}()
+
+	// a burst of saves is notified only once
+	paths := []string{
+		filepath.Join(dir, "A.elm"),
+		filepath.Join(dir, "B.elm"),
+		filepath.Join(dir, "C.elm"),
+	}
+	for _, path := range paths {
+		writeFile(t, path, "module A")
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case changed := <-notified:
+		require.Equal(paths, changed)
+	case <-time.After(5 * time.Second):
+		require.FailNow("changes were not notified")
+	}
+
+	select {
+	case changed := <-notified:
+		require.FailNow("unexpected notification", "%v", changed)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(stop)
+	require.NoError(<-done)
+}